@@ -15,8 +15,9 @@ const (
 )
 
 type ContentPolicyResult struct {
-	Allow   bool     `json:"allow"`
-	Reasons []string `json:"reasons"`
+	Allow      bool     `json:"allow"`
+	Reasons    []string `json:"reasons"`
+	DecisionID string   `json:"-"`
 }
 
 type Agent interface {
@@ -46,6 +47,7 @@ func (o *OpenPolicyAgent) EvaluateContentPolicy(
 	}
 
 	o.log.Infof("Evaluated Content Policy: decisionID: %q, result: %v", decisionID, r)
+	r.DecisionID = decisionID
 
 	return r, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -9,12 +11,17 @@ import (
 	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Financial-Times/opa-client-go"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/access"
+	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
 	"github.com/Financial-Times/notifications-push/v5/resources"
+	"github.com/Financial-Times/notifications-push/v5/tracing"
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
 )
@@ -96,18 +103,114 @@ func main() {
 		Desc:   "application port",
 		EnvVar: "PORT",
 	})
+	grpcPort := app.Int(cli.IntOpt{
+		Name:   "grpc_port",
+		Value:  0,
+		Desc:   "The port of the gRPC API for the internal consumers. If not set the gRPC API is disabled.",
+		EnvVar: "GRPC_PORT",
+	})
 	historySize := app.Int(cli.IntOpt{
 		Name:   "notification_history_size",
 		Value:  200,
 		Desc:   "the number of recent notifications to be saved and returned on the /__history endpoint",
 		EnvVar: "NOTIFICATION_HISTORY_SIZE",
 	})
+	historyOrder := app.String(cli.StringOpt{
+		Name:   "notification_history_order",
+		Value:  string(dispatch.OrderByLastModified),
+		Desc:   "The order of the notifications returned on the /__history endpoint (arrival, notificationDate or lastModified).",
+		EnvVar: "NOTIFICATION_HISTORY_ORDER",
+	})
+	historyDir := app.String(cli.StringOpt{
+		Name:   "notification_history_dir",
+		Value:  "",
+		Desc:   "Directory of the on-disk notification history journal which survives restarts. If empty the history is kept only in memory.",
+		EnvVar: "NOTIFICATION_HISTORY_DIR",
+	})
+	historyMaxAge := app.Int(cli.IntOpt{
+		Name:   "notification_history_max_age",
+		Value:  0,
+		Desc:   "The maximum age of the notifications kept in the history journal (in minutes). Zero means no age limit.",
+		EnvVar: "NOTIFICATION_HISTORY_MAX_AGE",
+	})
+	traceSize := app.Int(cli.IntOpt{
+		Name:   "notification_trace_size",
+		Value:  200,
+		Desc:   "the number of recent notifications for which the delivery decisions are kept and returned on the /__history/{tid} endpoint",
+		EnvVar: "NOTIFICATION_TRACE_SIZE",
+	})
 	delay := app.Int(cli.IntOpt{
 		Name:   "notifications_delay",
 		Value:  30,
 		Desc:   "The time to delay each notification before forwarding to any subscribers (in seconds).",
 		EnvVar: "NOTIFICATIONS_DELAY",
 	})
+	latencyThreshold := app.Int(cli.IntOpt{
+		Name:   "delivery_latency_threshold",
+		Value:  120,
+		Desc:   "The p95 time from the last modification of the content to the notification fan-out above which the delivery latency healthcheck fails (in seconds). Zero disables the check.",
+		EnvVar: "DELIVERY_LATENCY_THRESHOLD",
+	})
+	canaryInterval := app.Int(cli.IntOpt{
+		Name:   "canary_interval",
+		Value:  0,
+		Desc:   "The time between two canary notifications sent to the dispatcher to verify the delivery end to end (in seconds). Zero disables the canary.",
+		EnvVar: "CANARY_INTERVAL",
+	})
+	canaryDeadline := app.Int(cli.IntOpt{
+		Name:   "canary_deadline",
+		Value:  5,
+		Desc:   "The time within which a canary notification must come back to the canary subscriber (in seconds).",
+		EnvVar: "CANARY_DEADLINE",
+	})
+	maxPending := app.Int(cli.IntOpt{
+		Name:   "saturation_max_pending",
+		Value:  1000,
+		Desc:   "The number of notifications waiting for their delay or for the dispatcher to be unfrozen above which the dispatcher saturation healthcheck fails. Zero disables the condition.",
+		EnvVar: "SATURATION_MAX_PENDING",
+	})
+	maxLaggingRatio := app.Float64(cli.Float64Opt{
+		Name:   "saturation_max_lagging_ratio",
+		Value:  0.5,
+		Desc:   "The share of subscribers with a full buffer, between 0 and 1, above which the dispatcher saturation healthcheck fails. Zero disables the condition.",
+		EnvVar: "SATURATION_MAX_LAGGING_RATIO",
+	})
+	activityWindow := app.Int(cli.IntOpt{
+		Name:   "publishing_activity_window",
+		Value:  60,
+		Desc:   "The rolling window over which the publishing activity healthcheck counts the accepted and rejected messages (in minutes).",
+		EnvVar: "PUBLISHING_ACTIVITY_WINDOW",
+	})
+	activityMinNotifications := app.Int(cli.IntOpt{
+		Name:   "publishing_min_notifications",
+		Value:  1,
+		Desc:   "The number of notifications expected in the publishing activity window during the active hours, below which the healthcheck fails. Zero disables the condition.",
+		EnvVar: "PUBLISHING_MIN_NOTIFICATIONS",
+	})
+	activityHours := app.String(cli.StringOpt{
+		Name:   "publishing_active_hours",
+		Value:  "07:00-21:00",
+		Desc:   "The daily period during which content is expected to be published, formatted as HH:MM-HH:MM. Empty means all day.",
+		EnvVar: "PUBLISHING_ACTIVE_HOURS",
+	})
+	activityTimezone := app.String(cli.StringOpt{
+		Name:   "publishing_active_hours_timezone",
+		Value:  "Europe/London",
+		Desc:   "The timezone of the publishing active hours.",
+		EnvVar: "PUBLISHING_ACTIVE_HOURS_TIMEZONE",
+	})
+	maxRejectionRatio := app.Float64(cli.Float64Opt{
+		Name:   "publishing_max_rejection_ratio",
+		Value:  5,
+		Desc:   "How many times the ratio of messages rejected by the allowlists per accepted notification in the baseline window the ratio of the publishing activity window must exceed for the healthcheck to fail. Zero disables the condition.",
+		EnvVar: "PUBLISHING_MAX_REJECTION_RATIO",
+	})
+	activityBaselineWindow := app.Int(cli.IntOpt{
+		Name:   "publishing_baseline_window",
+		Value:  24,
+		Desc:   "The window preceding the publishing activity window whose rejection ratio is the baseline of the publishing activity healthcheck (in hours).",
+		EnvVar: "PUBLISHING_BASELINE_WINDOW",
+	})
 	contentURIAllowList := app.String(cli.StringOpt{
 		Name:   "contentURIAllowList",
 		Value:  "",
@@ -187,6 +290,38 @@ func main() {
 		EnvVar: "NOTIFICATIONS_PUSH_POLICY_PATH",
 	})
 
+	adminToken := app.String(cli.StringOpt{
+		Name:   "admin_token",
+		Value:  "",
+		Desc:   "The token required to access the /__admin endpoints. If empty the admin endpoints are disabled.",
+		EnvVar: "ADMIN_TOKEN",
+	})
+
+	tracingExporter := app.String(cli.StringOpt{
+		Name:   "tracing_exporter",
+		Value:  tracing.ExporterNone,
+		Desc:   "Where the OpenTelemetry spans are exported: none, to only propagate the trace context, or stdout for local use.",
+		EnvVar: "TRACING_EXPORTER",
+	})
+	sessionLogFile := app.String(cli.StringOpt{
+		Name:   "session_log_file",
+		Value:  "",
+		Desc:   "The file the subscriber session records are written to, rotated once it reaches session_log_max_size. If empty the records are written to the standard output with the service logs.",
+		EnvVar: "SESSION_LOG_FILE",
+	})
+	sessionLogMaxSize := app.Int(cli.IntOpt{
+		Name:   "session_log_max_size",
+		Value:  100,
+		Desc:   "The size (in megabytes) at which the session log file is rotated",
+		EnvVar: "SESSION_LOG_MAX_SIZE",
+	})
+	sessionLogMaxBackups := app.Int(cli.IntOpt{
+		Name:   "session_log_max_backups",
+		Value:  5,
+		Desc:   "The number of rotated session log files kept",
+		EnvVar: "SESSION_LOG_MAX_BACKUPS",
+	})
+
 	log := logger.NewUPPLogger(serviceName, *logLevel)
 
 	app.Action = func() {
@@ -199,6 +334,11 @@ func main() {
 			"E2E_TEST_IDS":      *e2eTestUUIDs,
 		}).Infof("[Startup] notifications-push is starting ")
 
+		shutdownTracing, err := tracing.Setup(*tracingExporter, serviceName, os.Stdout)
+		if err != nil {
+			log.WithError(err).Fatal("could not set up tracing")
+		}
+
 		kafkaConsumer, err := createConsumer(log, *kafkaClusterArn, *consumerAddress, *consumerGroupID, *kafkaTopic, *consumerLagTolerance)
 		if err != nil {
 			log.WithError(err).Fatalf("could not create Kafka consumer for %s and topic %s", *consumerAddress, *kafkaTopic)
@@ -244,9 +384,27 @@ func main() {
 		}
 
 		healthCheckEndpoint = baseURL.ResolveReference(healthCheckEndpoint)
-		hc := resources.NewHealthCheck(kafkaConsumer, healthCheckEndpoint.String(), requestStatusCode, serviceName, log)
 
-		dispatcher, history := createDispatcher(*delay, *historySize, opaAgent, log)
+		order, err := dispatch.ParseHistoryOrder(*historyOrder)
+		if err != nil {
+			log.WithError(err).Fatal("invalid notification_history_order")
+		}
+
+		history, err := createHistory(*historyDir, *historySize, time.Duration(*historyMaxAge)*time.Minute, order, log)
+		if err != nil {
+			log.WithError(err).Fatal("could not open notification history journal")
+		}
+		appMetrics := metrics.New()
+		dispatcher := createDispatcher(*delay, history, dispatch.NewTraceStore(*traceSize), opaAgent, appMetrics, log)
+		if err = appMetrics.Register(dispatcher.Collector()); err != nil {
+			log.WithError(err).Fatal("could not register dispatcher metrics")
+		}
+		publishing, err := createPublishingThresholds(*activityMinNotifications, *activityHours, *activityTimezone, *maxRejectionRatio)
+		if err != nil {
+			log.WithError(err).Fatal("invalid publishing activity configuration")
+		}
+		activity := queueConsumer.NewPublishingActivity(time.Duration(*activityWindow)*time.Minute, time.Duration(*activityBaselineWindow)*time.Hour)
+		probes := createCanary(*canaryInterval, *canaryDeadline, dispatcher, appMetrics, log)
 
 		msgConfig := msgHandlerCfg{
 			BaseURL:              *apiBaseURL,
@@ -264,6 +422,10 @@ func main() {
 			log.WithError(err).Fatal("could not start notification consumer")
 		}
 
+		// the messages injected by the admins are metered and counted in the publishing activity as the consumed ones
+		processor := queueConsumer.NewMeteredHandler(queueConsumer.NewActivityHandler(queueHandler, activity), appMetrics)
+		consumption := queueConsumer.NewPausableHandler(processor)
+
 		keyValidateURL, err := url.Parse(*apiKeyValidationEndpoint)
 		if err != nil {
 			log.WithError(err).Fatal("cannot parse api_key_validation_endpoint")
@@ -281,21 +443,74 @@ func main() {
 
 		keyProcessor := access.NewKeyProcessor(keyValidateURL, httpClient, log)
 		policyProcessor := access.NewPolicyProcessor(keyPoliciesURL, httpClient)
-		subHandler := resources.NewSubHandler(dispatcher, keyProcessor, policyProcessor, srv, heartbeatPeriod,
+
+		policiesAddress := ""
+		if keyPoliciesURL != nil {
+			policiesAddress = keyPoliciesURL.String()
+		}
+		hc := resources.NewHealthCheck(kafkaConsumer, healthCheckEndpoint.String(), policiesAddress, requestStatusCode, opaAgent, dispatcher, activity, probes,
+			resources.HealthThresholds{
+				DeliveryLatency: time.Duration(*latencyThreshold) * time.Second,
+				Publishing:      publishing,
+				Saturation: resources.SaturationThresholds{
+					MaxPending:      *maxPending,
+					MaxLaggingRatio: *maxLaggingRatio,
+				},
+			}, serviceName, log)
+
+		taps := resources.NewStreamTaps()
+		maintenance := resources.NewMaintenance()
+		streams := resources.NewStreams()
+		sessionRecorder, sessionLog, err := createSessionRecorder(serviceName, *sessionLogFile, *sessionLogMaxSize, *sessionLogMaxBackups)
+		if err != nil {
+			log.WithError(err).Fatal("could not open the session log")
+		}
+		subHandler := resources.NewSubHandler(dispatcher, keyProcessor, policyProcessor, srv, taps, maintenance, streams, sessionRecorder, appMetrics, heartbeatPeriod,
 			log, *allowedAllContentType, *supportedSubscriptionType, *defaultSubscriptionType)
 		if err != nil {
 			log.WithError(err).Fatal("Could not create request handler")
 		}
 
-		initRouter(router, subHandler, *resource, dispatcher, history, hc, log)
+		var adminHandler *resources.AdminHandler
+		if *adminToken != "" {
+			adminHandler = resources.NewAdminHandler(*adminToken, dispatcher, history, taps, processor, consumption, maintenance, streams, hc, srv, heartbeatPeriod, log)
+		}
+
+		initRouter(router, subHandler, *resource, dispatcher, streams, history, hc, appMetrics, adminHandler, log)
 
-		shutdown := startService(srv, dispatcher, kafkaConsumer, queueHandler, log)
+		grpcSrv, err := createGRPCService(*grpcPort, subHandler)
+		if err != nil {
+			log.WithError(err).Fatal("could not listen for gRPC subscriptions")
+		}
+
+		shutdown := startService(srv, grpcSrv, dispatcher, kafkaConsumer, consumption, log)
+		if probes != nil {
+			go probes.Start()
+		}
 
 		ch := make(chan os.Signal, 1)
 		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 		<-ch
 
+		if probes != nil {
+			probes.Stop()
+		}
 		shutdown(time.Second * 30)
+
+		if err = shutdownTracing(context.Background()); err != nil {
+			log.WithError(err).Error("Failed to flush the tracing spans")
+		}
+
+		if c, ok := history.(io.Closer); ok {
+			if err = c.Close(); err != nil {
+				log.WithError(err).Error("Failed to close notification history journal")
+			}
+		}
+		if sessionLog != nil {
+			if err = sessionLog.Close(); err != nil {
+				log.WithError(err).Error("Failed to close the session log")
+			}
+		}
 	}
 
 	if err := app.Run(os.Args); err != nil {
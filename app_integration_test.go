@@ -24,6 +24,7 @@ import (
 	"github.com/Financial-Times/kafka-client-go/v4"
 	"github.com/Financial-Times/notifications-push/v5/consumer"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
 	"github.com/Financial-Times/notifications-push/v5/mocks"
 	"github.com/Financial-Times/notifications-push/v5/resources"
 )
@@ -115,7 +116,7 @@ func TestPushNotifications(t *testing.T) {
 	defer server.Close()
 
 	// handler
-	hc := resources.NewHealthCheck(queue, apiGatewayGTGURL, nil, "notifications-push", l)
+	hc := resources.NewHealthCheck(queue, apiGatewayGTGURL, "", nil, nil, d, nil, nil, resources.HealthThresholds{DeliveryLatency: time.Minute}, "notifications-push", l)
 
 	keyProcessorURL, _ := url.Parse(server.URL + apiGatewayValidateURL)
 	policyProcessorURL, _ := url.Parse(server.URL + apiGatewayPoliciesURL)
@@ -123,10 +124,10 @@ func TestPushNotifications(t *testing.T) {
 	keyProcessor := access.NewKeyProcessor(keyProcessorURL, http.DefaultClient, l)
 	policyProcessor := access.NewPolicyProcessor(policyProcessorURL, http.DefaultClient)
 
-	s := resources.NewSubHandler(d, keyProcessor, policyProcessor, reg, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+	s := resources.NewSubHandler(d, keyProcessor, policyProcessor, reg, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 		[]string{"Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
-	initRouter(router, s, resource, d, h, hc, l)
+	initRouter(router, s, resource, d, nil, h, hc, metrics.New(), nil, l)
 
 	// key validation
 	router.HandleFunc(apiGatewayValidateURL, func(resp http.ResponseWriter, req *http.Request) {
@@ -409,9 +410,9 @@ func startSubscriber(ctx context.Context, serverURL string, subType string) (<-c
 }
 
 func startDispatcher(delay time.Duration, historySize int, log *logger.UPPLogger) (*dispatch.Dispatcher, dispatch.History, error) {
-	h := dispatch.NewHistory(historySize)
+	h := dispatch.NewHistory(historySize, dispatch.OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(log)
-	d := dispatch.NewDispatcher(delay, h, oa, log)
+	d := dispatch.NewDispatcher(delay, h, nil, oa, nil, log)
 	go d.Start()
 	return d, h, nil
 }
@@ -0,0 +1,190 @@
+package canary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/gofrs/uuid"
+)
+
+// Address identifies the canary subscriber in the logs and the delivery traces
+const Address = "canary"
+
+type dispatcher interface {
+	SubscribeProbes(address string, subTypes []string, options *access.NotificationSubscriptionOptions) (dispatch.Subscriber, error)
+	Unsubscribe(subscriber dispatch.Subscriber)
+	Probe(n dispatch.NotificationModel, target *dispatch.Target)
+}
+
+// Result of a canary probe
+type Result struct {
+	TransactionID string
+	At            time.Time
+	RoundTrip     time.Duration
+	Err           error
+}
+
+// Canary subscribes to the dispatcher as a probe subscriber and periodically sends it an E2E test notification,
+// checking that the notification comes back through the fan-out and the serialisation within the deadline.
+// As a probe subscriber it receives only its own notifications, and is not listed among the dispatcher subscribers.
+type Canary struct {
+	dispatcher dispatcher
+	interval   time.Duration
+	deadline   time.Duration
+	metrics    *metrics.Metrics
+	subscriber dispatch.Subscriber
+	mutex      *sync.RWMutex
+	last       *Result
+	stopChan   chan struct{}
+	log        *logger.UPPLogger
+}
+
+func New(d dispatcher, interval time.Duration, deadline time.Duration, m *metrics.Metrics, log *logger.UPPLogger) *Canary {
+	return &Canary{
+		dispatcher: d,
+		interval:   interval,
+		deadline:   deadline,
+		metrics:    m,
+		mutex:      &sync.RWMutex{},
+		stopChan:   make(chan struct{}),
+		log:        log,
+	}
+}
+
+// Start subscribes the canary and probes the dispatcher at every interval until Stop is called
+func (c *Canary) Start() {
+	if err := c.subscribe(); err != nil {
+		c.log.WithError(err).Error("Failed to subscribe the canary")
+		return
+	}
+	defer c.dispatcher.Unsubscribe(c.subscriber)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.Probe()
+		select {
+		case <-ticker.C:
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// Stop ends the probes, it does not block when Start has already returned
+func (c *Canary) Stop() {
+	close(c.stopChan)
+}
+
+// Last returns the result of the last probe, false before the first one completes
+func (c *Canary) Last() (Result, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.last == nil {
+		return Result{}, false
+	}
+	return *c.last, true
+}
+
+// Interval returns the time between two probes
+func (c *Canary) Interval() time.Duration {
+	return c.interval
+}
+
+// Probe sends a canary notification and waits for it to come back to the canary subscriber.
+// It must not be called concurrently with another probe.
+func (c *Canary) Probe() Result {
+	result := c.probe()
+
+	c.mutex.Lock()
+	c.last = &result
+	c.mutex.Unlock()
+
+	entry := c.log.WithTransactionID(result.TransactionID)
+	if result.Err != nil {
+		c.metrics.CanaryProbed(metrics.CanaryFailure, result.RoundTrip)
+		entry.WithError(result.Err).Error("Canary notification failed")
+	} else {
+		c.metrics.CanaryProbed(metrics.CanarySuccess, result.RoundTrip)
+		entry.WithField("roundTrip", result.RoundTrip.String()).Debug("Canary notification received")
+	}
+	return result
+}
+
+func (c *Canary) probe() Result {
+	result := Result{At: time.Now()}
+	if err := c.subscribe(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		result.Err = fmt.Errorf("failed to generate the canary notification ID: %w", err)
+		return result
+	}
+	result.TransactionID = "tid_canary_" + id.String()
+	c.dispatcher.Probe(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/" + id.String(),
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: result.TransactionID,
+		LastModified:     result.At.Format(dispatch.RFC3339Millis),
+		SubscriptionType: dispatch.ArticleContentType,
+		IsE2ETest:        true,
+	}, &dispatch.Target{SubscriberID: c.subscriber.ID()})
+
+	timeout := time.NewTimer(c.deadline)
+	defer timeout.Stop()
+	for {
+		select {
+		case msg := <-c.subscriber.Notifications():
+			received, err := decode(msg)
+			if err != nil {
+				result.Err = err
+				result.RoundTrip = time.Since(result.At)
+				return result
+			}
+			// notifications of earlier probes that missed their deadline are skipped
+			if received == result.TransactionID {
+				result.RoundTrip = time.Since(result.At)
+				return result
+			}
+		case <-timeout.C:
+			result.Err = fmt.Errorf("canary notification not received within %v", c.deadline)
+			result.RoundTrip = c.deadline
+			return result
+		}
+	}
+}
+
+func (c *Canary) subscribe() error {
+	if c.subscriber != nil {
+		return nil
+	}
+	s, err := c.dispatcher.SubscribeProbes(Address, []string{dispatch.ArticleContentType}, &access.NotificationSubscriptionOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe the canary: %w", err)
+	}
+	c.subscriber = s
+	return nil
+}
+
+// decode returns the transaction ID of the serialised notification
+func decode(msg string) (string, error) {
+	var notifications []dispatch.NotificationResponse
+	if err := json.Unmarshal([]byte(msg), &notifications); err != nil {
+		return "", fmt.Errorf("invalid canary notification: %w", err)
+	}
+	if len(notifications) != 1 {
+		return "", errors.New("invalid canary notification: expected exactly one notification")
+	}
+	return notifications[0].PublishReference, nil
+}
@@ -0,0 +1,155 @@
+package canary
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+)
+
+type stubAgent struct{}
+
+func (a *stubAgent) EvaluateContentPolicy(_ map[string]interface{}) (*access.ContentPolicyResult, error) {
+	return &access.ContentPolicyResult{Allow: true}, nil
+}
+
+func TestCanaryProbe(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	h := dispatch.NewHistory(10, dispatch.OrderByArrival)
+	d := dispatch.NewDispatcher(time.Minute, h, nil, &stubAgent{}, nil, l)
+	monitor, _ := d.Subscribe("192.168.1.3", []string{dispatch.ArticleContentType}, true, &access.NotificationSubscriptionOptions{})
+
+	go d.Start()
+	defer d.Stop()
+
+	m := metrics.New()
+	c := New(d, time.Minute, time.Second, m, l)
+	_, ok := c.Last()
+	assert.False(t, ok)
+
+	result := c.Probe()
+	require.NoError(t, result.Err)
+	assert.Contains(t, result.TransactionID, "tid_canary_")
+	assert.Positive(t, result.RoundTrip)
+
+	last, ok := c.Last()
+	assert.True(t, ok)
+	assert.Equal(t, result, last)
+	assert.Empty(t, monitor.Notifications(), "The canary notification should only reach the canary")
+	assert.Empty(t, h.Notifications())
+
+	d.Freeze()
+	result = c.Probe()
+	assert.EqualError(t, result.Err, "canary notification not received within 1s")
+
+	// the notification held while frozen comes back late and is skipped by the next probe
+	d.Unfreeze()
+	result = c.Probe()
+	require.NoError(t, result.Err)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `notifications_push_canary_probes_total{result="success"} 2`)
+	assert.Contains(t, w.Body.String(), `notifications_push_canary_probes_total{result="failure"} 1`)
+}
+
+// probeCounter keeps count of the probe subscribers registered by the canary
+type probeCounter struct {
+	*dispatch.Dispatcher
+	subscribed int32
+}
+
+func (p *probeCounter) SubscribeProbes(address string, subTypes []string, options *access.NotificationSubscriptionOptions) (dispatch.Subscriber, error) {
+	s, err := p.Dispatcher.SubscribeProbes(address, subTypes, options)
+	if err == nil {
+		atomic.AddInt32(&p.subscribed, 1)
+	}
+	return s, err
+}
+
+func (p *probeCounter) Unsubscribe(s dispatch.Subscriber) {
+	p.Dispatcher.Unsubscribe(s)
+	atomic.AddInt32(&p.subscribed, -1)
+}
+
+func (p *probeCounter) count() int32 {
+	return atomic.LoadInt32(&p.subscribed)
+}
+
+func TestCanaryStartStop(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, &stubAgent{}, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	p := &probeCounter{Dispatcher: d}
+	c := New(p, 10*time.Millisecond, time.Second, nil, l)
+	go c.Start()
+
+	require.Eventually(t, func() bool { return p.count() == 1 }, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		last, ok := c.Last()
+		return ok && last.Err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Empty(t, d.Subscribers(), "The canary should not be listed among the subscribers")
+
+	c.Stop()
+	assert.Eventually(t, func() bool { return p.count() == 0 }, time.Second, 10*time.Millisecond,
+		"The canary should unsubscribe once stopped")
+}
+
+// failingDispatcher refuses the canary subscription
+type failingDispatcher struct {
+	*dispatch.Dispatcher
+}
+
+func (f *failingDispatcher) SubscribeProbes(string, []string, *access.NotificationSubscriptionOptions) (dispatch.Subscriber, error) {
+	return nil, errors.New("subscription refused")
+}
+
+func TestCanaryStopAfterFailedSubscribe(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	c := New(&failingDispatcher{}, 10*time.Millisecond, time.Second, nil, l)
+	c.Start()
+
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop should not block once Start has returned")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	tid, err := decode(`[{"apiUrl":"","id":"","type":"","publishReference":"tid_canary"}]`)
+	require.NoError(t, err)
+	assert.Equal(t, "tid_canary", tid)
+
+	_, err = decode(`[]`)
+	assert.EqualError(t, err, "invalid canary notification: expected exactly one notification")
+	_, err = decode(`{`)
+	assert.Error(t, err)
+}
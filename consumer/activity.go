@@ -0,0 +1,145 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const activityBucket = time.Minute
+
+// ActivityReport counts the messages accepted and rejected by the queue handler over the activity window.
+// Accepted messages were dispatched, rejected ones were left out by the content URI or content type allowlists.
+// Complete tells if the service has been counting for the whole window.
+// The baseline counts are those of the longer baseline window preceding the activity window,
+// BaselineComplete tells if the service has been counting for both windows.
+type ActivityReport struct {
+	Window   time.Duration
+	Accepted int
+	Rejected int
+	Complete bool
+
+	BaselineWindow   time.Duration
+	BaselineAccepted int
+	BaselineRejected int
+	BaselineComplete bool
+}
+
+type activityCount struct {
+	minute   int64
+	accepted int
+	rejected int
+}
+
+// PublishingActivity counts the accepted and rejected messages per minute over a rolling window and the baseline window preceding it.
+// All methods are safe to call on a nil value, which counts nothing.
+type PublishingActivity struct {
+	mutex          *sync.Mutex
+	window         time.Duration
+	baselineWindow time.Duration
+	buckets        []activityCount
+	started        time.Time
+}
+
+// NewPublishingActivity keeps the counts of the given window and baseline window, both rounded up to the minute
+func NewPublishingActivity(window time.Duration, baselineWindow time.Duration) *PublishingActivity {
+	size := int((window + activityBucket - 1) / activityBucket)
+	if size < 1 {
+		size = 1
+	}
+	baselineSize := int((baselineWindow + activityBucket - 1) / activityBucket)
+	if baselineSize < 0 {
+		baselineSize = 0
+	}
+	return &PublishingActivity{
+		mutex:          &sync.Mutex{},
+		window:         time.Duration(size) * activityBucket,
+		baselineWindow: time.Duration(baselineSize) * activityBucket,
+		buckets:        make([]activityCount, size+baselineSize),
+		started:        time.Now(),
+	}
+}
+
+// Record counts the message outcome at the given time, the outcomes other than dispatched and rejected are ignored
+func (a *PublishingActivity) Record(outcome Outcome, at time.Time) {
+	if a == nil {
+		return
+	}
+	var accepted, rejected int
+	switch outcome {
+	case OutcomeDispatched:
+		accepted = 1
+	case OutcomeAllowlistRejected, OutcomeContentTypeRejected:
+		rejected = 1
+	default:
+		return
+	}
+
+	minute := at.Unix() / int64(activityBucket/time.Second)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	b := &a.buckets[minute%int64(len(a.buckets))]
+	if b.minute != minute {
+		*b = activityCount{minute: minute}
+	}
+	b.accepted += accepted
+	b.rejected += rejected
+}
+
+// Report sums the counts of the window ending at the given time, and those of the baseline window preceding it
+func (a *PublishingActivity) Report(now time.Time) ActivityReport {
+	if a == nil {
+		return ActivityReport{}
+	}
+	current := now.Unix() / int64(activityBucket/time.Second)
+	oldest := current - int64(a.window/activityBucket) + 1
+	oldestBaseline := current - int64(len(a.buckets)) + 1
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	r := ActivityReport{
+		Window:           a.window,
+		Complete:         now.Sub(a.started) >= a.window,
+		BaselineWindow:   a.baselineWindow,
+		BaselineComplete: now.Sub(a.started) >= a.window+a.baselineWindow,
+	}
+	for _, b := range a.buckets {
+		switch {
+		case b.minute >= oldest && b.minute <= current:
+			r.Accepted += b.accepted
+			r.Rejected += b.rejected
+		case b.minute >= oldestBaseline && b.minute < oldest:
+			r.BaselineAccepted += b.accepted
+			r.BaselineRejected += b.rejected
+		}
+	}
+	return r
+}
+
+// ActivityHandler records the outcome of the processed messages in the publishing activity
+type ActivityHandler struct {
+	processor messageProcessor
+	activity  *PublishingActivity
+}
+
+func NewActivityHandler(processor messageProcessor, activity *PublishingActivity) *ActivityHandler {
+	return &ActivityHandler{
+		processor: processor,
+		activity:  activity,
+	}
+}
+
+// Process processes the message and records its outcome
+func (h *ActivityHandler) Process(queueMsg kafka.FTMessage) Result {
+	result := h.processor.Process(queueMsg)
+	h.activity.Record(result.Outcome, time.Now())
+	return result
+}
+
+// HandleMessage processes the message and records its outcome
+func (h *ActivityHandler) HandleMessage(queueMsg kafka.FTMessage) {
+	h.Process(queueMsg)
+}
@@ -0,0 +1,70 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishingActivity(t *testing.T) {
+	t.Parallel()
+
+	activity := NewPublishingActivity(90*time.Second, 150*time.Second)
+	assert.Equal(t, 2*time.Minute, activity.window, "The window should be rounded up to the minute")
+	assert.Equal(t, 3*time.Minute, activity.baselineWindow, "The baseline window should be rounded up to the minute")
+
+	now := time.Now()
+	activity.Record(OutcomeAllowlistRejected, now.Add(-6*time.Minute))
+	activity.Record(OutcomeDispatched, now.Add(-4*time.Minute))
+	activity.Record(OutcomeDispatched, now.Add(-3*time.Minute))
+	activity.Record(OutcomeContentTypeRejected, now.Add(-2*time.Minute))
+	activity.Record(OutcomeDispatched, now.Add(-time.Minute))
+	activity.Record(OutcomeDispatched, now)
+	activity.Record(OutcomeAllowlistRejected, now)
+	activity.Record(OutcomeContentTypeRejected, now)
+	activity.Record(OutcomeCarousel, now)
+	activity.Record(OutcomeMappingError, now)
+
+	assert.Equal(t, ActivityReport{
+		Window: 2 * time.Minute, Accepted: 2, Rejected: 2,
+		BaselineWindow: 3 * time.Minute, BaselineAccepted: 2, BaselineRejected: 1,
+	}, activity.Report(now))
+	assert.Equal(t, ActivityReport{Window: 2 * time.Minute, Complete: true, BaselineWindow: 3 * time.Minute, BaselineAccepted: 2, BaselineRejected: 3},
+		activity.Report(now.Add(2*time.Minute)), "The counts of the window should move to the baseline as they age")
+	assert.Equal(t, ActivityReport{Window: 2 * time.Minute, Complete: true, BaselineWindow: 3 * time.Minute, BaselineComplete: true},
+		activity.Report(now.Add(6*time.Minute)), "The counts older than the baseline window should be left out")
+
+	activity.started = now.Add(-2 * time.Minute)
+	assert.True(t, activity.Report(now).Complete)
+	assert.False(t, activity.Report(now).BaselineComplete)
+	activity.started = now.Add(-5 * time.Minute)
+	assert.True(t, activity.Report(now).BaselineComplete)
+
+	var nilActivity *PublishingActivity
+	nilActivity.Record(OutcomeDispatched, now)
+	assert.Equal(t, ActivityReport{}, nilActivity.Report(now))
+}
+
+func TestActivityHandler(t *testing.T) {
+	t.Parallel()
+
+	outcomes := []Outcome{OutcomeDispatched, OutcomeAllowlistRejected, OutcomeDispatched}
+	processed := 0
+	processor := processorFunc(func(_ kafka.FTMessage) Result {
+		outcome := outcomes[processed]
+		processed++
+		return Result{Outcome: outcome}
+	})
+
+	activity := NewPublishingActivity(time.Minute, 0)
+	handler := NewActivityHandler(processor, activity)
+	handler.HandleMessage(kafka.NewFTMessage(nil, ""))
+	handler.HandleMessage(kafka.NewFTMessage(nil, ""))
+	assert.Equal(t, OutcomeDispatched, handler.Process(kafka.NewFTMessage(nil, "")).Outcome)
+
+	r := activity.Report(time.Now())
+	assert.Equal(t, 2, r.Accepted)
+	assert.Equal(t, 1, r.Rejected)
+}
@@ -1,11 +1,16 @@
 package consumer
 
 import (
+	"context"
 	"regexp"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MessageQueueHandler is a generic interface for implementation of components to handle messages form the kafka queue.
@@ -60,8 +65,50 @@ func NewQueueHandler(contentURIAllowlist *regexp.Regexp, contentTypeAllowlist *S
 	}
 }
 
+// Outcome is what the queue handler did with a message
+type Outcome string
+
+// message outcomes
+const (
+	OutcomeInvalidMessage      Outcome = "invalid-message"
+	OutcomeCarousel            Outcome = "carousel"
+	OutcomeSynthetic           Outcome = "synthetic"
+	OutcomeAllowlistRejected   Outcome = "allowlist-rejected"
+	OutcomeContentTypeRejected Outcome = "content-type-rejected"
+	OutcomeMappingError        Outcome = "mapping-error"
+	OutcomeDispatched          Outcome = "dispatched"
+)
+
+// Result describes the processing of a message. The notification is set only when the message was dispatched.
+type Result struct {
+	Outcome      Outcome
+	Notification *dispatch.NotificationModel
+	Err          error
+}
+
 func (h *QueueHandler) HandleMessage(queueMsg kafka.FTMessage) {
+	h.Process(queueMsg)
+}
+
+// Process filters and maps the message and sends the resulting notification to the dispatcher.
+// It is traced as a child of the trace context found in the message headers.
+func (h *QueueHandler) Process(queueMsg kafka.FTMessage) Result {
 	msg := NotificationQueueMessage{queueMsg}
+	ctx, span := tracing.Tracer().Start(tracing.Extract(queueMsg.Headers), "HandleMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(tracing.TransactionIDKey.String(msg.TransactionID())))
+	defer span.End()
+
+	result := h.process(ctx, msg)
+	span.SetAttributes(attribute.String("outcome", string(result.Outcome)))
+	if result.Err != nil {
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	}
+	return result
+}
+
+func (h *QueueHandler) process(ctx context.Context, msg NotificationQueueMessage) Result {
 	tid := msg.TransactionID()
 
 	pubEvent, err := msg.Unmarshal()
@@ -75,38 +122,40 @@ func (h *QueueHandler) HandleMessage(queueMsg kafka.FTMessage) {
 
 	if err != nil {
 		logEntry.WithError(err).Error("Failed to unmarshall kafka message")
-		return
+		return Result{Outcome: OutcomeInvalidMessage, Err: err}
 	}
 
 	if msg.HasCarouselTransactionID() {
 		logEntry.WithValidFlag(false).WithField("contentUri", pubEvent.ContentURI).Info("Skipping event: Carousel publish event.")
-		return
+		return Result{Outcome: OutcomeCarousel}
 	}
 
 	isE2ETest := msg.HasE2ETestTransactionID(h.e2eTestUUIDs)
 	if !isE2ETest {
 		if msg.HasSynthTransactionID() {
 			logEntry.WithValidFlag(false).WithField("contentUri", pubEvent.ContentURI).Info("Skipping event: Synthetic transaction ID.")
-			return
+			return Result{Outcome: OutcomeSynthetic}
 		}
 
 		if pubEvent.ContentType == "application/json" {
 			if !pubEvent.Matches(h.contentURIAllowlist) {
 				logEntry.WithValidFlag(false).WithField("contentUri", pubEvent.ContentURI).Info("Skipping event: contentUri is not in the allowlist.")
-				return
+				return Result{Outcome: OutcomeAllowlistRejected}
 			}
 		} else {
 			if !h.contentTypeAllowlist.Contains(pubEvent.ContentType) {
 				logEntry.WithValidFlag(false).Info("Skipping event: contentType is not in the allowlist.")
-				return
+				return Result{Outcome: OutcomeContentTypeRejected}
 			}
 		}
 	}
 
+	_, mapSpan := tracing.Tracer().Start(ctx, "MapNotification")
 	notification, err := h.mapper.MapNotification(pubEvent, msg.TransactionID())
+	mapSpan.End()
 	if err != nil {
 		logEntry.WithError(err).Warn("Skipping event: Cannot build notification for message.")
-		return
+		return Result{Outcome: OutcomeMappingError, Err: err}
 	}
 	notification.IsE2ETest = isE2ETest
 
@@ -118,5 +167,7 @@ func (h *QueueHandler) HandleMessage(queueMsg kafka.FTMessage) {
 	if !isE2ETest && notification.SubscriptionType == dispatch.ArticleContentType {
 		h.log.WithField("eventType", notification.Type).WithField("ID", notification.ID).WithTransactionID(tid).Info("Processed article notification")
 	}
+	notification.SpanContext = trace.SpanContextFromContext(ctx)
 	h.dispatcher.Send(notification)
+	return Result{Outcome: OutcomeDispatched, Notification: &notification}
 }
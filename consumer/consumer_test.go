@@ -8,6 +8,7 @@ import (
 	hooks "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
@@ -556,3 +557,90 @@ func TestAcceptNotificationBasedOnE2ETransactionID(t *testing.T) {
 	assert.NotContains(t, buf.String(), "error")
 	dispatcher.AssertExpectations(t)
 }
+
+func TestProcessOutcome(t *testing.T) {
+	t.Parallel()
+
+	mapper := NotificationMapper{
+		APIBaseURL:      "test.api.ft.com",
+		APIUrlResource:  "content",
+		UpdateEventType: "http://www.ft.com/thing/ThingChangeType/UPDATE",
+	}
+	l := logger.NewUPPLogger("test", "panic")
+
+	contentTypeAllowlist := NewSet()
+	contentTypeAllowlist.Add("application/vnd.ft-upp-article+json")
+
+	tests := map[string]struct {
+		headers         map[string]string
+		body            string
+		expectedOutcome Outcome
+		expectError     bool
+	}{
+		"invalid message": {
+			headers:         map[string]string{"X-Request-Id": "tid_test"},
+			expectedOutcome: OutcomeInvalidMessage,
+			expectError:     true,
+		},
+		"carousel": {
+			headers:         map[string]string{"X-Request-Id": "tid_carousel_1234567890"},
+			body:            `{"ContentURI": "http://upp-article-mapper.svc.ft.com:8080/content/uuid"}`,
+			expectedOutcome: OutcomeCarousel,
+		},
+		"synthetic": {
+			headers:         map[string]string{"X-Request-Id": "SYNTH_test"},
+			body:            `{"ContentURI": "http://upp-article-mapper.svc.ft.com:8080/content/uuid"}`,
+			expectedOutcome: OutcomeSynthetic,
+		},
+		"content uri not in the allowlist": {
+			headers:         map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"},
+			body:            `{"ContentURI": "http://not-in-the-allowlist"}`,
+			expectedOutcome: OutcomeAllowlistRejected,
+		},
+		"content type not in the allowlist": {
+			headers:         map[string]string{"X-Request-Id": "tid_test", "Content-Type": "invalid-type"},
+			body:            `{"ContentURI": "http://upp-article-mapper.svc.ft.com:8080/content/uuid"}`,
+			expectedOutcome: OutcomeContentTypeRejected,
+		},
+		"mapping error": {
+			headers:         map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"},
+			body:            `{"ContentURI": "http://upp-article-mapper.svc.ft.com:8080/content/uuid"}`,
+			expectedOutcome: OutcomeMappingError,
+			expectError:     true,
+		},
+		"dispatched": {
+			headers:         map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/vnd.ft-upp-article+json"},
+			body:            `{"payload": {"title": "Title"}, "ContentURI": "http://upp-content-validator.svc.ft.com/content/f601289e-93a0-4c08-854e-fef334584079"}`,
+			expectedOutcome: OutcomeDispatched,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dispatcher := &mocks.Dispatcher{}
+			dispatcher.On("Send", mock.AnythingOfType("dispatch.NotificationModel")).Return()
+			handler := NewQueueHandler(sparkIncludedAllowlist, contentTypeAllowlist, nil, false, mapper, dispatcher, l)
+
+			result := handler.Process(kafka.NewFTMessage(test.headers, test.body))
+
+			assert.Equal(t, test.expectedOutcome, result.Outcome)
+			if test.expectError {
+				assert.Error(t, result.Err)
+			} else {
+				assert.NoError(t, result.Err)
+			}
+			if test.expectedOutcome != OutcomeDispatched {
+				assert.Nil(t, result.Notification)
+				dispatcher.AssertNotCalled(t, "Send", mock.Anything)
+				return
+			}
+			require.NotNil(t, result.Notification)
+			assert.Equal(t, "tid_test", result.Notification.PublishReference)
+			assert.Equal(t, "Title", result.Notification.Title)
+			dispatcher.AssertCalled(t, "Send", *result.Notification)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package consumer
+
+import (
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+)
+
+type messageProcessor interface {
+	Process(queueMsg kafka.FTMessage) Result
+}
+
+// MeteredHandler counts the consumed messages by the outcome of their processing
+type MeteredHandler struct {
+	processor messageProcessor
+	metrics   *metrics.Metrics
+}
+
+func NewMeteredHandler(processor messageProcessor, m *metrics.Metrics) *MeteredHandler {
+	return &MeteredHandler{
+		processor: processor,
+		metrics:   m,
+	}
+}
+
+// Process processes the message and counts its outcome
+func (h *MeteredHandler) Process(queueMsg kafka.FTMessage) Result {
+	result := h.processor.Process(queueMsg)
+	h.metrics.MessageProcessed(string(result.Outcome))
+	return result
+}
+
+// HandleMessage processes the message and counts its outcome
+func (h *MeteredHandler) HandleMessage(queueMsg kafka.FTMessage) {
+	h.Process(queueMsg)
+}
@@ -0,0 +1,43 @@
+package consumer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+)
+
+type processorFunc func(queueMsg kafka.FTMessage) Result
+
+func (f processorFunc) Process(queueMsg kafka.FTMessage) Result {
+	return f(queueMsg)
+}
+
+func TestMeteredHandler(t *testing.T) {
+	t.Parallel()
+
+	outcomes := []Outcome{OutcomeDispatched, OutcomeCarousel, OutcomeDispatched}
+	processed := 0
+	processor := processorFunc(func(_ kafka.FTMessage) Result {
+		outcome := outcomes[processed]
+		processed++
+		return Result{Outcome: outcome}
+	})
+
+	m := metrics.New()
+	handler := NewMeteredHandler(processor, m)
+	handler.HandleMessage(kafka.NewFTMessage(nil, ""))
+	assert.Equal(t, OutcomeCarousel, handler.Process(kafka.NewFTMessage(nil, "")).Outcome)
+	handler.HandleMessage(kafka.NewFTMessage(nil, ""))
+	require.Equal(t, len(outcomes), processed)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `notifications_push_kafka_messages_total{outcome="dispatched"} 2`)
+	assert.Contains(t, w.Body.String(), `notifications_push_kafka_messages_total{outcome="carousel"} 1`)
+}
@@ -0,0 +1,83 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+// PausableHandler holds the consumed messages while the consumption is paused.
+// A held message is not acknowledged, so the consumer group offsets are not committed past it
+// and the following messages stay in Kafka until the consumption is resumed.
+type PausableHandler struct {
+	handler MessageQueueHandler
+	mutex   *sync.Mutex
+	resumed chan struct{}
+	since   time.Time
+	closed  bool
+}
+
+func NewPausableHandler(handler MessageQueueHandler) *PausableHandler {
+	resumed := make(chan struct{})
+	close(resumed)
+	return &PausableHandler{
+		handler: handler,
+		mutex:   &sync.Mutex{},
+		resumed: resumed,
+	}
+}
+
+// HandleMessage waits for the consumption to be resumed and passes the message to the wrapped handler
+func (p *PausableHandler) HandleMessage(queueMsg kafka.FTMessage) {
+	p.mutex.Lock()
+	resumed := p.resumed
+	p.mutex.Unlock()
+
+	<-resumed
+	p.handler.HandleMessage(queueMsg)
+}
+
+// Pause holds the messages consumed from now on. It returns false if the consumption was already paused.
+func (p *PausableHandler) Pause() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed || !p.since.IsZero() {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	p.since = time.Now()
+	return true
+}
+
+// Resume releases the held message and continues the consumption. It returns false if it was not paused.
+func (p *PausableHandler) Resume() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.since.IsZero() {
+		return false
+	}
+	close(p.resumed)
+	p.since = time.Time{}
+	return true
+}
+
+// Paused returns whether the consumption is paused and since when
+func (p *PausableHandler) Paused() (bool, time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return !p.since.IsZero(), p.since
+}
+
+// Close resumes the consumption for good, so the consumer can be closed without waiting on a held message
+func (p *PausableHandler) Close() error {
+	p.Resume()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	return nil
+}
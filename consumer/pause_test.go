@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+type handlerFunc func(queueMsg kafka.FTMessage)
+
+func (f handlerFunc) HandleMessage(queueMsg kafka.FTMessage) {
+	f(queueMsg)
+}
+
+func TestPausableHandler(t *testing.T) {
+	t.Parallel()
+
+	handled := make(chan string, 1)
+	p := NewPausableHandler(handlerFunc(func(queueMsg kafka.FTMessage) {
+		handled <- queueMsg.Body
+	}))
+
+	p.HandleMessage(kafka.NewFTMessage(nil, "first"))
+	assert.Equal(t, "first", <-handled)
+
+	assert.True(t, p.Pause())
+	assert.False(t, p.Pause(), "Pausing twice should not change the state")
+	paused, since := p.Paused()
+	assert.True(t, paused)
+	assert.False(t, since.IsZero())
+
+	go p.HandleMessage(kafka.NewFTMessage(nil, "second"))
+	select {
+	case <-handled:
+		t.Fatal("The message should be held while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.True(t, p.Resume())
+	assert.False(t, p.Resume(), "Resuming twice should not change the state")
+	select {
+	case body := <-handled:
+		assert.Equal(t, "second", body)
+	case <-time.After(time.Second):
+		t.Fatal("The held message should be handled once resumed")
+	}
+
+	paused, _ = p.Paused()
+	assert.False(t, paused)
+}
+
+func TestPausableHandlerClose(t *testing.T) {
+	t.Parallel()
+
+	handled := make(chan string, 1)
+	p := NewPausableHandler(handlerFunc(func(queueMsg kafka.FTMessage) {
+		handled <- queueMsg.Body
+	}))
+
+	assert.True(t, p.Pause())
+	go p.HandleMessage(kafka.NewFTMessage(nil, "held"))
+
+	assert.NoError(t, p.Close())
+	select {
+	case body := <-handled:
+		assert.Equal(t, "held", body)
+	case <-time.After(time.Second):
+		t.Fatal("Closing should release the held message")
+	}
+	assert.False(t, p.Pause(), "A closed handler should not be paused again")
+}
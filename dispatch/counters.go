@@ -0,0 +1,128 @@
+package dispatch
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// SubscriberCounters tracks what happened to the notifications of a single subscriber.
+// The dispatcher counts the notifications skipped, dropped or failed for the subscriber,
+// the subscriber stream counts the frames actually written to the connection.
+// All methods are safe to call on a nil value, which counts nothing.
+type SubscriberCounters struct {
+	mutex            *sync.Mutex
+	sent             uint64
+	skipped          map[string]uint64
+	lagging          uint64
+	failed           uint64
+	acknowledged     uint64
+	bytesWritten     uint64
+	lastNotification time.Time
+	lastHeartbeat    time.Time
+}
+
+// CountersSnapshot is a copy of the subscriber counters at a point in time
+type CountersSnapshot struct {
+	Sent             uint64
+	Skipped          map[string]uint64
+	Lagging          uint64
+	Failed           uint64
+	Acknowledged     uint64
+	BytesWritten     uint64
+	LastNotification time.Time
+	LastHeartbeat    time.Time
+}
+
+func newSubscriberCounters() *SubscriberCounters {
+	return &SubscriberCounters{
+		mutex:   &sync.Mutex{},
+		skipped: map[string]uint64{},
+	}
+}
+
+// RecordWrite counts a frame written to the subscriber connection, either a heartbeat or a notification
+func (c *SubscriberCounters) RecordWrite(bytes int, heartbeat bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.bytesWritten += uint64(bytes)
+	if heartbeat {
+		c.lastHeartbeat = time.Now()
+		return
+	}
+	c.sent++
+	c.lastNotification = time.Now()
+}
+
+// RecordAck counts a notification acknowledged by the subscriber, for the transports supporting it
+func (c *SubscriberCounters) RecordAck() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.acknowledged++
+}
+
+// RecordSkip counts a notification the subscriber stream did not write, because it does not match the stream filters
+func (c *SubscriberCounters) RecordSkip(reason string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.skipped[reason]++
+}
+
+// recordOutcome counts the notifications the dispatcher did not pass to the subscriber.
+// The ones it did are counted once they are written to the connection.
+func (c *SubscriberCounters) recordOutcome(outcome string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch {
+	case outcome == OutcomeLagging:
+		c.lagging++
+	case outcome == OutcomeFailed:
+		c.failed++
+	case strings.HasPrefix(outcome, "skipped-"):
+		c.skipped[strings.TrimPrefix(outcome, "skipped-")]++
+	}
+}
+
+// Snapshot returns a copy of the counters
+func (c *SubscriberCounters) Snapshot() CountersSnapshot {
+	if c == nil {
+		return CountersSnapshot{Skipped: map[string]uint64{}}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	skipped := make(map[string]uint64, len(c.skipped))
+	for reason, count := range c.skipped {
+		skipped[reason] = count
+	}
+	return CountersSnapshot{
+		Sent:             c.sent,
+		Skipped:          skipped,
+		Lagging:          c.lagging,
+		Failed:           c.failed,
+		Acknowledged:     c.acknowledged,
+		BytesWritten:     c.bytesWritten,
+		LastNotification: c.lastNotification,
+		LastHeartbeat:    c.lastHeartbeat,
+	}
+}
+
+// recordOutcome adds the outcome of the notification for the subscriber to the delivery trace and to the subscriber counters
+func recordOutcome(trace *DeliveryTrace, s Subscriber, outcome string, err error) {
+	trace.addOutcome(s, outcome, err)
+	s.Counters().recordOutcome(outcome)
+}
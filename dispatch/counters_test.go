@@ -0,0 +1,87 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func TestSubscriberCounters(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+
+	options := &access.NotificationSubscriptionOptions{ReceiveAdvancedNotifications: true}
+	article, _ := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+	lagging, _ := d.Subscribe("192.168.1.3", []string{ArticleContentType}, false, options)
+	for i := 0; i < notificationBuffer; i++ {
+		require.NoError(t, lagging.(NotificationConsumer).Send(NotificationResponse{}))
+	}
+
+	go d.Start()
+	defer d.Stop()
+
+	d.Send(NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_article",
+		SubscriptionType: ArticleContentType,
+	})
+	d.Send(NotificationModel{
+		ID:               "http://www.ft.com/thing/c1b1b4a2-8d5b-4a8f-9f2b-5d8a3a1b3c4d",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_audio",
+		SubscriptionType: AudioContentType,
+	})
+
+	require.Eventually(t, func() bool {
+		return article.Counters().Snapshot().Skipped["type"] == 1 && lagging.Counters().Snapshot().Lagging == 1
+	}, time.Second, 10*time.Millisecond)
+
+	msg := <-article.Notifications()
+	assert.Zero(t, article.Counters().Snapshot().Sent, "Notifications should be counted once written to the connection")
+	article.Counters().RecordWrite(len(msg), false)
+	article.Counters().RecordWrite(2, true)
+	article.Counters().RecordSkip("filter")
+
+	counters := article.Counters().Snapshot()
+	assert.Equal(t, uint64(1), counters.Sent)
+	assert.Equal(t, map[string]uint64{"type": 1, "filter": 1}, counters.Skipped)
+	assert.Equal(t, uint64(len(msg)+2), counters.BytesWritten)
+	assert.False(t, counters.LastNotification.IsZero())
+	assert.False(t, counters.LastHeartbeat.IsZero())
+
+	payload := map[string]interface{}{}
+	raw, err := json.Marshal(lagging)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &payload))
+	assert.Equal(t, float64(1), payload["notificationsDroppedLagging"])
+	assert.Equal(t, map[string]interface{}{"type": float64(1)}, payload["notificationsSkipped"])
+	assert.Equal(t, float64(notificationBuffer), payload["bufferOccupancy"])
+	assert.Equal(t, false, payload["monitor"])
+	assert.Equal(t, []interface{}{ArticleContentType}, payload["subscriptionTypes"])
+	assert.Equal(t, map[string]interface{}{
+		"receiveAdvancedNotifications": true,
+		"receiveInternalUnstable":      false,
+	}, payload["options"])
+	assert.NotContains(t, payload, "lastNotification")
+}
+
+func TestNilSubscriberCounters(t *testing.T) {
+	t.Parallel()
+
+	var c *SubscriberCounters
+	c.RecordWrite(10, false)
+	c.recordOutcome(OutcomeLagging)
+	c.RecordSkip("filter")
+	c.RecordAck()
+	assert.Equal(t, CountersSnapshot{Skipped: map[string]uint64{}}, c.Snapshot())
+}
@@ -1,13 +1,20 @@
 package dispatch
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/Financial-Times/notifications-push/v5/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -17,55 +24,157 @@ const (
 // NewDispatcher creates and returns a new Dispatcher
 // Delay argument configures minimum delay between send notifications
 // History is a system that collects a list of all notifications send by Dispatcher
-func NewDispatcher(delay time.Duration, history History, opaAgent access.Agent, log *logger.UPPLogger) *Dispatcher {
+// Traces keeps the delivery decisions taken for the last notifications, it can be nil
+// Metrics records the policy evaluations and the fan-out durations, it can be nil
+func NewDispatcher(delay time.Duration, history History, traces *TraceStore, opaAgent access.Agent, m *metrics.Metrics, log *logger.UPPLogger) *Dispatcher {
 	return &Dispatcher{
-		delay:       delay,
-		inbound:     make(chan NotificationModel),
-		subscribers: map[NotificationConsumer]struct{}{},
-		lock:        &sync.RWMutex{},
-		history:     history,
-		opaAgent:    opaAgent,
-		stopChan:    make(chan bool),
-		log:         log,
+		delay:          delay,
+		inbound:        make(chan delivery),
+		subscribers:    map[NotificationConsumer]struct{}{},
+		probes:         map[NotificationConsumer]struct{}{},
+		lock:           &sync.RWMutex{},
+		history:        history,
+		historyUpdates: newBroadcast(),
+		traces:         traces,
+		watchers:       map[*TraceWatcher]struct{}{},
+		watchLock:      &sync.Mutex{},
+		freeze:         newFreezeState(),
+		latency:        newLatencyTracker(LatencyWindow, latencySamples),
+		volume:         newVolumeCounter(VolumeWindow),
+		opaAgent:       opaAgent,
+		metrics:        m,
+		stopChan:       make(chan bool),
+		log:            log,
 	}
 }
 
 type Dispatcher struct {
-	delay       time.Duration
-	inbound     chan NotificationModel
-	subscribers map[NotificationConsumer]struct{}
-	lock        *sync.RWMutex
-	history     History
-	opaAgent    access.Agent
-	stopChan    chan bool
-	log         *logger.UPPLogger
+	delay          time.Duration
+	inbound        chan delivery
+	subscribers    map[NotificationConsumer]struct{}
+	probes         map[NotificationConsumer]struct{}
+	lock           *sync.RWMutex
+	history        History
+	historyUpdates *broadcast
+	traces         *TraceStore
+	watchers       map[*TraceWatcher]struct{}
+	watchLock      *sync.Mutex
+	freeze         *freezeState
+	pending        int64
+	latency        *latencyTracker
+	volume         *volumeCounter
+	opaAgent       access.Agent
+	metrics        *metrics.Metrics
+	stopChan       chan bool
+	log            *logger.UPPLogger
 }
 
 func (d *Dispatcher) Start() {
 	for {
+		// while frozen the delayed notifications are held until the dispatcher is unfrozen
+		inbound := d.inbound
+		if frozen, _ := d.Frozen(); frozen {
+			inbound = nil
+		}
 		select {
-		case notification := <-d.inbound:
-			d.forwardToSubscribers(notification)
-			d.history.Push(notification)
+		case dl := <-inbound:
+			d.forwardToSubscribers(dl.notification, dl.trace, dl.target)
+			if dl.isNew() {
+				d.history.Push(withPolicyDecision(dl.notification, dl.trace))
+				d.historyUpdates.notify()
+			}
+			atomic.AddInt64(&d.pending, -1)
+		case <-d.freeze.changed:
 		case <-d.stopChan:
 			return
 		}
 	}
 }
 
+// Stop ends the dispatching, the notifications still waiting for their delay or held while frozen are discarded
 func (d *Dispatcher) Stop() {
-	d.stopChan <- true
+	close(d.stopChan)
+}
+
+// deliver hands the delivery over to the dispatching loop, unless the dispatcher is stopped
+func (d *Dispatcher) deliver(dl delivery) {
+	select {
+	case d.inbound <- dl:
+	case <-d.stopChan:
+	}
 }
 
 func (d *Dispatcher) Send(n NotificationModel) {
 	d.log.WithTransactionID(n.PublishReference).Infof("Received notification. Waiting configured delay (%v).", d.delay)
+	trace := newDeliveryTrace(n, time.Now())
+	d.traces.record(trace)
+	atomic.AddInt64(&d.pending, 1)
 	go func() {
+		_, span := tracing.Tracer().Start(tracing.ContextWith(n.SpanContext), "DelayWait",
+			oteltrace.WithAttributes(attribute.String("delay", d.delay.String())))
 		time.Sleep(d.delay)
-		n.NotificationDate = time.Now().Format(RFC3339Millis)
-		d.inbound <- n
+		span.End()
+		d.deliver(newDelivery(n, trace, nil))
 	}()
 }
 
+// Traces returns the delivery traces of the notifications with the given transaction ID
+func (d *Dispatcher) Traces(tid string) []DeliveryTrace {
+	return d.traces.Traces(tid)
+}
+
+// RecentTraces returns the delivery traces of the last notifications, most recent first, at most limit of them
+func (d *Dispatcher) RecentTraces(limit int) []DeliveryTrace {
+	return d.traces.Recent(limit)
+}
+
+// WatchTraces registers a watcher receiving the delivery trace of every notification once it is forwarded
+func (d *Dispatcher) WatchTraces() *TraceWatcher {
+	w := &TraceWatcher{traces: make(chan DeliveryTrace, traceWatcherBuffer)}
+
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	d.watchers[w] = struct{}{}
+	return w
+}
+
+// UnwatchTraces removes a watcher registered with WatchTraces
+func (d *Dispatcher) UnwatchTraces(w *TraceWatcher) {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	delete(d.watchers, w)
+}
+
+func (d *Dispatcher) notifyWatchers(t DeliveryTrace) {
+	d.watchLock.Lock()
+	defer d.watchLock.Unlock()
+	for w := range d.watchers {
+		w.send(t)
+	}
+}
+
+// delivery is a notification waiting to be forwarded together with its trace
+type delivery struct {
+	notification NotificationModel
+	trace        *DeliveryTrace
+	target       *Target
+}
+
+// newDelivery dates a notification whose delay has ended
+func newDelivery(n NotificationModel, trace *DeliveryTrace, target *Target) delivery {
+	now := time.Now()
+	n.NotificationDate = now.Format(RFC3339Millis)
+	trace.DelayEndedAt = timestamp(now)
+	trace.Notification.NotificationDate = n.NotificationDate
+	return delivery{notification: n, trace: trace, target: target}
+}
+
+// isNew reports whether the delivery is a notification received for the first time, which is added to the history.
+// The probes and the re-dispatched notifications are not, the latter being already in it.
+func (dl delivery) isNew() bool {
+	return !dl.trace.probe && dl.target == nil && !dl.notification.Redelivered
+}
+
 func (d *Dispatcher) Subscribers() []Subscriber {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
@@ -95,6 +204,22 @@ func (d *Dispatcher) Subscribe(address string, subTypes []string, monitoring boo
 	return s, nil
 }
 
+// SubscribeProbes registers a monitor subscriber which only receives the probes targeted at it.
+// It is left out of the subscribers, so it is neither listed nor counted in the dispatcher saturation.
+func (d *Dispatcher) SubscribeProbes(address string, subTypes []string, options *access.NotificationSubscriptionOptions) (Subscriber, error) {
+	s, err := NewMonitorSubscriber(address, subTypes, options)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.probes[s] = struct{}{}
+	logWithSubscriber(d.log, s).Info("Registered new probe subscriber")
+	return s, nil
+}
+
 func (d *Dispatcher) Unsubscribe(subscriber Subscriber) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -102,6 +227,7 @@ func (d *Dispatcher) Unsubscribe(subscriber Subscriber) {
 	s := subscriber.(NotificationConsumer)
 
 	delete(d.subscribers, s)
+	delete(d.probes, s)
 
 	logWithSubscriber(d.log, s).Info("Unregistered subscriber")
 }
@@ -124,12 +250,44 @@ func logWithSubscriber(log *logger.UPPLogger, s Subscriber) *logger.LogEntry {
 	})
 }
 
-func (d *Dispatcher) forwardToSubscribers(notification NotificationModel) {
+// forwardToSubscribers sends the notification to the subscribers matching the target, or to all of them when it is nil
+func (d *Dispatcher) forwardToSubscribers(notification NotificationModel, trace *DeliveryTrace, target *Target) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
+	fanOutStart := time.Now()
+	trace.FanOutStartedAt = timestamp(fanOutStart)
+	ctx, span := tracing.Tracer().Start(tracing.ContextWith(notification.SpanContext), "FanOut",
+		oteltrace.WithAttributes(tracing.TransactionIDKey.String(notification.PublishReference)))
+	// the writes to the subscriber streams are traced as children of the fan-out
+	notification.SpanContext = span.SpanContext()
 	var sent, failed, skipped int
 	defer func() {
+		fanOutEnd := time.Now()
+		trace.FanOutEndedAt = timestamp(fanOutEnd)
+		d.metrics.FanOutCompleted(fanOutEnd.Sub(fanOutStart))
+		d.latency.record(notification, trace, target != nil)
+		span.SetAttributes(
+			attribute.Int("sent", sent),
+			attribute.Int("failed", failed),
+			attribute.Int("skipped", skipped))
+		if trace.Error != "" {
+			span.SetStatus(codes.Error, trace.Error)
+		}
+		span.End()
+		if trace.probe {
+			d.log.WithTransactionID(notification.PublishReference).
+				WithField("sent", sent).
+				Debug("Processed probe subscribers.")
+			return
+		}
+		d.traces.record(trace)
+		d.notifyWatchers(*trace)
+		// the re-dispatched notifications were already counted when first sent
+		if target == nil && !notification.Redelivered {
+			d.volume.record(notification, trace, fanOutEnd)
+		}
+
 		entry := d.log.
 			WithTransactionID(notification.PublishReference).
 			WithFields(map[string]interface{}{
@@ -138,7 +296,7 @@ func (d *Dispatcher) forwardToSubscribers(notification NotificationModel) {
 				"failed":   failed,
 				"skipped":  skipped,
 			})
-		if len(d.subscribers) == 0 || sent > 0 || len(d.subscribers) == skipped {
+		if trace.Error == "" && (sent > 0 || failed == 0) {
 			entry.WithMonitoringEvent("NotificationsPush", notification.PublishReference, notification.SubscriptionType).
 				Info("Processed subscribers.")
 		} else {
@@ -155,64 +313,112 @@ func (d *Dispatcher) forwardToSubscribers(notification NotificationModel) {
 				WithField("resource", notification.APIURL).
 				WithError(pubErr).
 				Warn("Failed to evaluate notification")
+			trace.Error = pubErr.Error()
 			return
 		}
 		publication = pu
 	}
+	_, policySpan := tracing.Tracer().Start(ctx, "EvaluateContentPolicy")
 	evaluationResult, err := d.opaAgent.EvaluateContentPolicy(map[string]interface{}{
 		"EditorialDesk": notification.EditorialDesk,
 		"Publication":   publication,
 	})
+	if err != nil {
+		policySpan.RecordError(err)
+		policySpan.SetStatus(codes.Error, err.Error())
+	} else {
+		policySpan.SetAttributes(
+			attribute.Bool("allow", evaluationResult.Allow),
+			attribute.String("decision_id", evaluationResult.DecisionID))
+	}
+	policySpan.End()
 	if err != nil {
 		d.log.
 			WithTransactionID(notification.PublishReference).
 			WithField("resource", notification.APIURL).
 			WithError(err).
 			Warn("Failed to evaluate OPA notifications-push policy")
+		trace.Error = err.Error()
+		d.metrics.PolicyEvaluated(metrics.PolicyError)
 		return
 	}
 	hasAccess := evaluationResult.Allow
+	if hasAccess {
+		d.metrics.PolicyEvaluated(metrics.PolicyAllow)
+	} else {
+		d.metrics.PolicyEvaluated(metrics.PolicyDeny)
+	}
+	trace.Policy = &PolicyDecision{
+		Allow:      evaluationResult.Allow,
+		Reasons:    evaluationResult.Reasons,
+		DecisionID: evaluationResult.DecisionID,
+	}
 
-	isRelatedContent := notification.Type == RelatedContentType
-	for sub := range d.subscribers {
+	subscribers := d.subscribers
+	if trace.probe {
+		subscribers = d.probes
+	}
+	for sub := range subscribers {
+		if !target.Matches(sub) {
+			continue
+		}
 		entry := logWithSubscriber(d.log, sub).
 			WithTransactionID(notification.PublishReference).
 			WithField("resource", notification.APIURL)
 
-		if notification.IsE2ETest {
-			if _, isStandard := sub.(*StandardSubscriber); isStandard {
-				skipped++
+		if outcome := skipOutcome(notification, sub, hasAccess); outcome != "" {
+			skipped++
+			recordOutcome(trace, sub, outcome, nil)
+			switch outcome {
+			case OutcomeSkippedE2E:
 				entry.Info("Test notification. Skipping standard subscriber.")
-				continue
-			}
-		} else {
-			if !matchesSubType(notification, sub) {
-				skipped++
+			case OutcomeSkippedType:
 				entry.Info("Skipping subscriber due to subscription type mismatch.")
-				continue
-			}
-			if !hasAccess {
-				skipped++
+			case OutcomeSkippedPolicy:
 				entry.Info("Skipping subscriber due to ", strings.Join(evaluationResult.Reasons[:], ", "))
-				continue
-			}
-			if isRelatedContent && !sub.Options().ReceiveInternalUnstable {
-				skipped++
+			default:
 				entry.Info("Skipping subscriber due to RELATEDCONTENТ notification, without policy InternalUnstable.")
-				continue
 			}
+			continue
 		}
 		nr := CreateNotificationResponse(notification, sub.Options())
 		if err = sub.Send(nr); err != nil {
 			failed++
+			outcome := OutcomeFailed
+			if errors.Is(err, ErrSubLagging) {
+				outcome = OutcomeLagging
+			}
+			recordOutcome(trace, sub, outcome, err)
 			entry.WithError(err).Warn("Failed forwarding to subscriber.")
 		} else {
 			sent++
+			recordOutcome(trace, sub, OutcomeSent, nil)
 			entry.Info("Forwarding to subscriber.")
 		}
 	}
 }
 
+// skipOutcome returns the outcome of a notification the subscriber is not sent, empty when it is sent.
+// The test notifications are only sent to the monitor subscribers, whatever their subscription types and the content policy.
+func skipOutcome(n NotificationModel, s Subscriber, hasAccess bool) string {
+	if n.IsE2ETest {
+		if _, isStandard := s.(*StandardSubscriber); isStandard {
+			return OutcomeSkippedE2E
+		}
+		return ""
+	}
+	if !matchesSubType(n, s) {
+		return OutcomeSkippedType
+	}
+	if !hasAccess {
+		return OutcomeSkippedPolicy
+	}
+	if n.Type == RelatedContentType && !s.Options().ReceiveInternalUnstable {
+		return OutcomeSkippedInternalUnstable
+	}
+	return ""
+}
+
 // matchesSubType matches subscriber's ContentType with the incoming contentType notification.
 func matchesSubType(n NotificationModel, s Subscriber) bool {
 	subTypes := make(map[string]bool)
@@ -5,8 +5,10 @@ package dispatch
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -186,9 +188,9 @@ func TestShouldDispatchNotificationsToMultipleSubscribers(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 
 	m, _ := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
@@ -227,10 +229,10 @@ func TestFailToSendNotificationToBlockedPublicationOrEditorialDesk(t *testing.T)
 	hook := hooks.NewLocal(l.Logger)
 	defer hook.Reset()
 
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 	_, err := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
 	})
@@ -283,11 +285,11 @@ func TestShouldDispatchNotificationsToSubscribersByType(t *testing.T) {
 	hook := hooks.NewLocal(l.Logger)
 	defer hook.Reset()
 
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 
 	m, _ := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
@@ -356,10 +358,10 @@ func TestShouldDispatchE2ETestNotificationsToMonitoringSubscribersOnly(t *testin
 	//t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(time.Millisecond, h, oa, l)
+	d := NewDispatcher(time.Millisecond, h, nil, oa, nil, l)
 
 	m, _ := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
@@ -391,10 +393,10 @@ func TestCreateNotificationIsProperlyDispatchedToSubscribers(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(time.Millisecond, h, oa, l)
+	d := NewDispatcher(time.Millisecond, h, nil, oa, nil, l)
 
 	m1, _ := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: true,
@@ -444,11 +446,11 @@ func TestAddAndRemoveOfSubscribers(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 
 	m, _ := d.Subscribe("192.168.1.2", contentSubscribeTypes, true, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
@@ -483,10 +485,10 @@ func TestDispatchDelay(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 
 	oa := access.GetOPAAgentForTesting(l)
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 
 	s, _ := d.Subscribe("192.168.1.3", contentSubscribeTypes, false, &access.NotificationSubscriptionOptions{
 		ReceiveAdvancedNotifications: false,
@@ -512,10 +514,10 @@ func TestDispatchedNotificationsInHistory(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(delay, h, oa, l)
+	d := NewDispatcher(delay, h, nil, oa, nil, l)
 
 	go d.Start()
 	defer d.Stop()
@@ -550,10 +552,10 @@ func TestInternalFailToSendNotifications(t *testing.T) {
 	hook := hooks.NewLocal(l.Logger)
 	defer hook.Reset()
 
-	h := NewHistory(historySize)
+	h := NewHistory(historySize, OrderByLastModified)
 	oa := access.GetOPAAgentForTesting(l)
 
-	d := NewDispatcher(0, h, oa, l)
+	d := NewDispatcher(0, h, nil, oa, nil, l)
 
 	s1 := &MockSubscriber{}
 	s2 := &MockSubscriber{}
@@ -587,6 +589,34 @@ func TestInternalFailToSendNotifications(t *testing.T) {
 	assert.Equal(t, 1, logOccurrence)
 }
 
+func TestPolicyEvaluationFailureLogged(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "info")
+	l.Out = io.Discard
+	hook := hooks.NewLocal(l.Logger)
+	defer hook.Reset()
+
+	d := NewDispatcher(0, NewHistory(historySize, OrderByLastModified), nil, &stubAgent{err: errors.New("opa unavailable")}, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	_, err := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	d.Send(n1)
+	require.Eventually(t, func() bool { return d.Pending() == 0 }, time.Second, 10*time.Millisecond)
+
+	var messages []string
+	for _, e := range hook.AllEntries() {
+		if strings.HasPrefix(e.Message, "Processed subscribers.") {
+			messages = append(messages, e.Message)
+		}
+	}
+	assert.Equal(t, []string{"Processed subscribers. Failed to send notifications"}, messages,
+		"A notification dropped by a failed policy evaluation should not be reported as pushed")
+}
+
 func verifyNotificationResponse(t *testing.T, expected NotificationModel, notBefore time.Time, notAfter time.Time, actualMsg string) {
 	actualNotifications := []NotificationResponse{}
 	_ = json.Unmarshal([]byte(actualMsg), &actualNotifications)
@@ -732,6 +762,11 @@ func (_m *MockSubscriber) Since() time.Time {
 	return time.Now()
 }
 
+// Counters provides a mock function with given fields:
+func (_m *MockSubscriber) Counters() *SubscriberCounters {
+	return nil
+}
+
 func waitForNotification(notificationsCh <-chan string, timeout time.Duration) (string, error) {
 	ticker := time.NewTicker(timeout / 10)
 	defer ticker.Stop()
@@ -0,0 +1,136 @@
+package dispatch
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of a pull consumer in the history, after the last notification it read.
+// The notifications are positioned by their lastModified date, and by ID when modified at the same time,
+// so a cursor gives the same position on every instance of the service and after a restart.
+// The notifications without a valid lastModified date are positioned by their reception time instead.
+type Cursor struct {
+	lastModified time.Time
+	id           string
+}
+
+// ParseCursor decodes a cursor returned by String, the empty string being the cursor before the oldest notification
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	lastModified, id, found := strings.Cut(string(decoded), ".")
+	if !found {
+		return Cursor{}, errInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(lastModified, 10, 64)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	return Cursor{lastModified: time.Unix(0, nanos).UTC(), id: id}, nil
+}
+
+// String encodes the cursor as an opaque URL safe token
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%s", c.lastModified.UnixNano(), c.id)))
+}
+
+// IsZero reports whether the cursor is before the oldest notification
+func (c Cursor) IsZero() bool {
+	return c.lastModified.IsZero() && c.id == ""
+}
+
+// before reports whether the cursor is positioned before the other one
+func (c Cursor) before(other Cursor) bool {
+	if !c.lastModified.Equal(other.lastModified) {
+		return c.lastModified.Before(other.lastModified)
+	}
+	return c.id < other.id
+}
+
+// cursorAfter returns the position of the notification, the cursor of a consumer which has read it
+func cursorAfter(n NotificationModel, received time.Time) Cursor {
+	lastModified, err := time.Parse(time.RFC3339Nano, n.LastModified)
+	if err != nil {
+		lastModified = received
+	}
+	return Cursor{lastModified: lastModified.UTC(), id: n.ID}
+}
+
+// broadcast wakes up the pull consumers waiting for a notification to be added to the history
+type broadcast struct {
+	mutex *sync.Mutex
+	ch    chan struct{}
+}
+
+func newBroadcast() *broadcast {
+	return &broadcast{mutex: &sync.Mutex{}, ch: make(chan struct{})}
+}
+
+func (b *broadcast) wait() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.ch
+}
+
+func (b *broadcast) notify() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	close(b.ch)
+	b.ch = make(chan struct{})
+}
+
+// Pull returns the notifications of the history following the cursor that a subscriber with the given subscription types,
+// kind and options was sent, in cursor order and at most limit of them, with the cursor to read the next ones from.
+// The notifications whose content policy could not be evaluated were not sent to anyone and are not returned.
+// Truncated tells that notifications following the cursor dropped out of the history before they were pulled.
+func (d *Dispatcher) Pull(cursor Cursor, subTypes []string, monitor bool, options *access.NotificationSubscriptionOptions, limit int) ([]NotificationResponse, Cursor, bool) {
+	var s Subscriber = &StandardSubscriber{acceptedTypes: subTypes, subscriberOptions: options}
+	if monitor {
+		s = &MonitorSubscriber{acceptedTypes: subTypes, subscriberOptions: options}
+	}
+	matching, next, truncated := d.history.After(cursor, limit, func(n NotificationModel) bool {
+		return n.ContentPolicyAllowed != nil && skipOutcome(n, s, *n.ContentPolicyAllowed) == ""
+	})
+
+	notifications := make([]NotificationResponse, 0, len(matching))
+	for _, n := range matching {
+		nr := CreateNotificationResponse(n, options)
+		if !monitor {
+			nr = standardView(nr)
+		}
+		notifications = append(notifications, nr)
+	}
+	return notifications, next, truncated
+}
+
+// HistoryUpdated returns a channel closed once the next notification is added to the history
+func (d *Dispatcher) HistoryUpdated() <-chan struct{} {
+	return d.historyUpdates.wait()
+}
+
+// withPolicyDecision keeps the content policy decision of the fan-out with the notification added to the history
+func withPolicyDecision(n NotificationModel, trace *DeliveryTrace) NotificationModel {
+	n.ContentPolicyAllowed = nil
+	if trace.Policy != nil {
+		allow := trace.Policy.Allow
+		n.ContentPolicyAllowed = &allow
+	}
+	return n
+}
@@ -0,0 +1,192 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func matchAll(NotificationModel) bool {
+	return true
+}
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	c := Cursor{lastModified: time.Unix(0, 1700000000123456789).UTC(), id: "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122"}
+	parsed, err := ParseCursor(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	zero, err := ParseCursor("")
+	require.NoError(t, err)
+	assert.True(t, zero.IsZero())
+	assert.Empty(t, zero.String())
+
+	for _, invalid := range []string{"not a cursor", "YWJj", "YS5iLmM"} {
+		_, err = ParseCursor(invalid)
+		assert.ErrorIs(t, err, errInvalidCursor, invalid)
+	}
+
+	n := NotificationModel{ID: "http://www.ft.com/thing/1", LastModified: "2024-07-31T10:30:15.123Z"}
+	received := time.Now()
+	assert.Equal(t, Cursor{lastModified: time.Date(2024, 7, 31, 10, 30, 15, 123000000, time.UTC), id: n.ID}, cursorAfter(n, received))
+	n.LastModified = ""
+	assert.True(t, cursorAfter(n, received).lastModified.Equal(received), "The reception time should position the notifications without lastModified")
+}
+
+func lastModifiedAt(id string, minute int) NotificationModel {
+	return NotificationModel{ID: id, LastModified: time.Date(2024, 7, 31, 10, minute, 0, 0, time.UTC).Format(time.RFC3339Nano)}
+}
+
+func ids(notifications []NotificationModel) []string {
+	result := []string{}
+	for _, n := range notifications {
+		result = append(result, n.ID)
+	}
+	return result
+}
+
+func TestHistoryAfter(t *testing.T) {
+	t.Parallel()
+
+	h := NewHistory(3, OrderByArrival)
+	h.Push(lastModifiedAt("1", 1))
+	h.Push(lastModifiedAt("3", 3))
+	h.Push(lastModifiedAt("2", 2))
+	h.Push(lastModifiedAt("4", 4))
+
+	page, next, truncated := h.After(Cursor{}, 2, matchAll)
+	assert.Equal(t, []string{"2", "3"}, ids(page), "The retained notifications should be read by lastModified")
+	assert.False(t, truncated, "Reading from the oldest retained notification should not be truncated")
+	page, next, _ = h.After(next, 2, matchAll)
+	assert.Equal(t, []string{"4"}, ids(page))
+	last := next
+	page, next, _ = h.After(next, 2, matchAll)
+	assert.Empty(t, page)
+	assert.Equal(t, last, next, "The cursor should stay at the last notification")
+
+	h.Push(lastModifiedAt("6", 5))
+	h.Push(lastModifiedAt("5", 5))
+	page, next, truncated = h.After(next, 1, func(n NotificationModel) bool { return n.ID == "6" })
+	assert.Equal(t, []string{"6"}, ids(page), "The notifications not matching should be skipped, and those modified at the same time ordered by ID")
+	assert.False(t, truncated, "The notifications dropped out of the history were already read")
+	page, _, _ = h.After(next, 1, matchAll)
+	assert.Empty(t, page)
+
+	// the cursor of another instance, which received the notifications in another order, gives the same position
+	other := NewHistory(10, OrderByArrival)
+	other.Push(lastModifiedAt("4", 4))
+	other.Push(lastModifiedAt("3", 3))
+	_, foreign, _ := other.After(Cursor{}, 10, matchAll)
+	page, _, _ = h.After(foreign, 10, matchAll)
+	assert.Equal(t, []string{"5", "6"}, ids(page))
+
+	// the notifications 2 and 3 following the cursor dropped out of the history
+	page, _, truncated = h.After(cursorAfter(lastModifiedAt("1", 1), time.Time{}), 10, matchAll)
+	assert.Equal(t, []string{"4", "5", "6"}, ids(page))
+	assert.True(t, truncated)
+}
+
+func TestHistoryRingAfterExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	r := newHistoryRing(10)
+	for i, id := range []string{"1", "2", "3"} {
+		n := lastModifiedAt(id, i)
+		r.push(newHistoryEntry(n, uint64(i), now.Add(time.Duration(i-2)*time.Hour), OrderByArrival))
+	}
+
+	page, _, truncated := r.after(Cursor{}, now.Add(-90*time.Minute), 10, matchAll)
+	assert.Equal(t, []string{"2", "3"}, ids(page))
+	assert.False(t, truncated)
+
+	page, _, truncated = r.after(cursorAfter(lastModifiedAt("0", 0), time.Time{}), now.Add(-90*time.Minute), 10, matchAll)
+	assert.Equal(t, []string{"2", "3"}, ids(page))
+	assert.True(t, truncated, "The expired notifications following the cursor should truncate the result")
+}
+
+func TestJournalHistoryAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	dir := t.TempDir()
+	j, err := NewJournalHistory(dir, 10, 0, OrderByArrival, l)
+	require.NoError(t, err)
+	j.Push(lastModifiedAt("1", 1))
+	j.Push(NotificationModel{ID: "2"})
+	_, cursor, _ := j.After(Cursor{}, 10, matchAll)
+	require.NoError(t, j.Close())
+
+	j, err = NewJournalHistory(dir, 10, 0, OrderByArrival, l)
+	require.NoError(t, err)
+	defer j.Close()
+	j.Push(NotificationModel{ID: "3"})
+
+	page, _, _ := j.After(cursor, 10, matchAll)
+	assert.Equal(t, []string{"3"}, ids(page), "The reception time of the journal should keep the position of the notifications without lastModified")
+}
+
+// deskAgent denies the notifications of the denied editorial desk
+type deskAgent struct{}
+
+func (deskAgent) EvaluateContentPolicy(q map[string]interface{}) (*access.ContentPolicyResult, error) {
+	return &access.ContentPolicyResult{Allow: q["EditorialDesk"] != "denied"}, nil
+}
+
+func TestDispatcherPull(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, deskAgent{}, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	send := func(n NotificationModel) {
+		updated := d.HistoryUpdated()
+		d.Send(n)
+		select {
+		case <-updated:
+		case <-time.After(time.Second):
+			t.Fatal("The history update should be signalled")
+		}
+	}
+	send(NotificationModel{
+		ID:               "http://www.ft.com/thing/1",
+		Type:             ContentCreateType,
+		PublishReference: "tid_article",
+		SubscriptionType: ArticleContentType,
+	})
+	send(NotificationModel{ID: "http://www.ft.com/thing/2", Type: ContentUpdateType, SubscriptionType: AudioContentType})
+	send(NotificationModel{ID: "http://www.ft.com/thing/3", Type: ContentUpdateType, SubscriptionType: ArticleContentType, IsE2ETest: true})
+	send(NotificationModel{ID: "http://www.ft.com/thing/4", Type: RelatedContentType, SubscriptionType: ArticleContentType})
+	send(NotificationModel{ID: "http://www.ft.com/thing/5", Type: ContentUpdateType, SubscriptionType: ArticleContentType, EditorialDesk: "denied"})
+
+	standard, cursor, _ := d.Pull(Cursor{}, []string{ArticleContentType}, false, &access.NotificationSubscriptionOptions{}, 10)
+	require.Len(t, standard, 1, "The other types, the test, internal unstable and denied notifications should be skipped")
+	assert.Equal(t, "http://www.ft.com/thing/1", standard[0].ID)
+	assert.Equal(t, ContentUpdateType, standard[0].Type, "CREATE events should be rendered as UPDATE without advanced notifications")
+	assert.Empty(t, standard[0].PublishReference)
+	assert.Empty(t, standard[0].NotificationDate)
+	assert.Equal(t, "http://www.ft.com/thing/5", cursor.id, "The cursor should be after the last notification read")
+
+	options := &access.NotificationSubscriptionOptions{ReceiveInternalUnstable: true}
+	monitor, _, _ := d.Pull(Cursor{}, []string{ArticleContentType}, true, options, 10)
+	require.Len(t, monitor, 3)
+	assert.Equal(t, "tid_article", monitor[0].PublishReference)
+	assert.NotEmpty(t, monitor[0].NotificationDate)
+	assert.Equal(t, "http://www.ft.com/thing/3", monitor[1].ID)
+	assert.Equal(t, "http://www.ft.com/thing/4", monitor[2].ID)
+
+	limited, next, _ := d.Pull(Cursor{}, []string{ArticleContentType}, true, options, 2)
+	assert.Len(t, limited, 2)
+	rest, _, _ := d.Pull(next, []string{ArticleContentType}, true, options, 2)
+	require.Len(t, rest, 1)
+	assert.Equal(t, "http://www.ft.com/thing/4", rest[0].ID)
+}
@@ -0,0 +1,66 @@
+package dispatch
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// freezeState tells whether the dispatcher holds the notifications instead of forwarding them
+type freezeState struct {
+	mutex   *sync.Mutex
+	since   time.Time
+	changed chan struct{}
+}
+
+func newFreezeState() *freezeState {
+	return &freezeState{
+		mutex:   &sync.Mutex{},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (f *freezeState) set(frozen bool) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if frozen == !f.since.IsZero() {
+		return false
+	}
+	if frozen {
+		f.since = time.Now()
+	} else {
+		f.since = time.Time{}
+	}
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Freeze stops forwarding notifications to the subscribers. The notifications keep being received
+// and are held, once their delay has ended, until the dispatcher is unfrozen.
+// It returns false if the dispatcher was already frozen.
+func (d *Dispatcher) Freeze() bool {
+	return d.freeze.set(true)
+}
+
+// Unfreeze forwards the held notifications and resumes the normal dispatching. It returns false if it was not frozen.
+func (d *Dispatcher) Unfreeze() bool {
+	return d.freeze.set(false)
+}
+
+// Frozen returns whether the dispatcher is frozen and since when
+func (d *Dispatcher) Frozen() (bool, time.Time) {
+	d.freeze.mutex.Lock()
+	defer d.freeze.mutex.Unlock()
+
+	return !d.freeze.since.IsZero(), d.freeze.since
+}
+
+// Pending returns the number of notifications received but not forwarded yet,
+// either waiting for their delay or held while the dispatcher is frozen
+func (d *Dispatcher) Pending() int {
+	return int(atomic.LoadInt64(&d.pending))
+}
@@ -0,0 +1,84 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func TestFreeze(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	s, _ := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+
+	assert.True(t, d.Freeze())
+	assert.False(t, d.Freeze(), "Freezing twice should not change the state")
+	frozen, since := d.Frozen()
+	assert.True(t, frozen)
+	assert.False(t, since.IsZero())
+
+	d.Send(NotificationModel{ID: "http://www.ft.com/thing/1", PublishReference: "tid_1", SubscriptionType: ArticleContentType})
+	d.Send(NotificationModel{ID: "http://www.ft.com/thing/2", PublishReference: "tid_2", SubscriptionType: ArticleContentType})
+
+	select {
+	case <-s.Notifications():
+		t.Fatal("Notifications should be held while frozen")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 2, d.Pending())
+
+	assert.True(t, d.Unfreeze())
+	assert.False(t, d.Unfreeze(), "Unfreezing twice should not change the state")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-s.Notifications():
+		case <-time.After(time.Second):
+			t.Fatal("The held notifications should be forwarded once unfrozen")
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return d.Pending() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStopReleasesHeldNotifications(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+	go d.Start()
+
+	assert.True(t, d.Freeze())
+	n := NotificationModel{ID: "http://www.ft.com/thing/1", PublishReference: "tid_1", SubscriptionType: ArticleContentType}
+	d.Send(n)
+	assert.Equal(t, 1, d.Pending())
+
+	done := make(chan struct{})
+	go func() {
+		d.deliver(newDelivery(n, newDeliveryTrace(n, time.Now()), nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("The delivery should be held while frozen")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	d.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("The held deliveries should be released once the dispatcher is stopped")
+	}
+}
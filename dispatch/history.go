@@ -1,6 +1,7 @@
 package dispatch
 
 import (
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -10,49 +11,256 @@ import (
 type History interface {
 	Push(notification NotificationModel)
 	Notifications() []NotificationModel
+	// After returns the notifications following the cursor and accepted by match, in cursor order and at most limit of them,
+	// with the cursor of the last notification read. Truncated tells that notifications following the cursor dropped out of the history.
+	After(cursor Cursor, limit int, match func(NotificationModel) bool) (notifications []NotificationModel, next Cursor, truncated bool)
+}
+
+// HistoryOrder is the key by which the history notifications are ordered, newest first.
+type HistoryOrder string
+
+const (
+	OrderByArrival          HistoryOrder = "arrival"
+	OrderByNotificationDate HistoryOrder = "notificationDate"
+	OrderByLastModified     HistoryOrder = "lastModified"
+)
+
+// ParseHistoryOrder returns the HistoryOrder matching s.
+func ParseHistoryOrder(s string) (HistoryOrder, error) {
+	switch o := HistoryOrder(s); o {
+	case OrderByArrival, OrderByNotificationDate, OrderByLastModified:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown history order %q", s)
+	}
 }
 
 type inMemoryHistory struct {
-	size          int
-	mutex         *sync.RWMutex
-	notifications []NotificationModel
+	mutex *sync.RWMutex
+	order HistoryOrder
+	ring  *historyRing
+	seq   uint64
 }
 
-// NewHistory creates a new history type
-func NewHistory(size int) History {
-	return &inMemoryHistory{size, &sync.RWMutex{}, []NotificationModel{}}
+// NewHistory creates a new history type keeping the last size notifications received, ordered by the given key.
+func NewHistory(size int, order HistoryOrder) History {
+	return &inMemoryHistory{
+		mutex: &sync.RWMutex{},
+		order: order,
+		ring:  newHistoryRing(size),
+	}
 }
 
 func (i *inMemoryHistory) Push(n NotificationModel) {
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 
-	i.notifications = append(i.notifications, n)
-	sort.Sort(sort.Reverse(byTimestamp(i.notifications)))
-
-	length := len(i.notifications)
-	if length > i.size {
-		i.notifications = i.notifications[:length-1] // remove the last entry
-	}
+	i.ring.push(newHistoryEntry(n, i.seq, time.Now(), i.order))
+	i.seq++
 }
 
 func (i *inMemoryHistory) Notifications() []NotificationModel {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
-	return i.notifications
+	return sortedNotifications(i.ring, time.Time{})
+}
+
+func (i *inMemoryHistory) After(cursor Cursor, limit int, match func(NotificationModel) bool) ([]NotificationModel, Cursor, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.ring.after(cursor, time.Time{}, limit, match)
 }
 
-type byTimestamp []NotificationModel
+// historyEntry is a notification together with its precomputed ordering key.
+type historyEntry struct {
+	notification NotificationModel
+	seq          uint64
+	received     time.Time
+	cursor       Cursor
+	key          time.Time
+	hasKey       bool
+}
+
+func newHistoryEntry(n NotificationModel, seq uint64, received time.Time, order HistoryOrder) historyEntry {
+	e := historyEntry{notification: n, seq: seq, received: received, cursor: cursorAfter(n, received)}
+
+	var ts string
+	switch order {
+	case OrderByNotificationDate:
+		ts = n.NotificationDate
+	case OrderByLastModified:
+		ts = n.LastModified
+	default:
+		return e
+	}
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		e.key = t
+		e.hasKey = true
+	}
+
+	return e
+}
 
-func (notifications byTimestamp) Len() int { return len(notifications) }
+// historyRing is a fixed size circular buffer of history entries in arrival order.
+// The entries sorted by their ordering key and by their cursor are cached until the ring changes.
+// Dropped is the furthest cursor of the entries which dropped out of the ring.
+type historyRing struct {
+	entries     []historyEntry
+	dropped     Cursor
+	start       int
+	count       int
+	sortLock    sync.Mutex
+	sortCache   []historyEntry
+	cursorCache []historyEntry
+}
 
-func (notifications byTimestamp) Swap(i, j int) {
-	notifications[i], notifications[j] = notifications[j], notifications[i]
+func newHistoryRing(size int) *historyRing {
+	if size < 0 {
+		size = 0
+	}
+	return &historyRing{entries: make([]historyEntry, size)}
 }
 
-func (notifications byTimestamp) Less(i, j int) bool {
-	ti, _ := time.Parse(time.RFC3339Nano, notifications[i].LastModified)
-	tj, _ := time.Parse(time.RFC3339Nano, notifications[j].LastModified)
-	return ti.Before(tj)
+// push adds e as the newest entry, overwriting the oldest one when the ring is full.
+func (r *historyRing) push(e historyEntry) {
+	size := len(r.entries)
+	if size == 0 {
+		return
+	}
+	r.invalidate()
+	if r.count < size {
+		r.entries[(r.start+r.count)%size] = e
+		r.count++
+		return
+	}
+	r.drop(r.entries[r.start])
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % size
+}
+
+func (r *historyRing) drop(e historyEntry) {
+	if r.dropped.before(e.cursor) {
+		r.dropped = e.cursor
+	}
+}
+
+func (r *historyRing) oldest() (historyEntry, bool) {
+	if r.count == 0 {
+		return historyEntry{}, false
+	}
+	return r.entries[r.start], true
+}
+
+func (r *historyRing) dropOldest() {
+	if r.count == 0 {
+		return
+	}
+	r.invalidate()
+	r.drop(r.entries[r.start])
+	r.entries[r.start] = historyEntry{}
+	r.start = (r.start + 1) % len(r.entries)
+	r.count--
+}
+
+// newestFirst returns a copy of the entries received at or after since, the most recently received first.
+func (r *historyRing) newestFirst(since time.Time) []historyEntry {
+	entries := make([]historyEntry, 0, r.count)
+	for i := r.count - 1; i >= 0; i-- {
+		e := r.entries[(r.start+i)%len(r.entries)]
+		if e.received.Before(since) {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// after returns the notifications of the entries following the cursor and received at or after since, in cursor order,
+// accepted by match and at most limit of them. The returned cursor is at the last entry read, accepted or not,
+// so the entries which were not accepted are not read again. The result is truncated when entries following the cursor
+// dropped out of the ring or were received before since, the zero cursor reading from the oldest retained entry.
+func (r *historyRing) after(cursor Cursor, since time.Time, limit int, match func(NotificationModel) bool) ([]NotificationModel, Cursor, bool) {
+	entries := r.byCursor()
+	truncated := !cursor.IsZero() && cursor.before(r.dropped)
+	from := cursor
+	notifications := []NotificationModel{}
+	for i := sort.Search(len(entries), func(i int) bool { return cursor.before(entries[i].cursor) }); i < len(entries) && len(notifications) < limit; i++ {
+		e := entries[i]
+		if e.received.Before(since) {
+			truncated = truncated || !from.IsZero()
+			continue
+		}
+		cursor = e.cursor
+		if match(e.notification) {
+			notifications = append(notifications, e.notification)
+		}
+	}
+	return notifications, cursor, truncated
+}
+
+func (r *historyRing) invalidate() {
+	r.sortLock.Lock()
+	r.sortCache = nil
+	r.cursorCache = nil
+	r.sortLock.Unlock()
+}
+
+// byCursor returns the entries sorted by their cursor, sorting them only once after each change.
+// The returned slice is shared and must not be modified.
+func (r *historyRing) byCursor() []historyEntry {
+	r.sortLock.Lock()
+	defer r.sortLock.Unlock()
+
+	if r.cursorCache != nil {
+		return r.cursorCache
+	}
+	entries := make([]historyEntry, 0, r.count)
+	for i := 0; i < r.count; i++ {
+		entries = append(entries, r.entries[(r.start+i)%len(r.entries)])
+	}
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].cursor.before(entries[b].cursor) })
+	r.cursorCache = entries
+	return entries
+}
+
+// sorted returns the entries newest first by their ordering key, sorting them only once after each change.
+// Entries with equal keys are in reverse arrival order and those without a valid key come last,
+// so the entries are already sorted when none has a key, as with the arrival order.
+// The returned slice is shared and must not be modified.
+func (r *historyRing) sorted() []historyEntry {
+	r.sortLock.Lock()
+	defer r.sortLock.Unlock()
+
+	if r.sortCache != nil {
+		return r.sortCache
+	}
+	entries := r.newestFirst(time.Time{})
+	for _, e := range entries {
+		if e.hasKey {
+			sort.SliceStable(entries, func(a, b int) bool {
+				if entries[a].hasKey != entries[b].hasKey {
+					return entries[a].hasKey
+				}
+				return entries[a].key.After(entries[b].key)
+			})
+			break
+		}
+	}
+	r.sortCache = entries
+	return entries
+}
+
+// sortedNotifications returns the notifications in the ring received at or after since, newest first by their ordering key.
+// Notifications with equal keys are returned in reverse arrival order and those without a valid key come last.
+func sortedNotifications(r *historyRing, since time.Time) []NotificationModel {
+	entries := r.sorted()
+	notifications := make([]NotificationModel, 0, len(entries))
+	for _, e := range entries {
+		if !e.received.Before(since) {
+			notifications = append(notifications, e.notification)
+		}
+	}
+	return notifications
 }
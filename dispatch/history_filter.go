@@ -0,0 +1,101 @@
+package dispatch
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryFilter selects notifications from the history. Empty fields match any notification.
+type HistoryFilter struct {
+	ContentUUID       string
+	TransactionID     string
+	Types             []string
+	SubscriptionTypes []string
+	Publication       string
+	EditorialDesk     string
+	From              time.Time
+	To                time.Time
+}
+
+// Matches reports whether the notification satisfies all the conditions of the filter.
+// Types can be given either as full notification type URIs or by their last segment e.g. UPDATE.
+// The time range is inclusive and is checked against the notification date, or the last modified date
+// for notifications which were not dispatched yet.
+func (f HistoryFilter) Matches(n NotificationModel) bool {
+	if f.ContentUUID != "" && !strings.HasSuffix(strings.ToLower(n.ID), "/"+strings.ToLower(f.ContentUUID)) {
+		return false
+	}
+	if f.TransactionID != "" && n.PublishReference != f.TransactionID {
+		return false
+	}
+	if len(f.Types) > 0 && !matchesAny(f.Types, n.Type, notificationTypeName(n.Type)) {
+		return false
+	}
+	if len(f.SubscriptionTypes) > 0 && !matchesAny(f.SubscriptionTypes, n.SubscriptionType) {
+		return false
+	}
+	if f.Publication != "" && !hasPublication(n, f.Publication) {
+		return false
+	}
+	if f.EditorialDesk != "" && !strings.EqualFold(n.EditorialDesk, f.EditorialDesk) {
+		return false
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		t, ok := notificationTime(n)
+		if !ok || (!f.From.IsZero() && t.Before(f.From)) || (!f.To.IsZero() && t.After(f.To)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns the notifications matching the filter, keeping their order.
+func (f HistoryFilter) Apply(notifications []NotificationModel) []NotificationModel {
+	matching := make([]NotificationModel, 0, len(notifications))
+	for _, n := range notifications {
+		if f.Matches(n) {
+			matching = append(matching, n)
+		}
+	}
+	return matching
+}
+
+func matchesAny(wanted []string, values ...string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if strings.EqualFold(w, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func notificationTypeName(notificationType string) string {
+	return notificationType[strings.LastIndex(notificationType, "/")+1:]
+}
+
+func hasPublication(n NotificationModel, uuid string) bool {
+	if n.Publication == nil {
+		return false
+	}
+	for _, u := range n.Publication.UUIDS {
+		if strings.EqualFold(u.String(), uuid) {
+			return true
+		}
+	}
+	return false
+}
+
+func notificationTime(n NotificationModel) (time.Time, bool) {
+	ts := n.NotificationDate
+	if ts == "" {
+		ts = n.LastModified
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
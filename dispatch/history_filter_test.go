@@ -0,0 +1,103 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Financial-Times/notifications-push/v5/publication"
+)
+
+func TestHistoryFilter(t *testing.T) {
+	t.Parallel()
+
+	n := NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentCreateType,
+		PublishReference: "tid_test",
+		LastModified:     "2016-11-02T10:54:22.234Z",
+		NotificationDate: "2016-11-02T10:55:22.234Z",
+		SubscriptionType: ArticleContentType,
+		EditorialDesk:    "/FT/Pink",
+		Publication: &publication.Publications{
+			UUIDS: []uuid.UUID{uuid.MustParse(publication.PinkFt)},
+		},
+	}
+	notificationDate, _ := time.Parse(time.RFC3339Nano, n.NotificationDate)
+
+	tests := map[string]struct {
+		filter  HistoryFilter
+		matches bool
+	}{
+		"empty filter": {
+			filter:  HistoryFilter{},
+			matches: true,
+		},
+		"content uuid": {
+			filter:  HistoryFilter{ContentUUID: "7998974A-1E97-11E6-B286-CDDDE55CA122"},
+			matches: true,
+		},
+		"other content uuid": {
+			filter: HistoryFilter{ContentUUID: "3cc23068-e501-11e9-9743-db5a370481bc"},
+		},
+		"transaction id": {
+			filter:  HistoryFilter{TransactionID: "tid_test"},
+			matches: true,
+		},
+		"other transaction id": {
+			filter: HistoryFilter{TransactionID: "tid_other"},
+		},
+		"full notification type": {
+			filter:  HistoryFilter{Types: []string{ContentCreateType}},
+			matches: true,
+		},
+		"short notification type": {
+			filter:  HistoryFilter{Types: []string{"UPDATE", "create"}},
+			matches: true,
+		},
+		"other notification type": {
+			filter: HistoryFilter{Types: []string{"DELETE"}},
+		},
+		"subscription type": {
+			filter:  HistoryFilter{SubscriptionTypes: []string{"article"}},
+			matches: true,
+		},
+		"other subscription type": {
+			filter: HistoryFilter{SubscriptionTypes: []string{AudioContentType}},
+		},
+		"publication": {
+			filter:  HistoryFilter{Publication: publication.PinkFt},
+			matches: true,
+		},
+		"other publication": {
+			filter: HistoryFilter{Publication: publication.SustainableViews},
+		},
+		"editorial desk": {
+			filter:  HistoryFilter{EditorialDesk: "/FT/Pink"},
+			matches: true,
+		},
+		"other editorial desk": {
+			filter: HistoryFilter{EditorialDesk: "/FT/Professional/Central Banking"},
+		},
+		"within time range": {
+			filter:  HistoryFilter{From: notificationDate, To: notificationDate},
+			matches: true,
+		},
+		"before time range": {
+			filter: HistoryFilter{From: notificationDate.Add(time.Millisecond)},
+		},
+		"after time range": {
+			filter: HistoryFilter{To: notificationDate.Add(-time.Millisecond)},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.matches, test.filter.Matches(n))
+		})
+	}
+}
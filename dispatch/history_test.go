@@ -10,7 +10,7 @@ import (
 func TestHistory(t *testing.T) {
 	t.Parallel()
 
-	history := NewHistory(2)
+	history := NewHistory(2, OrderByLastModified)
 	lastModified := time.Now()
 
 	history.Push(NotificationModel{
@@ -40,3 +40,82 @@ func TestHistory(t *testing.T) {
 
 	assert.Equal(t, "note3", notifications[0].ID, "Should be the last pushed notification")
 }
+
+func TestHistoryOrder(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	pushed := []NotificationModel{
+		{
+			ID:               "note1",
+			LastModified:     now.Add(-1 * time.Second).Format(time.RFC3339Nano),
+			NotificationDate: now.Add(-3 * time.Second).Format(RFC3339Millis),
+		},
+		{
+			ID:               "unparsable",
+			LastModified:     "yesterday",
+			NotificationDate: "yesterday",
+		},
+		{
+			ID:               "note2",
+			LastModified:     now.Add(-2 * time.Second).Format(time.RFC3339Nano),
+			NotificationDate: now.Add(-2 * time.Second).Format(RFC3339Millis),
+		},
+		{
+			ID:               "note3",
+			LastModified:     now.Add(-3 * time.Second).Format(time.RFC3339Nano),
+			NotificationDate: now.Add(-1 * time.Second).Format(RFC3339Millis),
+		},
+	}
+
+	tests := map[string]struct {
+		order    HistoryOrder
+		expected []string
+	}{
+		"by arrival": {
+			order:    OrderByArrival,
+			expected: []string{"note3", "note2", "unparsable", "note1"},
+		},
+		"by notification date": {
+			order:    OrderByNotificationDate,
+			expected: []string{"note3", "note2", "note1", "unparsable"},
+		},
+		"by last modified": {
+			order:    OrderByLastModified,
+			expected: []string{"note1", "note2", "note3", "unparsable"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			history := NewHistory(len(pushed), test.order)
+			for _, n := range pushed {
+				history.Push(n)
+			}
+
+			notifications := history.Notifications()
+			var ids []string
+			for _, n := range notifications {
+				ids = append(ids, n.ID)
+			}
+			assert.Equal(t, test.expected, ids)
+
+			notifications[0].ID = "changed"
+			assert.Equal(t, test.expected[0], history.Notifications()[0].ID, "Should return a copy of the history")
+		})
+	}
+}
+
+func TestParseHistoryOrder(t *testing.T) {
+	t.Parallel()
+
+	order, err := ParseHistoryOrder("notificationDate")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderByNotificationDate, order)
+
+	_, err = ParseHistoryOrder("random")
+	assert.Error(t, err)
+}
@@ -0,0 +1,457 @@
+package dispatch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+const (
+	segmentExt             = ".seg"
+	compactedExt           = ".base"
+	segmentTmpExt          = ".tmp"
+	recordHeaderLen        = 8
+	maxRecordLen           = 1 << 20
+	defaultSegmentCapacity = 1024
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errCorruptRecord = errors.New("corrupt journal record")
+)
+
+// JournalHistory is a History that persists notifications to an append-only journal on local disk,
+// so the history survives restarts. The journal is split in segment files which are removed once
+// all of their notifications fall out of the retention limits. The first segment is the compacted one
+// written at startup, which supersedes any older segment left over by an interrupted compaction.
+type JournalHistory struct {
+	dir             string
+	maxAge          time.Duration
+	segmentCapacity int
+	order           HistoryOrder
+	mutex           *sync.RWMutex
+	ring            *historyRing
+	segments        []*segment
+	active          *os.File
+	nextIndex       uint64
+	now             func() time.Time
+	log             *logger.UPPLogger
+}
+
+type journalRecord struct {
+	Timestamp    time.Time         `json:"timestamp"`
+	Notification NotificationModel `json:"notification"`
+}
+
+type segment struct {
+	seq       uint64
+	path      string
+	records   int
+	lastIndex uint64
+}
+
+// NewJournalHistory opens the journal in dir, creating it when missing, and loads the retained notifications.
+// The journal keeps at most size notifications, and if maxAge is positive only those appended within maxAge.
+// The notifications are returned ordered by the given key.
+func NewJournalHistory(dir string, size int, maxAge time.Duration, order HistoryOrder, log *logger.UPPLogger) (*JournalHistory, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	j := &JournalHistory{
+		dir:             dir,
+		maxAge:          maxAge,
+		segmentCapacity: defaultSegmentCapacity,
+		order:           order,
+		mutex:           &sync.RWMutex{},
+		ring:            newHistoryRing(size),
+		now:             time.Now,
+		log:             log,
+	}
+
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+
+	log.WithField("dir", dir).Infof("Loaded %d notifications from the history journal", j.ring.count)
+	return j, nil
+}
+
+func (j *JournalHistory) Push(n NotificationModel) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	e := newHistoryEntry(n, j.nextIndex, j.now().UTC(), j.order)
+	j.nextIndex++
+
+	if err := j.append(e); err != nil {
+		j.log.WithTransactionID(n.PublishReference).WithError(err).Error("Failed to write notification to the history journal")
+	}
+
+	j.ring.push(e)
+	j.applyRetention()
+	j.removeExpiredSegments()
+}
+
+func (j *JournalHistory) Notifications() []NotificationModel {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	return sortedNotifications(j.ring, j.oldestAllowed())
+}
+
+// After reads the notifications in cursor order, the cursors taken before a restart keep their position.
+func (j *JournalHistory) After(cursor Cursor, limit int, match func(NotificationModel) bool) ([]NotificationModel, Cursor, bool) {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	return j.ring.after(cursor, j.oldestAllowed(), limit, match)
+}
+
+// Close flushes and closes the active segment.
+func (j *JournalHistory) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.active == nil {
+		return nil
+	}
+	err := j.active.Sync()
+	if closeErr := j.active.Close(); err == nil {
+		err = closeErr
+	}
+	j.active = nil
+
+	return err
+}
+
+func (j *JournalHistory) oldestAllowed() time.Time {
+	if j.maxAge <= 0 {
+		return time.Time{}
+	}
+	return j.now().Add(-j.maxAge)
+}
+
+// applyRetention drops the oldest notifications which exceed maxAge. The size limit is kept by the ring itself.
+func (j *JournalHistory) applyRetention() {
+	oldest := j.oldestAllowed()
+	for e, ok := j.ring.oldest(); ok && e.received.Before(oldest); e, ok = j.ring.oldest() {
+		j.ring.dropOldest()
+	}
+}
+
+// removeExpiredSegments deletes the inactive segments which contain only notifications out of retention.
+func (j *JournalHistory) removeExpiredSegments() {
+	firstRetained := j.nextIndex
+	if e, ok := j.ring.oldest(); ok {
+		firstRetained = e.seq
+	}
+
+	for len(j.segments) > 1 && j.segments[0].lastIndex < firstRetained {
+		s := j.segments[0]
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			j.log.WithError(err).WithField("segment", s.path).Warn("Failed to remove expired journal segment")
+			return
+		}
+		j.segments = j.segments[1:]
+	}
+}
+
+func (j *JournalHistory) append(e historyEntry) error {
+	current := j.segments[len(j.segments)-1]
+	if current.records >= j.segmentCapacity {
+		var err error
+		if current, err = j.roll(); err != nil {
+			return err
+		}
+	}
+
+	record, err := encodeRecord(e)
+	if err != nil {
+		return err
+	}
+	if _, err = j.active.Write(record); err != nil {
+		return err
+	}
+	if err = j.active.Sync(); err != nil {
+		return err
+	}
+
+	current.records++
+	current.lastIndex = e.seq
+	return nil
+}
+
+// roll closes the active segment and starts a new one.
+func (j *JournalHistory) roll() (*segment, error) {
+	if err := j.active.Close(); err != nil {
+		return nil, fmt.Errorf("closing journal segment: %w", err)
+	}
+
+	last := j.segments[len(j.segments)-1]
+	s := &segment{seq: last.seq + 1, path: j.segmentPath(last.seq + 1), lastIndex: last.lastIndex}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("creating journal segment: %w", err)
+	}
+	if err = syncDir(j.dir); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	j.active = f
+	j.segments = append(j.segments, s)
+	return s, nil
+}
+
+// load reads the segments in the journal directory, starting from the newest compacted one.
+// A partially written record at the end of the newest segment is the result of a crash and is discarded.
+func (j *JournalHistory) load() error {
+	segments, err := j.listSegments()
+	if err != nil {
+		return err
+	}
+
+	segments, err = supersede(segments)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range segments {
+		path := s.path
+		records, validLen, err := readSegment(path)
+		if err != nil && !errors.Is(err, errCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("reading journal segment %s: %w", path, err)
+		}
+		if err != nil {
+			entry := j.log.WithError(err).WithField("segment", path)
+			if i == len(segments)-1 {
+				entry.Warn("Discarding incomplete record at the end of the history journal")
+				if err = os.Truncate(path, validLen); err != nil {
+					return fmt.Errorf("truncating journal segment %s: %w", path, err)
+				}
+			} else {
+				entry.Warn("Skipping corrupt records in the history journal")
+			}
+		}
+
+		for _, r := range records {
+			j.ring.push(newHistoryEntry(r.Notification, j.nextIndex, r.Timestamp, j.order))
+			j.nextIndex++
+		}
+		s.records = len(records)
+		s.lastIndex = j.nextIndex - 1
+		j.segments = append(j.segments, s)
+	}
+
+	j.applyRetention()
+	return nil
+}
+
+// compact rewrites the retained notifications into a new compacted segment and removes all the older ones.
+// The new segment is written to a temporary file and renamed in place. As it supersedes the older segments on load,
+// a crash leaves either the old segments or the compacted one in effect, whether the old ones were removed or not.
+func (j *JournalHistory) compact() error {
+	var seq uint64 = 1
+	if len(j.segments) > 0 {
+		seq = j.segments[len(j.segments)-1].seq + 1
+	}
+	path := j.compactedPath(seq)
+	tmpPath := path + segmentTmpExt
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating journal segment: %w", err)
+	}
+	retained := j.ring.newestFirst(time.Time{})
+	w := bufio.NewWriter(f)
+	for i := len(retained) - 1; i >= 0; i-- {
+		record, err := encodeRecord(retained[i])
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if _, err = w.Write(record); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("writing journal segment: %w", err)
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing journal segment: %w", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("renaming journal segment: %w", err)
+	}
+	if err = syncDir(j.dir); err != nil {
+		return err
+	}
+
+	for _, s := range j.segments {
+		if err = os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing compacted journal segment: %w", err)
+		}
+	}
+
+	lastIndex := j.nextIndex
+	if len(retained) > 0 {
+		lastIndex = retained[0].seq
+	}
+	j.segments = []*segment{{seq: seq, path: path, records: len(retained), lastIndex: lastIndex}}
+
+	j.active, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening journal segment: %w", err)
+	}
+	return nil
+}
+
+// listSegments returns the segments in the journal directory in ascending sequence order.
+// Leftover temporary files from an interrupted compaction are removed.
+func (j *JournalHistory) listSegments() ([]*segment, error) {
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading journal directory: %w", err)
+	}
+
+	var segments []*segment
+	for _, f := range files {
+		name := f.Name()
+		path := filepath.Join(j.dir, name)
+		if strings.HasSuffix(name, segmentTmpExt) {
+			_ = os.Remove(path)
+			continue
+		}
+		ext := filepath.Ext(name)
+		if f.IsDir() || (ext != segmentExt && ext != compactedExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{seq: seq, path: path})
+	}
+	sort.Slice(segments, func(a, b int) bool { return segments[a].seq < segments[b].seq })
+
+	return segments, nil
+}
+
+// supersede removes the segments older than the newest compacted one, whose notifications it already contains,
+// and returns the remaining segments.
+func supersede(segments []*segment) ([]*segment, error) {
+	first := 0
+	for i, s := range segments {
+		if filepath.Ext(s.path) == compactedExt {
+			first = i
+		}
+	}
+	for _, s := range segments[:first] {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing compacted journal segment: %w", err)
+		}
+	}
+	return segments[first:], nil
+}
+
+func (j *JournalHistory) segmentPath(seq uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+func (j *JournalHistory) compactedPath(seq uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", seq, compactedExt))
+}
+
+// encodeRecord frames the JSON encoded entry with its length and CRC-32C checksum.
+func encodeRecord(e historyEntry) ([]byte, error) {
+	payload, err := json.Marshal(journalRecord{Timestamp: e.received, Notification: e.notification})
+	if err != nil {
+		return nil, fmt.Errorf("encoding journal record: %w", err)
+	}
+
+	record := make([]byte, recordHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+	copy(record[recordHeaderLen:], payload)
+
+	return record, nil
+}
+
+// readSegment returns the records of a segment and the length of its valid prefix.
+func readSegment(path string) ([]journalRecord, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, recordHeaderLen)
+	var records []journalRecord
+	var offset int64
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, offset, nil
+			}
+			return records, offset, err
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		if length > maxRecordLen {
+			return records, offset, errCorruptRecord
+		}
+		payload := make([]byte, length)
+		if _, err = io.ReadFull(r, payload); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return records, offset, err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			return records, offset, errCorruptRecord
+		}
+
+		var rec journalRecord
+		if err = json.Unmarshal(payload, &rec); err != nil {
+			return records, offset, errCorruptRecord
+		}
+		records = append(records, rec)
+		offset += int64(recordHeaderLen) + int64(length)
+	}
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("opening journal directory: %w", err)
+	}
+	defer d.Close()
+
+	if err = d.Sync(); err != nil {
+		return fmt.Errorf("syncing journal directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package dispatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/publication"
+	"github.com/google/uuid"
+)
+
+func TestJournalHistorySurvivesRestart(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	dir := t.TempDir()
+	lastModified := time.Now()
+
+	j, err := NewJournalHistory(dir, 2, 0, OrderByLastModified, l)
+	require.NoError(t, err)
+
+	j.Push(NotificationModel{
+		ID:           "note1",
+		LastModified: lastModified.Add(-2 * time.Second).Format(time.RFC3339Nano),
+	})
+	j.Push(NotificationModel{
+		ID:           "note2",
+		LastModified: lastModified.Add(-1 * time.Second).Format(time.RFC3339Nano),
+		Publication:  &publication.Publications{UUIDS: []uuid.UUID{uuid.MustParse(publication.PinkFt)}},
+	})
+	j.Push(NotificationModel{
+		ID:           "note3",
+		LastModified: lastModified.Format(time.RFC3339Nano),
+	})
+	require.NoError(t, j.Close())
+
+	j, err = NewJournalHistory(dir, 2, 0, OrderByLastModified, l)
+	require.NoError(t, err)
+	defer j.Close()
+
+	notifications := j.Notifications()
+	require.Len(t, notifications, 2, "Should retain only the last 2 notifications")
+	assert.Equal(t, "note3", notifications[0].ID)
+	assert.Equal(t, "note2", notifications[1].ID)
+	assert.Equal(t, publication.PinkFt, notifications[1].Publication.UUIDS[0].String())
+}
+
+func TestJournalHistoryMaxAge(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	now := time.Now()
+
+	j, err := NewJournalHistory(t.TempDir(), 10, time.Hour, OrderByLastModified, l)
+	require.NoError(t, err)
+	defer j.Close()
+
+	j.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	j.Push(NotificationModel{ID: "old"})
+	j.now = func() time.Time { return now }
+	j.Push(NotificationModel{ID: "recent"})
+
+	notifications := j.Notifications()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "recent", notifications[0].ID)
+}
+
+func TestJournalHistoryDiscardsPartialRecord(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	dir := t.TempDir()
+
+	j, err := NewJournalHistory(dir, 10, 0, OrderByLastModified, l)
+	require.NoError(t, err)
+	j.Push(NotificationModel{ID: "note1"})
+	j.Push(NotificationModel{ID: "note2"})
+	require.NoError(t, j.Close())
+
+	// simulate a crash in the middle of writing the last record
+	path := j.segments[0].path
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-5))
+
+	j, err = NewJournalHistory(dir, 10, 0, OrderByLastModified, l)
+	require.NoError(t, err)
+	defer j.Close()
+
+	notifications := j.Notifications()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "note1", notifications[0].ID)
+
+	j.Push(NotificationModel{ID: "note3"})
+	assert.Len(t, j.Notifications(), 2)
+}
+
+func TestJournalHistoryRemovesExpiredSegments(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	dir := t.TempDir()
+
+	j, err := NewJournalHistory(dir, 3, 0, OrderByLastModified, l)
+	require.NoError(t, err)
+	defer j.Close()
+	j.segmentCapacity = 2
+
+	for i := 0; i < 10; i++ {
+		j.Push(NotificationModel{ID: "note"})
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	assert.Len(t, files, 2, "Only the segments with retained notifications should be kept")
+	assert.Len(t, j.Notifications(), 3)
+}
+
+func TestJournalHistoryInterruptedCompaction(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	dir := t.TempDir()
+
+	j, err := NewJournalHistory(dir, 10, 0, OrderByArrival, l)
+	require.NoError(t, err)
+	j.Push(NotificationModel{ID: "note1"})
+	j.Push(NotificationModel{ID: "note2"})
+	require.NoError(t, j.Close())
+
+	// simulate a crash after the compacted segment was renamed in place, before the older segments were removed
+	data, err := os.ReadFile(j.segments[0].path)
+	require.NoError(t, err)
+	leftover := j.segmentPath(0)
+	require.NoError(t, os.WriteFile(leftover, data, 0o644))
+
+	j, err = NewJournalHistory(dir, 10, 0, OrderByArrival, l)
+	require.NoError(t, err)
+	defer j.Close()
+
+	notifications := j.Notifications()
+	require.Len(t, notifications, 2, "The superseded segment should not be loaded")
+	assert.Equal(t, "note2", notifications[0].ID)
+	assert.Equal(t, "note1", notifications[1].ID)
+	assert.NoFileExists(t, leftover)
+}
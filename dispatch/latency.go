@@ -0,0 +1,147 @@
+package dispatch
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// LatencyWindow is the period over which the latency percentiles are computed
+	LatencyWindow = 15 * time.Minute
+	// latencySamples caps the number of notifications kept for the percentiles, the oldest ones are replaced first
+	latencySamples = 1024
+)
+
+// latencySample is the time a notification spent in each step, from its last modification to the end of the fan-out
+type latencySample struct {
+	at     time.Time
+	kafka  time.Duration
+	delay  time.Duration
+	fanOut time.Duration
+	total  time.Duration
+}
+
+// LatencyPercentiles of a delivery step over the latency window
+type LatencyPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+}
+
+// LatencyReport gives the latency percentiles of the notifications delivered in the latency window.
+// Kafka is the time from the last modification of the content to the notification reaching the dispatcher,
+// Delay the time waited before the fan-out, FanOut the time taken to forward it to the subscribers
+// and Total the time from the last modification to the end of the fan-out.
+type LatencyReport struct {
+	Samples int                `json:"samples"`
+	Kafka   LatencyPercentiles `json:"kafka"`
+	Delay   LatencyPercentiles `json:"delay"`
+	FanOut  LatencyPercentiles `json:"fanOut"`
+	Total   LatencyPercentiles `json:"total"`
+}
+
+// latencyTracker keeps the latency of the last notifications in a ring buffer.
+// All methods are safe to call on a nil value, which keeps nothing.
+type latencyTracker struct {
+	mutex   *sync.Mutex
+	window  time.Duration
+	samples []latencySample
+	next    int
+}
+
+func newLatencyTracker(window time.Duration, size int) *latencyTracker {
+	return &latencyTracker{
+		mutex:   &sync.Mutex{},
+		window:  window,
+		samples: make([]latencySample, 0, size),
+	}
+}
+
+// record keeps the latency of a notification once its fan-out has ended.
+// Redelivered and targeted notifications are left out, as well as those without a valid last modified date.
+func (l *latencyTracker) record(n NotificationModel, trace *DeliveryTrace, targeted bool) {
+	if l == nil || n.Redelivered || targeted || trace.FanOutStartedAt == nil || trace.FanOutEndedAt == nil {
+		return
+	}
+	lastModified, err := time.Parse(time.RFC3339Nano, n.LastModified)
+	if err != nil {
+		return
+	}
+	s := latencySample{
+		at:     *trace.FanOutEndedAt,
+		kafka:  nonNegative(trace.ReceivedAt.Sub(lastModified)),
+		delay:  trace.FanOutStartedAt.Sub(trace.ReceivedAt),
+		fanOut: trace.FanOutEndedAt.Sub(*trace.FanOutStartedAt),
+		total:  nonNegative(trace.FanOutEndedAt.Sub(lastModified)),
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) < cap(l.samples) {
+		l.samples = append(l.samples, s)
+		return
+	}
+	l.samples[l.next] = s
+	l.next = (l.next + 1) % len(l.samples)
+}
+
+func (l *latencyTracker) report(now time.Time) LatencyReport {
+	if l == nil {
+		return LatencyReport{}
+	}
+	l.mutex.Lock()
+	var kafka, delay, fanOut, total []time.Duration
+	for _, s := range l.samples {
+		if now.Sub(s.at) > l.window {
+			continue
+		}
+		kafka = append(kafka, s.kafka)
+		delay = append(delay, s.delay)
+		fanOut = append(fanOut, s.fanOut)
+		total = append(total, s.total)
+	}
+	l.mutex.Unlock()
+
+	return LatencyReport{
+		Samples: len(total),
+		Kafka:   percentiles(kafka),
+		Delay:   percentiles(delay),
+		FanOut:  percentiles(fanOut),
+		Total:   percentiles(total),
+	}
+}
+
+func percentiles(d []time.Duration) LatencyPercentiles {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	return LatencyPercentiles{
+		P50: percentile(d, 50),
+		P95: percentile(d, 95),
+		P99: percentile(d, 99),
+	}
+}
+
+// percentile uses the nearest-rank method on the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// nonNegative hides the clock skew between the publishing systems and this service
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Latency returns the latency percentiles of the notifications delivered in the last LatencyWindow
+func (d *Dispatcher) Latency() LatencyReport {
+	return d.latency.report(time.Now())
+}
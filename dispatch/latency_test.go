@@ -0,0 +1,100 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func latencyTrace(lastModified time.Time, kafka, delay, fanOut time.Duration) (NotificationModel, *DeliveryTrace) {
+	n := NotificationModel{LastModified: lastModified.Format(time.RFC3339Nano)}
+	trace := newDeliveryTrace(n, lastModified.Add(kafka))
+	trace.FanOutStartedAt = timestamp(trace.ReceivedAt.Add(delay))
+	trace.FanOutEndedAt = timestamp(trace.FanOutStartedAt.Add(fanOut))
+	return n, trace
+}
+
+func TestLatencyTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tracker := newLatencyTracker(time.Minute, 100)
+	for i := 1; i <= 100; i++ {
+		n, trace := latencyTrace(now.Add(-40*time.Second), time.Duration(i)*time.Millisecond, 30*time.Second, time.Millisecond)
+		tracker.record(n, trace, false)
+	}
+
+	r := tracker.report(now)
+	assert.Equal(t, 100, r.Samples)
+	assert.Equal(t, LatencyPercentiles{P50: 50 * time.Millisecond, P95: 95 * time.Millisecond, P99: 99 * time.Millisecond}, r.Kafka)
+	assert.Equal(t, 30*time.Second, r.Delay.P95)
+	assert.Equal(t, time.Millisecond, r.FanOut.P99)
+	assert.Equal(t, 30*time.Second+96*time.Millisecond, r.Total.P95)
+
+	// the oldest samples are replaced once the buffer is full
+	for i := 0; i < 95; i++ {
+		n, trace := latencyTrace(now.Add(-40*time.Second), 0, time.Second, 0)
+		tracker.record(n, trace, false)
+	}
+	r = tracker.report(now)
+	assert.Equal(t, 100, r.Samples)
+	assert.Equal(t, time.Second, r.Delay.P95)
+	assert.Equal(t, 30*time.Second, r.Delay.P99)
+
+	assert.Zero(t, tracker.report(now.Add(2*time.Minute)).Samples, "Samples older than the window should be left out")
+}
+
+func TestLatencyTrackerSkips(t *testing.T) {
+	t.Parallel()
+
+	tracker := newLatencyTracker(time.Minute, 10)
+	n, trace := latencyTrace(time.Now(), time.Second, time.Second, time.Second)
+
+	redelivered := n
+	redelivered.Redelivered = true
+	tracker.record(redelivered, trace, false)
+	tracker.record(n, trace, true)
+	tracker.record(NotificationModel{LastModified: "not a date"}, trace, false)
+	tracker.record(n, newDeliveryTrace(n, time.Now()), false)
+	assert.Zero(t, tracker.report(time.Now()).Samples)
+
+	// the clock skew of the publishing systems is not reported as a negative latency
+	skewed, trace := latencyTrace(time.Now().Add(time.Minute), -time.Minute, time.Second, 0)
+	tracker.record(skewed, trace, false)
+	r := tracker.report(time.Now())
+	assert.Equal(t, 1, r.Samples)
+	assert.Zero(t, r.Kafka.P50)
+
+	var nilTracker *latencyTracker
+	nilTracker.record(n, trace, false)
+	assert.Equal(t, LatencyReport{}, nilTracker.report(time.Now()))
+}
+
+func TestDispatcherLatency(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+
+	go d.Start()
+	defer d.Stop()
+
+	d.Send(NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_latency",
+		LastModified:     time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+		SubscriptionType: ArticleContentType,
+	})
+
+	require.Eventually(t, func() bool { return d.Latency().Samples == 1 }, time.Second, 10*time.Millisecond)
+	r := d.Latency()
+	assert.GreaterOrEqual(t, r.Kafka.P95, time.Second)
+	assert.GreaterOrEqual(t, r.Total.P95, r.Kafka.P95)
+}
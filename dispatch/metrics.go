@@ -0,0 +1,72 @@
+package dispatch
+
+import (
+	"strconv"
+
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type subscriberKey struct {
+	subType string
+	monitor bool
+}
+
+// collector reads the dispatcher gauges when the metrics are scraped
+type collector struct {
+	dispatcher  *Dispatcher
+	pending     *prometheus.Desc
+	subscribers *prometheus.Desc
+	buffered    *prometheus.Desc
+	bufferFull  *prometheus.Desc
+}
+
+// Collector returns the Prometheus collector of the pending notifications and the connected subscribers
+func (d *Dispatcher) Collector() prometheus.Collector {
+	labels := []string{"subscription_type", "monitor"}
+	return &collector{
+		dispatcher: d,
+		pending: prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "pending_notifications"),
+			"Notifications waiting for their delay to end or for the dispatcher to be unfrozen.", nil, nil),
+		subscribers: prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "subscribers"),
+			"Connected subscribers, a subscriber to several types is counted for each of them.", labels, nil),
+		buffered: prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "subscriber_buffered_notifications"),
+			"Notifications waiting in the subscriber buffers to be written to the streams.", labels, nil),
+		bufferFull: prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "subscribers_buffer_full"),
+			"Subscribers whose buffer is full, the next notifications are dropped for them.", labels, nil),
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.subscribers
+	ch <- c.buffered
+	ch <- c.bufferFull
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(c.dispatcher.Pending()))
+
+	subscribers := map[subscriberKey]int{}
+	buffered := map[subscriberKey]int{}
+	bufferFull := map[subscriberKey]int{}
+	for _, s := range c.dispatcher.Subscribers() {
+		_, isMonitor := s.(*MonitorSubscriber)
+		occupancy := len(s.Notifications())
+		for _, subType := range s.SubTypes() {
+			key := subscriberKey{subType: subType, monitor: isMonitor}
+			subscribers[key]++
+			buffered[key] += occupancy
+			if occupancy == cap(s.Notifications()) {
+				bufferFull[key]++
+			}
+		}
+	}
+
+	for key, count := range subscribers {
+		labels := []string{key.subType, strconv.FormatBool(key.monitor)}
+		ch <- prometheus.MustNewConstMetric(c.subscribers, prometheus.GaugeValue, float64(count), labels...)
+		ch <- prometheus.MustNewConstMetric(c.buffered, prometheus.GaugeValue, float64(buffered[key]), labels...)
+		ch <- prometheus.MustNewConstMetric(c.bufferFull, prometheus.GaugeValue, float64(bufferFull[key]), labels...)
+	}
+}
@@ -0,0 +1,49 @@
+package dispatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, nil, nil, l)
+
+	options := &access.NotificationSubscriptionOptions{}
+	_, err := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+	require.NoError(t, err)
+	full, err := d.Subscribe("192.168.1.3", []string{ArticleContentType, AudioContentType}, false, options)
+	require.NoError(t, err)
+	_, err = d.Subscribe("192.168.1.4", []string{AllContentType}, true, options)
+	require.NoError(t, err)
+	for i := 0; i < notificationBuffer; i++ {
+		require.NoError(t, full.(NotificationConsumer).Send(NotificationResponse{}))
+	}
+
+	expected := `
+# HELP notifications_push_pending_notifications Notifications waiting for their delay to end or for the dispatcher to be unfrozen.
+# TYPE notifications_push_pending_notifications gauge
+notifications_push_pending_notifications 0
+# HELP notifications_push_subscribers Connected subscribers, a subscriber to several types is counted for each of them.
+# TYPE notifications_push_subscribers gauge
+notifications_push_subscribers{monitor="false",subscription_type="Article"} 2
+notifications_push_subscribers{monitor="false",subscription_type="Audio"} 1
+notifications_push_subscribers{monitor="true",subscription_type="All"} 1
+# HELP notifications_push_subscribers_buffer_full Subscribers whose buffer is full, the next notifications are dropped for them.
+# TYPE notifications_push_subscribers_buffer_full gauge
+notifications_push_subscribers_buffer_full{monitor="false",subscription_type="Article"} 1
+notifications_push_subscribers_buffer_full{monitor="false",subscription_type="Audio"} 1
+notifications_push_subscribers_buffer_full{monitor="true",subscription_type="All"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(d.Collector(), strings.NewReader(expected),
+		"notifications_push_pending_notifications", "notifications_push_subscribers", "notifications_push_subscribers_buffer_full"))
+}
@@ -3,6 +3,7 @@ package dispatch
 import (
 	"github.com/Financial-Times/notifications-push/v5/access"
 	"github.com/Financial-Times/notifications-push/v5/publication"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // subscription types
@@ -28,6 +29,12 @@ const (
 	RelatedContentType   = "http://www.ft.com/thing/ThingChangeType/RELATEDCONTENT"
 )
 
+// advancedOptions renders notifications as seen by a subscriber with all the policies, for internal use.
+var advancedOptions = &access.NotificationSubscriptionOptions{
+	ReceiveAdvancedNotifications: true,
+	ReceiveInternalUnstable:      true,
+}
+
 // NotificationModel model
 type NotificationModel struct {
 	APIURL           string
@@ -43,6 +50,12 @@ type NotificationModel struct {
 	SubscriptionType string
 	IsE2ETest        bool
 	Publication      *publication.Publications
+	Redelivered      bool
+	// ContentPolicyAllowed is the content policy decision taken when the notification was dispatched,
+	// nil when the policy could not be evaluated. It is kept in the history for the pull consumers.
+	ContentPolicyAllowed *bool `json:",omitempty"`
+	// SpanContext links the dispatching spans to the trace of the consumed message, it is not kept in the history
+	SpanContext trace.SpanContext `json:"-"`
 }
 
 // NotificationResponse view
@@ -56,6 +69,9 @@ type NotificationResponse struct {
 	NotificationDate string    `json:"notificationDate,omitempty"`
 	Title            string    `json:"title,omitempty"`
 	Standout         *Standout `json:"standout,omitempty"`
+	Redelivered      bool      `json:"redelivered,omitempty"`
+	// SpanContext is the trace context of the fan-out, used to trace the write to the subscriber stream
+	SpanContext trace.SpanContext `json:"-"`
 }
 
 // Standout model for a NotificationResponse
@@ -63,10 +79,12 @@ type Standout struct {
 	Scoop bool `json:"scoop"`
 }
 
+// CreateNotificationResponse builds the view of the notification for a subscriber with the given options.
+// CREATE events are preserved only for subscribers receiving advanced notifications, nil options receive standard ones.
 func CreateNotificationResponse(notification NotificationModel, subscriberOptions *access.NotificationSubscriptionOptions) NotificationResponse {
 	notificationType := notification.Type
 
-	if notificationType == ContentCreateType && !subscriberOptions.ReceiveAdvancedNotifications {
+	if notificationType == ContentCreateType && (subscriberOptions == nil || !subscriberOptions.ReceiveAdvancedNotifications) {
 		notificationType = ContentUpdateType
 	}
 
@@ -80,5 +98,7 @@ func CreateNotificationResponse(notification NotificationModel, subscriberOption
 		NotificationDate: notification.NotificationDate,
 		Title:            notification.Title,
 		Standout:         notification.Standout,
+		Redelivered:      notification.Redelivered,
+		SpanContext:      notification.SpanContext,
 	}
 }
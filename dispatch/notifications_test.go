@@ -37,6 +37,15 @@ func TestCreateNotificationResponse(t *testing.T) {
 				Type: ContentUpdateType,
 			},
 		},
+		{
+			name: "nil options",
+			n: NotificationModel{
+				Type: ContentCreateType,
+			},
+			res: NotificationResponse{
+				Type: ContentUpdateType,
+			},
+		},
 		{
 			n: NotificationModel{
 				Type: ContentUpdateType,
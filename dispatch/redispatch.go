@@ -0,0 +1,91 @@
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// subscriber groups
+const (
+	StandardGroup = "standard"
+	MonitorGroup  = "monitor"
+)
+
+// Target selects the subscribers a re-dispatched notification is forwarded to. Empty fields match any subscriber.
+type Target struct {
+	SubscriberID string
+	Group        string
+}
+
+// NewTarget validates the subscriber group and returns the target, nil when it would match every subscriber
+func NewTarget(subscriberID string, group string) (*Target, error) {
+	group = strings.ToLower(group)
+	if group != "" && group != StandardGroup && group != MonitorGroup {
+		return nil, fmt.Errorf("unknown subscriber group %q, expected %s or %s", group, StandardGroup, MonitorGroup)
+	}
+	if subscriberID == "" && group == "" {
+		return nil, nil
+	}
+	return &Target{SubscriberID: subscriberID, Group: group}, nil
+}
+
+// Matches reports whether the subscriber is selected by the target. A nil target matches every subscriber.
+func (t *Target) Matches(s Subscriber) bool {
+	if t == nil {
+		return true
+	}
+	if t.SubscriberID != "" && s.ID() != t.SubscriberID {
+		return false
+	}
+	switch t.Group {
+	case StandardGroup:
+		_, ok := s.(*StandardSubscriber)
+		return ok
+	case MonitorGroup:
+		_, ok := s.(*MonitorSubscriber)
+		return ok
+	}
+	return true
+}
+
+// Redispatch sends the notifications again, in the given order, marking them as redelivered.
+// They are forwarded only to the subscribers matching the target and the configured delay is waited unless skipped.
+func (d *Dispatcher) Redispatch(notifications []NotificationModel, target *Target, skipDelay bool) {
+	delay := d.delay
+	if skipDelay {
+		delay = 0
+	}
+
+	traces := make([]*DeliveryTrace, 0, len(notifications))
+	for i := range notifications {
+		notifications[i].Redelivered = true
+		trace := newDeliveryTrace(notifications[i], time.Now())
+		d.traces.record(trace)
+		traces = append(traces, trace)
+		atomic.AddInt64(&d.pending, 1)
+
+		d.log.WithTransactionID(notifications[i].PublishReference).
+			WithField("resource", notifications[i].APIURL).
+			Infof("Re-dispatching notification. Waiting delay (%v).", delay)
+	}
+
+	go func() {
+		time.Sleep(delay)
+		for i, n := range notifications {
+			d.deliver(newDelivery(n, traces[i], target))
+		}
+	}()
+}
+
+// Probe forwards a notification right away to the probe subscribers matching the target, to check the delivery pipeline.
+// The probe is kept out of the history, the delivery traces and the trace watchers.
+func (d *Dispatcher) Probe(n NotificationModel, target *Target) {
+	trace := newDeliveryTrace(n, time.Now())
+	trace.probe = true
+	atomic.AddInt64(&d.pending, 1)
+	go func() {
+		d.deliver(newDelivery(n, trace, target))
+	}()
+}
@@ -0,0 +1,167 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func TestNewTarget(t *testing.T) {
+	t.Parallel()
+
+	standard, err := NewStandardSubscriber("192.168.1.2", []string{ArticleContentType}, nil)
+	require.NoError(t, err)
+	monitor, err := NewMonitorSubscriber("192.168.1.3", []string{ArticleContentType}, nil)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		subscriberID     string
+		group            string
+		expectError      bool
+		expectedStandard bool
+		expectedMonitor  bool
+	}{
+		"no target": {
+			expectedStandard: true,
+			expectedMonitor:  true,
+		},
+		"monitor group": {
+			group:           "Monitor",
+			expectedMonitor: true,
+		},
+		"standard group": {
+			group:            StandardGroup,
+			expectedStandard: true,
+		},
+		"single subscriber": {
+			subscriberID:    monitor.ID(),
+			expectedMonitor: true,
+		},
+		"subscriber outside group": {
+			subscriberID: monitor.ID(),
+			group:        StandardGroup,
+		},
+		"unknown group": {
+			group:       "internal",
+			expectError: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target, err := NewTarget(test.subscriberID, test.group)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStandard, target.Matches(standard))
+			assert.Equal(t, test.expectedMonitor, target.Matches(monitor))
+		})
+	}
+}
+
+func TestRedispatch(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	h := NewHistory(10, OrderByArrival)
+	d := NewDispatcher(time.Hour, h, NewTraceStore(10), agent, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	options := &access.NotificationSubscriptionOptions{}
+	standard, _ := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+	monitor, _ := d.Subscribe("192.168.1.3", []string{ArticleContentType}, true, options)
+
+	notifications := []NotificationModel{
+		{ID: "http://www.ft.com/thing/1", PublishReference: "tid_1", SubscriptionType: ArticleContentType},
+		{ID: "http://www.ft.com/thing/2", PublishReference: "tid_2", SubscriptionType: ArticleContentType},
+	}
+	d.Redispatch(notifications, &Target{Group: MonitorGroup}, true)
+
+	for _, expected := range []string{"tid_1", "tid_2"} {
+		select {
+		case msg := <-monitor.Notifications():
+			assert.Contains(t, msg, `"publishReference":"`+expected+`"`, "Notifications should be sent in the given order")
+			assert.Contains(t, msg, `"redelivered":true`)
+		case <-time.After(time.Second):
+			t.Fatal("The delay should be skipped")
+		}
+	}
+	assert.Empty(t, standard.Notifications(), "Subscribers outside the target should not receive the notifications")
+
+	require.Eventually(t, func() bool {
+		return len(d.Traces("tid_2")) == 1 && d.Traces("tid_2")[0].FanOutEndedAt != nil
+	}, time.Second, 10*time.Millisecond)
+	trace := d.Traces("tid_2")[0]
+	assert.True(t, trace.Notification.Redelivered)
+	require.Len(t, trace.Subscribers, 1)
+	assert.Equal(t, monitor.ID(), trace.Subscribers[0].SubscriberID)
+
+	d.Redispatch([]NotificationModel{{ID: "http://www.ft.com/thing/3", SubscriptionType: ArticleContentType}}, nil, true)
+	select {
+	case <-standard.Notifications():
+	case <-time.After(time.Second):
+		t.Fatal("The untargeted notification should be sent to every subscriber")
+	}
+	assert.Eventually(t, func() bool { return d.Pending() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, h.Notifications(), "The re-dispatched notifications should not be added to the history again")
+}
+
+func TestProbe(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	h := NewHistory(10, OrderByArrival)
+	traces := NewTraceStore(10)
+	d := NewDispatcher(time.Hour, h, traces, agent, nil, l)
+	watcher := d.WatchTraces()
+
+	options := &access.NotificationSubscriptionOptions{}
+	probe, _ := d.SubscribeProbes("canary", []string{ArticleContentType}, options)
+	monitor, _ := d.Subscribe("192.168.1.3", []string{ArticleContentType}, true, options)
+
+	go d.Start()
+	defer d.Stop()
+
+	d.Probe(NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_canary",
+		SubscriptionType: ArticleContentType,
+		IsE2ETest:        true,
+	}, &Target{SubscriberID: probe.ID()})
+
+	select {
+	case msg := <-probe.Notifications():
+		assert.Contains(t, msg, `"publishReference":"tid_canary"`)
+	case <-time.After(time.Second):
+		t.Fatal("The probe should be forwarded without waiting the delay")
+	}
+	assert.Eventually(t, func() bool { return d.Pending() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, monitor.Notifications())
+	assert.Empty(t, h.Notifications())
+	assert.Empty(t, traces.Traces("tid_canary"))
+	assert.Empty(t, watcher.Traces())
+
+	d.Redispatch([]NotificationModel{{ID: "http://www.ft.com/thing/1", PublishReference: "tid_1", SubscriptionType: ArticleContentType}}, nil, true)
+	select {
+	case msg := <-monitor.Notifications():
+		assert.Contains(t, msg, `"publishReference":"tid_1"`)
+	case <-time.After(time.Second):
+		t.Fatal("The notification should be forwarded to the monitor")
+	}
+	assert.Empty(t, probe.Notifications(), "The probe subscriber should only receive the probes")
+	assert.Equal(t, []Subscriber{monitor}, d.Subscribers(), "The probe subscriber should not be listed")
+}
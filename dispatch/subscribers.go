@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/Financial-Times/notifications-push/v5/access"
@@ -30,6 +31,13 @@ type Subscriber interface {
 	Since() time.Time
 	SubTypes() []string
 	Options() *access.NotificationSubscriptionOptions
+	Counters() *SubscriberCounters
+}
+
+// FilterableSubscriber is a subscriber whose subscription types can be changed while it is connected
+type FilterableSubscriber interface {
+	Subscriber
+	SetSubTypes(subTypes []string)
 }
 
 type NotificationConsumer interface {
@@ -43,8 +51,11 @@ type StandardSubscriber struct {
 	notificationChannel chan string
 	addr                string
 	sinceTime           time.Time
+	typesLock           sync.RWMutex
 	acceptedTypes       []string
 	subscriberOptions   *access.NotificationSubscriptionOptions
+	counters            *SubscriberCounters
+	frames              *frameTraces
 }
 
 // NewStandardSubscriber returns a new instance of a standard subscriber
@@ -62,6 +73,8 @@ func NewStandardSubscriber(address string, subTypes []string, options *access.No
 		sinceTime:           time.Now(),
 		acceptedTypes:       subTypes,
 		subscriberOptions:   options,
+		counters:            newSubscriberCounters(),
+		frames:              newFrameTraces(),
 	}, nil
 }
 
@@ -78,9 +91,18 @@ func (s *StandardSubscriber) Address() string {
 
 // SubTypes returns the accepted subscription type for which notifications are returned
 func (s *StandardSubscriber) SubTypes() []string {
+	s.typesLock.RLock()
+	defer s.typesLock.RUnlock()
 	return s.acceptedTypes
 }
 
+// SetSubTypes changes the accepted subscription types, the notifications already queued are still returned
+func (s *StandardSubscriber) SetSubTypes(subTypes []string) {
+	s.typesLock.Lock()
+	defer s.typesLock.Unlock()
+	s.acceptedTypes = subTypes
+}
+
 // Since returns the time since a subscriber have been registered
 func (s *StandardSubscriber) Since() time.Time {
 	return s.sinceTime
@@ -96,6 +118,11 @@ func (s *StandardSubscriber) Options() *access.NotificationSubscriptionOptions {
 	return s.subscriberOptions
 }
 
+// Counters returns the delivery counters of the subscriber
+func (s *StandardSubscriber) Counters() *SubscriberCounters {
+	return s.counters
+}
+
 // Send tries to send notification to the subscriber.
 // It removes the monitoring fields from the notification. Serializes it as string and pushes it to the subscriber
 func (s *StandardSubscriber) Send(n NotificationResponse) error {
@@ -103,20 +130,27 @@ func (s *StandardSubscriber) Send(n NotificationResponse) error {
 	if err != nil {
 		return err
 	}
+	s.frames.add(msg, n.SpanContext)
 	select {
 	case s.notificationChannel <- msg:
 		return nil
 	default:
+		s.frames.discard()
 		return ErrSubLagging
 	}
 }
 
 func buildStandardNotificationMsg(n NotificationResponse) (string, error) {
+	return buildNotificationMsg(standardView(n))
+}
+
+// standardView removes the monitoring fields, which are only sent to the monitor subscribers
+func standardView(n NotificationResponse) NotificationResponse {
 	n.PublishReference = ""
 	n.LastModified = ""
 	n.NotificationDate = ""
-
-	return buildNotificationMsg(n)
+	n.Redelivered = false
+	return n
 }
 
 func buildNotificationMsg(n NotificationResponse) (string, error) {
@@ -149,8 +183,11 @@ type MonitorSubscriber struct {
 	notificationChannel chan string
 	addr                string
 	sinceTime           time.Time
+	typesLock           sync.RWMutex
 	acceptedTypes       []string
 	subscriberOptions   *access.NotificationSubscriptionOptions
+	counters            *SubscriberCounters
+	frames              *frameTraces
 }
 
 func (m *MonitorSubscriber) ID() string {
@@ -170,14 +207,28 @@ func (m *MonitorSubscriber) Since() time.Time {
 }
 
 func (m *MonitorSubscriber) SubTypes() []string {
+	m.typesLock.RLock()
+	defer m.typesLock.RUnlock()
 	return m.acceptedTypes
 }
 
+// SetSubTypes changes the accepted subscription types, the notifications already queued are still returned
+func (m *MonitorSubscriber) SetSubTypes(subTypes []string) {
+	m.typesLock.Lock()
+	defer m.typesLock.Unlock()
+	m.acceptedTypes = subTypes
+}
+
 // Options returns if the subscriber's options
 func (m *MonitorSubscriber) Options() *access.NotificationSubscriptionOptions {
 	return m.subscriberOptions
 }
 
+// Counters returns the delivery counters of the subscriber
+func (m *MonitorSubscriber) Counters() *SubscriberCounters {
+	return m.counters
+}
+
 func (m *MonitorSubscriber) Send(n NotificationResponse) error {
 	// -- set subscriberId for NPM traceability only for monitor mode subscribers
 	n.SubscriberID = m.ID()
@@ -185,10 +236,12 @@ func (m *MonitorSubscriber) Send(n NotificationResponse) error {
 	if err != nil {
 		return err
 	}
+	m.frames.add(msg, n.SpanContext)
 	select {
 	case m.notificationChannel <- msg:
 		return nil
 	default:
+		m.frames.discard()
 		return ErrSubLagging
 	}
 }
@@ -208,6 +261,8 @@ func NewMonitorSubscriber(address string, subTypes []string, options *access.Not
 		sinceTime:           time.Now(),
 		acceptedTypes:       subTypes,
 		subscriberOptions:   options,
+		counters:            newSubscriberCounters(),
+		frames:              newFrameTraces(),
 	}, nil
 }
 
@@ -217,29 +272,74 @@ func buildMonitorNotificationMsg(n NotificationResponse) (string, error) {
 
 // MarshalJSON returns the JSON representation of a StandardSubscriber
 func (s *StandardSubscriber) MarshalJSON() ([]byte, error) {
-	return json.Marshal(newSubscriberPayload(s))
+	return json.Marshal(NewSubscriberPayload(s))
 }
 
 // MarshalJSON returns the JSON representation of a MonitorSubscriber
 func (m *MonitorSubscriber) MarshalJSON() ([]byte, error) {
-	return json.Marshal(newSubscriberPayload(m))
+	return json.Marshal(NewSubscriberPayload(m))
 }
 
 // SubscriberPayload is the JSON representation of a generic subscriber
 type SubscriberPayload struct {
-	ID                 string `json:"id"`
-	Address            string `json:"address"`
-	Since              string `json:"since"`
-	ConnectionDuration string `json:"connectionDuration"`
-	Type               string `json:"type"`
-}
-
-func newSubscriberPayload(s Subscriber) *SubscriberPayload {
-	return &SubscriberPayload{
-		ID:                 s.ID(),
-		Address:            s.Address(),
-		Since:              s.Since().Format(time.StampMilli),
-		ConnectionDuration: time.Since(s.Since()).String(),
-		Type:               reflect.TypeOf(s).Elem().String(),
+	ID                   string                     `json:"id"`
+	Address              string                     `json:"address"`
+	Since                string                     `json:"since"`
+	ConnectionDuration   string                     `json:"connectionDuration"`
+	Type                 string                     `json:"type"`
+	SubscriptionTypes    []string                   `json:"subscriptionTypes"`
+	Monitor              bool                       `json:"monitor"`
+	Options              SubscriptionOptionsPayload `json:"options"`
+	NotificationsSent    uint64                     `json:"notificationsSent"`
+	NotificationsSkipped map[string]uint64          `json:"notificationsSkipped"`
+	NotificationsLagging uint64                     `json:"notificationsDroppedLagging"`
+	NotificationsFailed  uint64                     `json:"notificationsFailed"`
+	NotificationsAcked   uint64                     `json:"notificationsAcknowledged,omitempty"`
+	BytesWritten         uint64                     `json:"bytesWritten"`
+	LastNotification     string                     `json:"lastNotification,omitempty"`
+	LastHeartbeat        string                     `json:"lastHeartbeat,omitempty"`
+	BufferOccupancy      int                        `json:"bufferOccupancy"`
+	BufferSize           int                        `json:"bufferSize"`
+}
+
+// SubscriptionOptionsPayload is the JSON representation of the options resolved from the subscriber API key policies
+type SubscriptionOptionsPayload struct {
+	ReceiveAdvancedNotifications bool `json:"receiveAdvancedNotifications"`
+	ReceiveInternalUnstable      bool `json:"receiveInternalUnstable"`
+}
+
+// NewSubscriberPayload returns the JSON representation of the subscriber together with its delivery counters
+func NewSubscriberPayload(s Subscriber) *SubscriberPayload {
+	counters := s.Counters().Snapshot()
+	_, isMonitor := s.(*MonitorSubscriber)
+	payload := &SubscriberPayload{
+		ID:                   s.ID(),
+		Address:              s.Address(),
+		Since:                s.Since().Format(time.StampMilli),
+		ConnectionDuration:   time.Since(s.Since()).String(),
+		Type:                 reflect.TypeOf(s).Elem().String(),
+		SubscriptionTypes:    s.SubTypes(),
+		Monitor:              isMonitor,
+		NotificationsSent:    counters.Sent,
+		NotificationsSkipped: counters.Skipped,
+		NotificationsLagging: counters.Lagging,
+		NotificationsFailed:  counters.Failed,
+		NotificationsAcked:   counters.Acknowledged,
+		BytesWritten:         counters.BytesWritten,
+		BufferOccupancy:      len(s.Notifications()),
+		BufferSize:           cap(s.Notifications()),
+	}
+	if options := s.Options(); options != nil {
+		payload.Options = SubscriptionOptionsPayload{
+			ReceiveAdvancedNotifications: options.ReceiveAdvancedNotifications,
+			ReceiveInternalUnstable:      options.ReceiveInternalUnstable,
+		}
+	}
+	if !counters.LastNotification.IsZero() {
+		payload.LastNotification = counters.LastNotification.Format(time.StampMilli)
+	}
+	if !counters.LastHeartbeat.IsZero() {
+		payload.LastHeartbeat = counters.LastHeartbeat.Format(time.StampMilli)
 	}
+	return payload
 }
@@ -0,0 +1,213 @@
+package dispatch
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// delivery outcomes of a notification for a subscriber
+const (
+	OutcomeSent                    = "sent"
+	OutcomeSkippedType             = "skipped-type"
+	OutcomeSkippedPolicy           = "skipped-policy"
+	OutcomeSkippedE2E              = "skipped-e2e"
+	OutcomeSkippedInternalUnstable = "skipped-internal-unstable"
+	OutcomeLagging                 = "lagging"
+	OutcomeFailed                  = "failed"
+)
+
+// DeliveryTrace records the decisions taken by the dispatcher for a single notification.
+type DeliveryTrace struct {
+	TransactionID    string               `json:"transactionId"`
+	Notification     NotificationResponse `json:"notification"`
+	SubscriptionType string               `json:"subscriptionType,omitempty"`
+	EditorialDesk    string               `json:"editorialDesk,omitempty"`
+	IsE2ETest        bool                 `json:"isE2ETest"`
+	ReceivedAt       time.Time            `json:"receivedAt"`
+	DelayEndedAt     *time.Time           `json:"delayEndedAt,omitempty"`
+	FanOutStartedAt  *time.Time           `json:"fanOutStartedAt,omitempty"`
+	FanOutEndedAt    *time.Time           `json:"fanOutEndedAt,omitempty"`
+	Policy           *PolicyDecision      `json:"policy,omitempty"`
+	Error            string               `json:"error,omitempty"`
+	Subscribers      []SubscriberOutcome  `json:"subscribers"`
+
+	id uint64
+	// probe is set for the notifications of Dispatcher.Probe, which are neither recorded nor logged as deliveries
+	probe bool
+}
+
+// PolicyDecision is the result of the OPA content policy evaluation for a notification.
+type PolicyDecision struct {
+	Allow      bool     `json:"allow"`
+	Reasons    []string `json:"reasons,omitempty"`
+	DecisionID string   `json:"decisionId,omitempty"`
+}
+
+// SubscriberOutcome is what happened to a notification for a single subscriber.
+type SubscriberOutcome struct {
+	SubscriberID string `json:"subscriberId"`
+	Address      string `json:"address"`
+	Outcome      string `json:"outcome"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Count returns the number of subscribers with the given outcome.
+func (t DeliveryTrace) Count(outcome string) int {
+	c := 0
+	for _, s := range t.Subscribers {
+		if s.Outcome == outcome {
+			c++
+		}
+	}
+	return c
+}
+
+func newDeliveryTrace(n NotificationModel, receivedAt time.Time) *DeliveryTrace {
+	return &DeliveryTrace{
+		TransactionID:    n.PublishReference,
+		Notification:     CreateNotificationResponse(n, advancedOptions),
+		SubscriptionType: n.SubscriptionType,
+		EditorialDesk:    n.EditorialDesk,
+		IsE2ETest:        n.IsE2ETest,
+		ReceivedAt:       receivedAt,
+		Subscribers:      []SubscriberOutcome{},
+	}
+}
+
+func (t *DeliveryTrace) addOutcome(s Subscriber, outcome string, err error) {
+	o := SubscriberOutcome{
+		SubscriberID: s.ID(),
+		Address:      s.Address(),
+		Outcome:      outcome,
+	}
+	if err != nil {
+		o.Error = err.Error()
+	}
+	t.Subscribers = append(t.Subscribers, o)
+}
+
+func timestamp(t time.Time) *time.Time {
+	return &t
+}
+
+// TraceStore keeps the delivery traces of the last notifications received by the dispatcher.
+type TraceStore struct {
+	mutex  *sync.RWMutex
+	traces []DeliveryTrace
+	start  int
+	count  int
+	nextID uint64
+}
+
+// NewTraceStore creates a store keeping the traces of the last size notifications.
+func NewTraceStore(size int) *TraceStore {
+	if size < 1 {
+		size = 1
+	}
+	return &TraceStore{
+		mutex:  &sync.RWMutex{},
+		traces: make([]DeliveryTrace, size),
+		nextID: 1,
+	}
+}
+
+// record saves a copy of the trace, replacing the previously recorded state of the same notification.
+func (s *TraceStore) record(t *DeliveryTrace) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := *t
+	c.Subscribers = append([]SubscriberOutcome(nil), t.Subscribers...)
+
+	if t.id == 0 {
+		t.id = s.nextID
+		c.id = t.id
+		s.nextID++
+		s.push(c)
+		return
+	}
+	for i := s.count - 1; i >= 0; i-- {
+		idx := (s.start + i) % len(s.traces)
+		if s.traces[idx].id == t.id {
+			s.traces[idx] = c
+			return
+		}
+	}
+	s.push(c)
+}
+
+func (s *TraceStore) push(t DeliveryTrace) {
+	if s.count < len(s.traces) {
+		s.traces[(s.start+s.count)%len(s.traces)] = t
+		s.count++
+		return
+	}
+	s.traces[s.start] = t
+	s.start = (s.start + 1) % len(s.traces)
+}
+
+// Traces returns the delivery traces of the notifications with the given transaction ID, most recent first.
+func (s *TraceStore) Traces(tid string) []DeliveryTrace {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var traces []DeliveryTrace
+	for i := s.count - 1; i >= 0; i-- {
+		t := s.traces[(s.start+i)%len(s.traces)]
+		if t.TransactionID == tid {
+			traces = append(traces, t)
+		}
+	}
+	return traces
+}
+
+// Recent returns the delivery traces of the last notifications, most recent first, at most limit of them.
+func (s *TraceStore) Recent(limit int) []DeliveryTrace {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if limit > s.count {
+		limit = s.count
+	}
+	traces := make([]DeliveryTrace, 0, limit)
+	for i := s.count - 1; i >= s.count-limit; i-- {
+		traces = append(traces, s.traces[(s.start+i)%len(s.traces)])
+	}
+	return traces
+}
+
+const traceWatcherBuffer = 64
+
+// TraceWatcher receives the delivery trace of every notification forwarded by the dispatcher.
+type TraceWatcher struct {
+	traces  chan DeliveryTrace
+	dropped uint64
+}
+
+// Traces returns the channel of completed delivery traces
+func (w *TraceWatcher) Traces() <-chan DeliveryTrace {
+	return w.traces
+}
+
+// Dropped returns the number of traces discarded because the watcher was not keeping up
+func (w *TraceWatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+func (w *TraceWatcher) send(t DeliveryTrace) {
+	select {
+	case w.traces <- t:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
@@ -0,0 +1,174 @@
+package dispatch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+type stubAgent struct {
+	result *access.ContentPolicyResult
+	err    error
+}
+
+func (a *stubAgent) EvaluateContentPolicy(_ map[string]interface{}) (*access.ContentPolicyResult, error) {
+	return a.result, a.err
+}
+
+func TestDeliveryTrace(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true, DecisionID: "decision-1"}}
+	traces := NewTraceStore(10)
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), traces, agent, nil, l)
+
+	options := &access.NotificationSubscriptionOptions{}
+	article, _ := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+	audio, _ := d.Subscribe("192.168.1.3", []string{AudioContentType}, false, options)
+	monitor, _ := d.Subscribe("192.168.1.4", []string{ArticleContentType}, true, options)
+	lagging, _ := d.Subscribe("192.168.1.5", []string{ArticleContentType}, false, options)
+	for i := 0; i < notificationBuffer; i++ {
+		require.NoError(t, lagging.(NotificationConsumer).Send(NotificationResponse{}))
+	}
+
+	go d.Start()
+	defer d.Stop()
+
+	n := NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentCreateType,
+		PublishReference: "tid_trace",
+		SubscriptionType: ArticleContentType,
+	}
+	d.Send(n)
+	<-article.Notifications()
+
+	var trace DeliveryTrace
+	require.Eventually(t, func() bool {
+		ts := d.Traces("tid_trace")
+		if len(ts) != 1 || ts[0].FanOutEndedAt == nil {
+			return false
+		}
+		trace = ts[0]
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, ContentCreateType, trace.Notification.Type)
+	assert.NotEmpty(t, trace.Notification.NotificationDate)
+	assert.Equal(t, &PolicyDecision{Allow: true, DecisionID: "decision-1"}, trace.Policy)
+	assert.NotNil(t, trace.DelayEndedAt)
+	assert.NotNil(t, trace.FanOutStartedAt)
+
+	outcomes := map[string]string{}
+	for _, o := range trace.Subscribers {
+		outcomes[o.SubscriberID] = o.Outcome
+	}
+	assert.Equal(t, map[string]string{
+		article.ID(): OutcomeSent,
+		audio.ID():   OutcomeSkippedType,
+		monitor.ID(): OutcomeSent,
+		lagging.ID(): OutcomeLagging,
+	}, outcomes)
+	assert.Equal(t, 2, trace.Count(OutcomeSent))
+}
+
+func TestDeliveryTracePolicy(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	options := &access.NotificationSubscriptionOptions{}
+
+	tests := map[string]struct {
+		agent            *stubAgent
+		notification     NotificationModel
+		expectedOutcome  string
+		expectedError    string
+		expectedDecision *PolicyDecision
+	}{
+		"blocked by policy": {
+			agent:            &stubAgent{result: &access.ContentPolicyResult{Allow: false, Reasons: []string{"blocked desk"}}},
+			notification:     NotificationModel{PublishReference: "tid", SubscriptionType: ArticleContentType},
+			expectedOutcome:  OutcomeSkippedPolicy,
+			expectedDecision: &PolicyDecision{Allow: false, Reasons: []string{"blocked desk"}},
+		},
+		"e2e test notification": {
+			agent:            &stubAgent{result: &access.ContentPolicyResult{Allow: true}},
+			notification:     NotificationModel{PublishReference: "tid", SubscriptionType: ArticleContentType, IsE2ETest: true},
+			expectedOutcome:  OutcomeSkippedE2E,
+			expectedDecision: &PolicyDecision{Allow: true},
+		},
+		"related content": {
+			agent:            &stubAgent{result: &access.ContentPolicyResult{Allow: true}},
+			notification:     NotificationModel{PublishReference: "tid", SubscriptionType: ArticleContentType, Type: RelatedContentType},
+			expectedOutcome:  OutcomeSkippedInternalUnstable,
+			expectedDecision: &PolicyDecision{Allow: true},
+		},
+		"policy evaluation error": {
+			agent:         &stubAgent{err: fmt.Errorf("opa unavailable")},
+			notification:  NotificationModel{PublishReference: "tid", SubscriptionType: ArticleContentType},
+			expectedError: "opa unavailable",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			traces := NewTraceStore(1)
+			d := NewDispatcher(0, NewHistory(1, OrderByArrival), traces, test.agent, nil, l)
+			_, _ = d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+
+			trace := newDeliveryTrace(test.notification, time.Now())
+			d.forwardToSubscribers(test.notification, trace, nil)
+
+			recorded := d.Traces("tid")
+			require.Len(t, recorded, 1)
+			assert.Equal(t, test.expectedDecision, recorded[0].Policy)
+			assert.Equal(t, test.expectedError, recorded[0].Error)
+			if test.expectedOutcome == "" {
+				assert.Empty(t, recorded[0].Subscribers)
+				return
+			}
+			require.Len(t, recorded[0].Subscribers, 1)
+			assert.Equal(t, test.expectedOutcome, recorded[0].Subscribers[0].Outcome)
+		})
+	}
+}
+
+func TestTraceStore(t *testing.T) {
+	t.Parallel()
+
+	s := NewTraceStore(2)
+
+	first := newDeliveryTrace(NotificationModel{PublishReference: "tid_1"}, time.Now())
+	s.record(first)
+	first.Error = "updated"
+	s.record(first)
+	require.Len(t, s.Traces("tid_1"), 1, "Recording the same trace again should replace it")
+	assert.Equal(t, "updated", s.Traces("tid_1")[0].Error)
+
+	s.record(newDeliveryTrace(NotificationModel{PublishReference: "tid_2"}, time.Now()))
+	s.record(newDeliveryTrace(NotificationModel{PublishReference: "tid_2"}, time.Now()))
+	assert.Empty(t, s.Traces("tid_1"), "The oldest trace should be evicted")
+	assert.Len(t, s.Traces("tid_2"), 2)
+
+	s.record(newDeliveryTrace(NotificationModel{PublishReference: "tid_3"}, time.Now()))
+	recent := s.Recent(5)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "tid_3", recent[0].TransactionID)
+	assert.Equal(t, "tid_2", recent[1].TransactionID)
+	assert.Len(t, s.Recent(1), 1)
+
+	var nilStore *TraceStore
+	nilStore.record(first)
+	assert.Nil(t, nilStore.Traces("tid_1"))
+	assert.Nil(t, nilStore.Recent(5))
+}
@@ -0,0 +1,87 @@
+package dispatch
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedSubscriber gives the trace context of the notifications read from the subscriber channel
+type TracedSubscriber interface {
+	// TakeSpanContext returns the trace context of the serialised notification and forgets it
+	TakeSpanContext(msg string) trace.SpanContext
+}
+
+// frameTraces keeps the trace context of the notifications waiting in a subscriber buffer, in the order they were sent to it,
+// so identical notifications are told apart by their position. It holds at most as many contexts as the buffer holds notifications,
+// and the contexts of the notifications read from the buffer without being taken are dropped by the next take.
+// All methods are safe to call on a nil value, which keeps nothing.
+type frameTraces struct {
+	mutex  *sync.Mutex
+	frames []frameTrace
+}
+
+type frameTrace struct {
+	msg string
+	sc  trace.SpanContext
+}
+
+func newFrameTraces() *frameTraces {
+	return &frameTraces{mutex: &sync.Mutex{}}
+}
+
+// add keeps the trace context of the notification before it is sent to the subscriber channel
+func (f *frameTraces) add(msg string, sc trace.SpanContext) {
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// the buffer holds at most notificationBuffer notifications besides the one being sent
+	if len(f.frames) > notificationBuffer {
+		f.frames = f.frames[1:]
+	}
+	f.frames = append(f.frames, frameTrace{msg: msg, sc: sc})
+}
+
+// discard forgets the trace context of the last notification added, once it could not be sent to the subscriber channel
+func (f *frameTraces) discard() {
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.frames) > 0 {
+		f.frames = f.frames[:len(f.frames)-1]
+	}
+}
+
+// take returns the trace context of the oldest notification kept matching msg, which is the one read from the buffer,
+// and forgets it together with the older ones.
+func (f *frameTraces) take(msg string) trace.SpanContext {
+	if f == nil {
+		return trace.SpanContext{}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, frame := range f.frames {
+		if frame.msg == msg {
+			f.frames = f.frames[i+1:]
+			return frame.sc
+		}
+	}
+	return trace.SpanContext{}
+}
+
+// TakeSpanContext returns the trace context of the serialised notification and forgets it
+func (s *StandardSubscriber) TakeSpanContext(msg string) trace.SpanContext {
+	return s.frames.take(msg)
+}
+
+// TakeSpanContext returns the trace context of the serialised notification and forgets it
+func (m *MonitorSubscriber) TakeSpanContext(msg string) trace.SpanContext {
+	return m.frames.take(msg)
+}
@@ -0,0 +1,90 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+)
+
+func TestDispatchSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+
+	options := &access.NotificationSubscriptionOptions{}
+	s, _ := d.Subscribe("192.168.1.2", []string{ArticleContentType}, false, options)
+
+	go d.Start()
+	defer d.Stop()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "HandleMessage")
+	d.Send(NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_traced",
+		SubscriptionType: ArticleContentType,
+		SpanContext:      trace.SpanContextFromContext(ctx),
+	})
+
+	msg := <-s.Notifications()
+	parent.End()
+
+	sc := s.(TracedSubscriber).TakeSpanContext(msg)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, parent.SpanContext().TraceID(), sc.TraceID())
+	assert.False(t, s.(TracedSubscriber).TakeSpanContext(msg).IsValid(), "The trace context should be forgotten once taken")
+
+	require.Eventually(t, func() bool { return len(recorder.Ended()) == 4 }, time.Second, 10*time.Millisecond)
+	names := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+		names[span.Name()] = span
+	}
+	require.Contains(t, names, "FanOut")
+	assert.Contains(t, names, "DelayWait")
+	assert.Equal(t, names["FanOut"].SpanContext(), sc)
+	assert.Equal(t, sc.SpanID(), names["EvaluateContentPolicy"].Parent().SpanID())
+}
+
+func TestFrameTraces(t *testing.T) {
+	t.Parallel()
+
+	first := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	second := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{2}, SpanID: trace.SpanID{2}})
+	frames := newFrameTraces()
+	frames.add("same", first)
+	frames.add("untraced", trace.SpanContext{})
+	frames.add("same", second)
+	frames.add("lagging", first)
+	frames.discard()
+
+	assert.Equal(t, first, frames.take("same"), "Identical notifications should be taken in the order they were sent")
+	assert.Equal(t, second, frames.take("same"), "The notification read without taking its context should be skipped")
+	assert.False(t, frames.take("lagging").IsValid(), "The discarded context should be forgotten")
+	assert.Empty(t, frames.frames)
+
+	for i := 0; i <= 2*notificationBuffer; i++ {
+		frames.add(string(rune('a'+i)), first)
+	}
+	assert.Len(t, frames.frames, notificationBuffer+1)
+	assert.False(t, frames.take("a").IsValid(), "The oldest contexts should be dropped beyond the buffer size")
+
+	var nilFrames *frameTraces
+	nilFrames.add("a", first)
+	nilFrames.discard()
+	assert.False(t, nilFrames.take("a").IsValid())
+}
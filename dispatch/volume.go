@@ -0,0 +1,125 @@
+package dispatch
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+)
+
+const (
+	// VolumeWindow is the period over which the notification volume is kept
+	VolumeWindow = 24 * time.Hour
+	// VolumeBucket is the period counted by each point of the volume time series
+	VolumeBucket = time.Minute
+)
+
+// VolumeKey is the breakdown of the notification volume.
+// Type is the last segment of the notification type, UPDATE or DELETE for instance,
+// Policy the decision of the content policy: allow, deny or error when it could not be evaluated.
+type VolumeKey struct {
+	SubscriptionType string
+	Type             string
+	EditorialDesk    string
+	Publication      string
+	Policy           string
+}
+
+// VolumeCounts counts the notifications dispatched during the minute starting at Start by volume key
+type VolumeCounts struct {
+	Start  time.Time
+	Counts map[VolumeKey]int
+}
+
+type volumeMinute struct {
+	minute int64
+	counts map[VolumeKey]int
+}
+
+// volumeCounter counts the dispatched notifications per minute over a rolling window.
+// All methods are safe to call on a nil value, which counts nothing.
+type volumeCounter struct {
+	mutex   *sync.Mutex
+	buckets []volumeMinute
+}
+
+func newVolumeCounter(window time.Duration) *volumeCounter {
+	return &volumeCounter{
+		mutex:   &sync.Mutex{},
+		buckets: make([]volumeMinute, int(window/VolumeBucket)),
+	}
+}
+
+// record counts a notification once its fan-out has ended
+func (v *volumeCounter) record(n NotificationModel, trace *DeliveryTrace, at time.Time) {
+	if v == nil {
+		return
+	}
+	key := VolumeKey{
+		SubscriptionType: n.SubscriptionType,
+		Type:             path.Base(n.Type),
+		EditorialDesk:    n.EditorialDesk,
+		Policy:           metrics.PolicyError,
+	}
+	if n.Publication != nil {
+		key.Publication, _ = n.Publication.OnlyOneOrPink()
+	}
+	if trace.Policy != nil {
+		key.Policy = metrics.PolicyDeny
+		if trace.Policy.Allow {
+			key.Policy = metrics.PolicyAllow
+		}
+	}
+
+	minute := at.Unix() / int64(VolumeBucket/time.Second)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	b := &v.buckets[minute%int64(len(v.buckets))]
+	if b.minute > minute {
+		// the minute has already rolled out of the window
+		return
+	}
+	if b.minute != minute || b.counts == nil {
+		*b = volumeMinute{minute: minute, counts: map[VolumeKey]int{}}
+	}
+	b.counts[key]++
+}
+
+// series returns the counts of every minute from the one containing since to the one containing now, oldest first.
+// The minutes without notification are included with no count.
+func (v *volumeCounter) series(since time.Time, now time.Time) []VolumeCounts {
+	if v == nil {
+		return nil
+	}
+	current := now.Unix() / int64(VolumeBucket/time.Second)
+	first := since.Unix() / int64(VolumeBucket/time.Second)
+	if oldest := current - int64(len(v.buckets)) + 1; first < oldest {
+		first = oldest
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	series := make([]VolumeCounts, 0, current-first+1)
+	for minute := first; minute <= current; minute++ {
+		counts := map[VolumeKey]int{}
+		if b := v.buckets[minute%int64(len(v.buckets))]; b.minute == minute {
+			for key, count := range b.counts {
+				counts[key] = count
+			}
+		}
+		series = append(series, VolumeCounts{
+			Start:  time.Unix(minute*int64(VolumeBucket/time.Second), 0).UTC(),
+			Counts: counts,
+		})
+	}
+	return series
+}
+
+// Volume returns the number of notifications dispatched every minute since the given time, within the volume window.
+// Notifications sent by the canary are not counted.
+func (d *Dispatcher) Volume(since time.Time) []VolumeCounts {
+	return d.volume.series(since, time.Now())
+}
@@ -0,0 +1,112 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/publication"
+	"github.com/google/uuid"
+)
+
+func TestVolumeCounter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 7, 31, 10, 30, 15, 0, time.UTC)
+	counter := newVolumeCounter(time.Hour)
+
+	article := NotificationModel{
+		Type:             ContentUpdateType,
+		SubscriptionType: ArticleContentType,
+		EditorialDesk:    "/FT/WorldNews",
+		Publication:      &publication.Publications{UUIDS: []uuid.UUID{uuid.MustParse(publication.PinkFt)}},
+	}
+	allowed := &DeliveryTrace{Policy: &PolicyDecision{Allow: true}}
+	denied := &DeliveryTrace{Policy: &PolicyDecision{Allow: false}}
+
+	counter.record(article, allowed, now.Add(-2*time.Minute))
+	counter.record(article, allowed, now)
+	counter.record(article, allowed, now)
+	counter.record(article, denied, now)
+	counter.record(NotificationModel{Type: ContentDeleteType}, &DeliveryTrace{}, now)
+	counter.record(article, allowed, now.Add(-2*time.Hour))
+
+	series := counter.series(now.Add(-2*time.Minute), now)
+	require.Len(t, series, 3)
+	assert.Equal(t, time.Date(2024, 7, 31, 10, 28, 0, 0, time.UTC), series[0].Start)
+	assert.Equal(t, time.Date(2024, 7, 31, 10, 30, 0, 0, time.UTC), series[2].Start)
+
+	allowedKey := VolumeKey{SubscriptionType: ArticleContentType, Type: "UPDATE", EditorialDesk: "/FT/WorldNews", Publication: publication.PinkFt, Policy: "allow"}
+	assert.Equal(t, map[VolumeKey]int{allowedKey: 1}, series[0].Counts)
+	assert.Empty(t, series[1].Counts)
+	assert.Equal(t, map[VolumeKey]int{
+		allowedKey: 2,
+		{SubscriptionType: ArticleContentType, Type: "UPDATE", EditorialDesk: "/FT/WorldNews", Publication: publication.PinkFt, Policy: "deny"}: 1,
+		{Type: "DELETE", Policy: "error"}: 1,
+	}, series[2].Counts)
+
+	// the series is limited to the window and the minutes which rolled out of it are not counted
+	series = counter.series(now.Add(-3*time.Hour), now)
+	assert.Len(t, series, 60)
+	total := 0
+	for _, m := range series {
+		for _, count := range m.Counts {
+			total += count
+		}
+	}
+	assert.Equal(t, 5, total)
+
+	var nilCounter *volumeCounter
+	nilCounter.record(article, allowed, now)
+	assert.Nil(t, nilCounter.series(now.Add(-time.Hour), now))
+}
+
+func TestDispatcherVolume(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := NewDispatcher(0, NewHistory(10, OrderByArrival), nil, agent, nil, l)
+
+	go d.Start()
+	defer d.Stop()
+
+	d.Send(NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_volume",
+		SubscriptionType: ArticleContentType,
+		EditorialDesk:    "/FT/Money",
+	})
+	d.Probe(NotificationModel{Type: ContentUpdateType, PublishReference: "tid_canary"}, &Target{SubscriberID: "canary"})
+
+	key := VolumeKey{SubscriptionType: ArticleContentType, Type: "UPDATE", EditorialDesk: "/FT/Money", Policy: "allow"}
+	require.Eventually(t, func() bool {
+		series := d.Volume(time.Now().Add(-time.Minute))
+		return len(series) > 0 && series[len(series)-1].Counts[key] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	redispatched := NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             ContentUpdateType,
+		PublishReference: "tid_volume",
+		SubscriptionType: ArticleContentType,
+		EditorialDesk:    "/FT/Money",
+	}
+	d.Redispatch([]NotificationModel{redispatched}, nil, true)
+	d.Redispatch([]NotificationModel{redispatched}, &Target{SubscriberID: "subscriber"}, true)
+	require.Eventually(t, func() bool { return d.Pending() == 0 }, time.Second, 10*time.Millisecond)
+
+	total := 0
+	for _, m := range d.Volume(time.Now().Add(-time.Minute)) {
+		for k, count := range m.Counts {
+			assert.Equal(t, key, k, "Probes should not be counted")
+			total += count
+		}
+	}
+	assert.Equal(t, 1, total, "Re-dispatched notifications should not be counted again")
+}
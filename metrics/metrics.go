@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all the service metrics
+const Namespace = "notifications_push"
+
+// policy evaluation results
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+	PolicyError = "error"
+)
+
+// frames written to the subscriber streams
+const (
+	FrameNotification = "notification"
+	FrameHeartbeat    = "heartbeat"
+)
+
+// canary probe results
+const (
+	CanarySuccess = "success"
+	CanaryFailure = "failure"
+)
+
+var latencyBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// Metrics holds the Prometheus metrics of the service.
+// The recording methods are safe to call on a nil value, which records nothing.
+type Metrics struct {
+	registry          *prometheus.Registry
+	messages          *prometheus.CounterVec
+	policyEvaluations *prometheus.CounterVec
+	fanOutDuration    prometheus.Histogram
+	writeDuration     *prometheus.HistogramVec
+	canaryProbes      *prometheus.CounterVec
+	canaryRoundTrip   prometheus.Histogram
+}
+
+// New creates the service metrics in a dedicated registry, together with the Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "kafka_messages_total",
+			Help:      "Kafka messages consumed, by the outcome of their processing.",
+		}, []string{"outcome"}),
+		policyEvaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "opa_evaluations_total",
+			Help:      "OPA content policy evaluations, by result.",
+		}, []string{"result"}),
+		fanOutDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "fan_out_duration_seconds",
+			Help:      "Time taken to forward a notification to all the subscribers.",
+			Buckets:   latencyBuckets,
+		}),
+		writeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "sse_write_duration_seconds",
+			Help:      "Time taken to write and flush a frame to a subscriber stream, by frame.",
+			Buckets:   latencyBuckets,
+		}, []string{"frame"}),
+		canaryProbes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "canary_probes_total",
+			Help:      "Canary notifications injected in the dispatcher, by result.",
+		}, []string{"result"}),
+		canaryRoundTrip: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "canary_round_trip_seconds",
+			Help:      "Time taken by a canary notification to come back from the dispatcher to the canary subscriber.",
+			Buckets:   latencyBuckets,
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.messages,
+		m.policyEvaluations,
+		m.fanOutDuration,
+		m.writeDuration,
+		m.canaryProbes,
+		m.canaryRoundTrip,
+	)
+	return m
+}
+
+// Register adds a collector of the service state, like the dispatcher gauges
+func (m *Metrics) Register(c prometheus.Collector) error {
+	return m.registry.Register(c)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// MessageProcessed counts a consumed Kafka message
+func (m *Metrics) MessageProcessed(outcome string) {
+	if m == nil {
+		return
+	}
+	m.messages.WithLabelValues(outcome).Inc()
+}
+
+// PolicyEvaluated counts an OPA content policy evaluation
+func (m *Metrics) PolicyEvaluated(result string) {
+	if m == nil {
+		return
+	}
+	m.policyEvaluations.WithLabelValues(result).Inc()
+}
+
+// FanOutCompleted records the time taken to forward a notification to the subscribers
+func (m *Metrics) FanOutCompleted(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.fanOutDuration.Observe(d.Seconds())
+}
+
+// FrameWritten records the time taken to write a frame to a subscriber stream
+func (m *Metrics) FrameWritten(frame string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.writeDuration.WithLabelValues(frame).Observe(d.Seconds())
+}
+
+// CanaryProbed counts a canary probe, the round trip is recorded only for the successful ones
+func (m *Metrics) CanaryProbed(result string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.canaryProbes.WithLabelValues(result).Inc()
+	if result == CanarySuccess {
+		m.canaryRoundTrip.Observe(d.Seconds())
+	}
+}
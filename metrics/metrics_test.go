@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.MessageProcessed("dispatched")
+	m.MessageProcessed("dispatched")
+	m.PolicyEvaluated(PolicyDeny)
+	m.FanOutCompleted(2 * time.Millisecond)
+	m.FrameWritten(FrameHeartbeat, time.Millisecond)
+	m.CanaryProbed(CanarySuccess, time.Millisecond)
+	m.CanaryProbed(CanaryFailure, 5*time.Second)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `notifications_push_kafka_messages_total{outcome="dispatched"} 2`)
+	assert.Contains(t, body, `notifications_push_opa_evaluations_total{result="deny"} 1`)
+	assert.Contains(t, body, `notifications_push_fan_out_duration_seconds_count 1`)
+	assert.Contains(t, body, `notifications_push_sse_write_duration_seconds_count{frame="heartbeat"} 1`)
+	assert.Contains(t, body, `notifications_push_canary_probes_total{result="failure"} 1`)
+	assert.Contains(t, body, `notifications_push_canary_round_trip_seconds_count 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestNilMetrics(t *testing.T) {
+	t.Parallel()
+
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.MessageProcessed("dispatched")
+		m.PolicyEvaluated(PolicyAllow)
+		m.FanOutCompleted(time.Millisecond)
+		m.FrameWritten(FrameNotification, time.Millisecond)
+		m.CanaryProbed(CanarySuccess, time.Millisecond)
+	})
+}
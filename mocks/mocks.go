@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Financial-Times/notifications-push/v5/access"
 
@@ -67,6 +68,59 @@ func (m *Dispatcher) Unsubscribe(s dispatch.Subscriber) {
 	m.Called(s)
 }
 
+func (m *Dispatcher) Pull(cursor dispatch.Cursor, subTypes []string, monitor bool, options *access.NotificationSubscriptionOptions, limit int) ([]dispatch.NotificationResponse, dispatch.Cursor, bool) {
+	args := m.Called(cursor, subTypes, monitor, options, limit)
+	return args.Get(0).([]dispatch.NotificationResponse), args.Get(1).(dispatch.Cursor), args.Bool(2)
+}
+
+func (m *Dispatcher) HistoryUpdated() <-chan struct{} {
+	args := m.Called()
+	return args.Get(0).(<-chan struct{})
+}
+
+func (m *Dispatcher) Traces(tid string) []dispatch.DeliveryTrace {
+	args := m.Called(tid)
+	return args.Get(0).([]dispatch.DeliveryTrace)
+}
+
+func (m *Dispatcher) RecentTraces(limit int) []dispatch.DeliveryTrace {
+	args := m.Called(limit)
+	return args.Get(0).([]dispatch.DeliveryTrace)
+}
+
+func (m *Dispatcher) WatchTraces() *dispatch.TraceWatcher {
+	args := m.Called()
+	return args.Get(0).(*dispatch.TraceWatcher)
+}
+
+func (m *Dispatcher) UnwatchTraces(w *dispatch.TraceWatcher) {
+	m.Called(w)
+}
+
+func (m *Dispatcher) Redispatch(notifications []dispatch.NotificationModel, target *dispatch.Target, skipDelay bool) {
+	m.Called(notifications, target, skipDelay)
+}
+
+func (m *Dispatcher) Freeze() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *Dispatcher) Unfreeze() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *Dispatcher) Frozen() (bool, time.Time) {
+	args := m.Called()
+	return args.Bool(0), args.Get(1).(time.Time)
+}
+
+func (m *Dispatcher) Pending() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
 type transport struct {
 	ResponseStatusCode int
 	ResponseBody       string
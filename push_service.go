@@ -5,18 +5,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
 	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/canary"
 	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/Financial-Times/notifications-push/v5/pushpb"
 	"github.com/Financial-Times/notifications-push/v5/resources"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
 	"github.com/Financial-Times/service-status-go/httphandlers"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
 )
 
 type notificationSystem interface {
@@ -24,7 +32,7 @@ type notificationSystem interface {
 	Stop()
 }
 
-func startService(srv *http.Server, n notificationSystem, consumer *kafka.Consumer, msgHandler queueConsumer.MessageQueueHandler, log *logger.UPPLogger) func(time.Duration) {
+func startService(srv *http.Server, grpcSrv *grpcService, n notificationSystem, consumer *kafka.Consumer, msgHandler queueConsumer.MessageQueueHandler, log *logger.UPPLogger) func(time.Duration) {
 	go n.Start()
 
 	go consumer.Start(msgHandler.HandleMessage)
@@ -35,12 +43,19 @@ func startService(srv *http.Server, n notificationSystem, consumer *kafka.Consum
 			log.WithError(err).Error("http server")
 		}
 	}()
+	grpcSrv.start(log)
 
 	return func(timeout time.Duration) {
 		log.Info("Termination started. Quitting message consumer and notification dispatcher function.")
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		_ = srv.Shutdown(ctx)
+		// the gRPC streams are registered on the HTTP server shutdown, so they have been cancelled
+		grpcSrv.stop(ctx)
+		// a paused handler holds a message, it must let it through for the consumer to close
+		if c, ok := msgHandler.(io.Closer); ok {
+			_ = c.Close()
+		}
 		err := consumer.Close()
 		if err != nil {
 			log.WithError(err).Error("Failed to close kafka consumer")
@@ -49,22 +64,103 @@ func startService(srv *http.Server, n notificationSystem, consumer *kafka.Consum
 	}
 }
 
+// grpcService is the gRPC API, served on a port of its own alongside the HTTP server.
+// All methods are safe to call on a nil value, when the gRPC API is disabled.
+type grpcService struct {
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// createGRPCService listens on the given port for the gRPC subscriptions, it returns nil when no port is configured
+func createGRPCService(port int, s *resources.SubHandler) (*grpcService, error) {
+	if port == 0 {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer()
+	pushpb.RegisterNotificationsPushServer(server, resources.NewGRPCHandler(s))
+	return &grpcService{server: server, listener: listener}, nil
+}
+
+func (g *grpcService) start(log *logger.UPPLogger) {
+	if g == nil {
+		return
+	}
+	go func() {
+		if err := g.server.Serve(g.listener); err != nil {
+			log.WithError(err).Error("grpc server")
+		}
+	}()
+}
+
+// stop waits for the running calls to end, they are ended by force when the context is done first
+func (g *grpcService) stop(ctx context.Context) {
+	if g == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		g.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		g.server.Stop()
+	}
+}
+
 func initRouter(r *mux.Router,
 	s *resources.SubHandler,
 	resource string,
 	d *dispatch.Dispatcher,
+	streams *resources.Streams,
 	h dispatch.History,
 	hc *resources.HealthCheck,
+	m *metrics.Metrics,
+	admin *resources.AdminHandler,
 	log *logger.UPPLogger) {
 	r.HandleFunc("/"+resource+"/notifications-push", s.HandleSubscription).Methods("GET")
+	r.HandleFunc("/"+resource+"/notifications-push/ws", s.HandleWebSocket).Methods("GET")
+	r.HandleFunc("/"+resource+"/notifications", s.HandlePull).Methods("GET")
 
 	r.HandleFunc("/__health", hc.Health())
 	r.HandleFunc(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(hc.GTG))
 	r.HandleFunc(httphandlers.BuildInfoPath, httphandlers.BuildInfoHandler)
 	r.HandleFunc(httphandlers.PingPath, httphandlers.PingHandler)
+	r.Handle("/metrics", m.Handler()).Methods("GET")
 
-	r.HandleFunc("/__stats", resources.Stats(d, log)).Methods("GET")
+	r.HandleFunc("/__stats", resources.Stats(d, streams, log)).Methods("GET")
+	r.HandleFunc("/__volume", resources.Volume(d, log)).Methods("GET")
 	r.HandleFunc("/__history", resources.History(h, log)).Methods("GET")
+	r.HandleFunc("/__history/{tid}", resources.DeliveryTraces(d, log)).Methods("GET")
+
+	if admin == nil {
+		log.Info("No admin token configured, the /__admin endpoints are disabled")
+		return
+	}
+	a := r.PathPrefix("/__admin").Subrouter()
+	a.Use(admin.Authenticate)
+	a.HandleFunc("/firehose", admin.Firehose).Methods("GET")
+	a.HandleFunc("/subscribers", admin.ListSubscribers).Methods("GET")
+	a.HandleFunc("/subscribers", admin.DisconnectSubscribers).Methods("DELETE")
+	a.HandleFunc("/subscribers/{id}/throttle", admin.ThrottleSubscriber).Methods("POST")
+	a.HandleFunc("/subscribers/{id}/throttle", admin.UnthrottleSubscriber).Methods("DELETE")
+	a.HandleFunc("/subscribers/{id}/tap", admin.Tap).Methods("GET")
+	a.HandleFunc("/redispatch", admin.Redispatch).Methods("POST")
+	a.HandleFunc("/inject", admin.Inject).Methods("POST")
+	a.HandleFunc("/status", admin.Status).Methods("GET")
+	a.HandleFunc("/consumer/pause", admin.PauseConsumer).Methods("POST")
+	a.HandleFunc("/consumer/resume", admin.ResumeConsumer).Methods("POST")
+	a.HandleFunc("/dispatcher/freeze", admin.FreezeDispatcher).Methods("POST")
+	a.HandleFunc("/dispatcher/unfreeze", admin.UnfreezeDispatcher).Methods("POST")
+	a.HandleFunc("/maintenance", admin.EnableMaintenance).Methods("POST")
+	a.HandleFunc("/maintenance", admin.DisableMaintenance).Methods("DELETE")
+	a.HandleFunc("/dashboard", admin.Dashboard).Methods("GET")
+	a.HandleFunc("/dashboard/events", admin.DashboardEvents).Methods("GET")
 }
 
 func createConsumer(log *logger.UPPLogger, kafkaClusterArn, address, groupID string, topic string, lagTolerance int) (*kafka.Consumer, error) {
@@ -83,10 +179,53 @@ func createConsumer(log *logger.UPPLogger, kafkaClusterArn, address, groupID str
 	return kafka.NewConsumer(consumerConfig, kafkaTopic, log)
 }
 
-func createDispatcher(cacheDelay int, historySize int, evaluator access.Agent, log *logger.UPPLogger) (*dispatch.Dispatcher, dispatch.History) {
-	history := dispatch.NewHistory(historySize)
-	dispatcher := dispatch.NewDispatcher(time.Duration(cacheDelay)*time.Second, history, evaluator, log)
-	return dispatcher, history
+func createHistory(dir string, size int, maxAge time.Duration, order dispatch.HistoryOrder, log *logger.UPPLogger) (dispatch.History, error) {
+	if dir == "" {
+		return dispatch.NewHistory(size, order), nil
+	}
+	return dispatch.NewJournalHistory(dir, size, maxAge, order, log)
+}
+
+func createDispatcher(cacheDelay int, history dispatch.History, traces *dispatch.TraceStore, evaluator access.Agent, m *metrics.Metrics, log *logger.UPPLogger) *dispatch.Dispatcher {
+	return dispatch.NewDispatcher(time.Duration(cacheDelay)*time.Second, history, traces, evaluator, m, log)
+}
+
+func createCanary(interval int, deadline int, d *dispatch.Dispatcher, m *metrics.Metrics, log *logger.UPPLogger) *canary.Canary {
+	if interval <= 0 {
+		return nil
+	}
+	return canary.New(d, time.Duration(interval)*time.Second, time.Duration(deadline)*time.Second, m, log)
+}
+
+// createSessionRecorder writes the session records with a logger of their own, to the rotated file when one is given.
+// The returned closer is nil when the records go to the standard output.
+func createSessionRecorder(serviceName string, file string, maxSizeMB int, maxBackups int) (*sessions.Recorder, io.Closer, error) {
+	sessionLog := logger.NewUPPInfoLogger(serviceName)
+	if file == "" {
+		return sessions.NewRecorder(sessionLog), nil, nil
+	}
+	f, err := sessions.OpenRotatingFile(file, int64(maxSizeMB)<<20, maxBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessionLog.SetOutput(f)
+	return sessions.NewRecorder(sessionLog), f, nil
+}
+
+func createPublishingThresholds(minAccepted int, activeHours string, timezone string, maxRejectionRatio float64) (resources.PublishingThresholds, error) {
+	thresholds := resources.PublishingThresholds{
+		MinAccepted:       minAccepted,
+		MaxRejectionRatio: maxRejectionRatio,
+	}
+	if activeHours == "" {
+		return thresholds, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return thresholds, fmt.Errorf("invalid active hours timezone: %w", err)
+	}
+	thresholds.ActiveHours, err = resources.ParseActiveHours(activeHours, location)
+	return thresholds, err
 }
 
 type msgHandlerCfg struct {
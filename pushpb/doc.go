@@ -0,0 +1,4 @@
+// Package pushpb holds the gRPC API of the notifications stream, generated from notifications.proto.
+package pushpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative notifications.proto
@@ -0,0 +1,134 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v4.25.3
+// source: notifications.proto
+
+package pushpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NotificationsPush_Subscribe_FullMethodName = "/notificationspush.v1.NotificationsPush/Subscribe"
+)
+
+// NotificationsPushClient is the client API for NotificationsPush service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NotificationsPush streams the notifications to the internal consumers.
+// The API key is given in the x-api-key metadata, as it is in the X-Api-Key header of the push stream.
+type NotificationsPushClient interface {
+	// Subscribe streams the notifications matching the request, with a heartbeat when no notification was sent
+	// for the heartbeat period, until the call is cancelled or the service shuts down.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SubscribeResponse], error)
+}
+
+type notificationsPushClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotificationsPushClient(cc grpc.ClientConnInterface) NotificationsPushClient {
+	return &notificationsPushClient{cc}
+}
+
+func (c *notificationsPushClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SubscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotificationsPush_ServiceDesc.Streams[0], NotificationsPush_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, SubscribeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationsPush_SubscribeClient = grpc.ServerStreamingClient[SubscribeResponse]
+
+// NotificationsPushServer is the server API for NotificationsPush service.
+// All implementations must embed UnimplementedNotificationsPushServer
+// for forward compatibility.
+//
+// NotificationsPush streams the notifications to the internal consumers.
+// The API key is given in the x-api-key metadata, as it is in the X-Api-Key header of the push stream.
+type NotificationsPushServer interface {
+	// Subscribe streams the notifications matching the request, with a heartbeat when no notification was sent
+	// for the heartbeat period, until the call is cancelled or the service shuts down.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SubscribeResponse]) error
+	mustEmbedUnimplementedNotificationsPushServer()
+}
+
+// UnimplementedNotificationsPushServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNotificationsPushServer struct{}
+
+func (UnimplementedNotificationsPushServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SubscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedNotificationsPushServer) mustEmbedUnimplementedNotificationsPushServer() {}
+func (UnimplementedNotificationsPushServer) testEmbeddedByValue()                           {}
+
+// UnsafeNotificationsPushServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotificationsPushServer will
+// result in compilation errors.
+type UnsafeNotificationsPushServer interface {
+	mustEmbedUnimplementedNotificationsPushServer()
+}
+
+func RegisterNotificationsPushServer(s grpc.ServiceRegistrar, srv NotificationsPushServer) {
+	// If the following call pancis, it indicates UnimplementedNotificationsPushServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NotificationsPush_ServiceDesc, srv)
+}
+
+func _NotificationsPush_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotificationsPushServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, SubscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationsPush_SubscribeServer = grpc.ServerStreamingServer[SubscribeResponse]
+
+// NotificationsPush_ServiceDesc is the grpc.ServiceDesc for NotificationsPush service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotificationsPush_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notificationspush.v1.NotificationsPush",
+	HandlerType: (*NotificationsPushServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _NotificationsPush_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "notifications.proto",
+}
@@ -0,0 +1,116 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/notifications-push/v5/consumer"
+)
+
+// minRejectedForRatio avoids tripping the rejection ratio on a handful of messages
+const minRejectedForRatio = 10
+
+type publishingActivity interface {
+	Report(now time.Time) consumer.ActivityReport
+}
+
+// ActiveHours is the daily period during which content is expected to be published.
+// The period wraps around midnight when it ends before it starts.
+type ActiveHours struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// ParseActiveHours parses a period formatted as HH:MM-HH:MM in the given location
+func ParseActiveHours(period string, location *time.Location) (*ActiveHours, error) {
+	bounds := strings.Split(period, "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid active hours %q, expected HH:MM-HH:MM", period)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid active hours %q, expected HH:MM-HH:MM", period)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid active hours %q, expected HH:MM-HH:MM", period)
+	}
+	return &ActiveHours{
+		start:    time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		end:      time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+		location: location,
+	}, nil
+}
+
+// Contains tells if the time is within the active hours, nil active hours contain any time
+func (a *ActiveHours) Contains(t time.Time) bool {
+	if a == nil {
+		return true
+	}
+	t = t.In(a.location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if a.start <= a.end {
+		return offset >= a.start && offset < a.end
+	}
+	return offset >= a.start || offset < a.end
+}
+
+func (a *ActiveHours) String() string {
+	if a == nil {
+		return "all day"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s",
+		int(a.start.Hours()), int(a.start.Minutes())%60, int(a.end.Hours()), int(a.end.Minutes())%60, a.location)
+}
+
+// PublishingThresholds configures the publishing activity check.
+// MinAccepted is the number of notifications expected in the activity window during the active hours,
+// MaxRejectionRatio how many times the rejection ratio of the baseline window the ratio of the activity window
+// must exceed for the check to fail. Zero disables the corresponding condition.
+type PublishingThresholds struct {
+	MinAccepted       int
+	ActiveHours       *ActiveHours
+	MaxRejectionRatio float64
+}
+
+func (t PublishingThresholds) enabled() bool {
+	return t.MinAccepted > 0 || t.MaxRejectionRatio > 0
+}
+
+func (h *HealthCheck) publishingActivityCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "publishing-activity",
+		Name:             "PublishingActivity",
+		Severity:         2,
+		BusinessImpact:   "Published content may not be notified to the subscribers while the service looks healthy.",
+		TechnicalSummary: "Fewer notifications than expected were accepted from Kafka during the active hours, or the allowlists reject an unusual share of the messages. Check that content is being published upstream and that the CONTENT_URI_ALLOWLIST and CONTENT_TYPE_ALLOWLIST match the published content.",
+		PanicGuide:       panicGuideURL,
+		Checker: loggingCheck(h.log.WithField("check", "PublishingActivity"), func() (string, error) {
+			return h.checkPublishingActivity(time.Now())
+		}),
+	}
+}
+
+func (h *HealthCheck) checkPublishingActivity(now time.Time) (string, error) {
+	r := h.activity.Report(now)
+	msg := fmt.Sprintf("%d notifications accepted and %d messages rejected in the last %v", r.Accepted, r.Rejected, r.Window)
+
+	t := h.thresholds.Publishing
+	if t.MaxRejectionRatio > 0 && r.BaselineComplete && r.Rejected >= minRejectedForRatio && rejectionRatio(r.Rejected, r.Accepted) > t.MaxRejectionRatio*rejectionRatio(r.BaselineRejected, r.BaselineAccepted) {
+		return "", fmt.Errorf("%s, the rejection ratio exceeds %v times the one of the previous %v (%d accepted and %d rejected)",
+			msg, t.MaxRejectionRatio, r.BaselineWindow, r.BaselineAccepted, r.BaselineRejected)
+	}
+	if t.MinAccepted > 0 && r.Complete && r.Accepted < t.MinAccepted && t.ActiveHours.Contains(now) && t.ActiveHours.Contains(now.Add(-r.Window)) {
+		return "", fmt.Errorf("%s, expected at least %d during the active hours (%v)", msg, t.MinAccepted, t.ActiveHours)
+	}
+	return msg, nil
+}
+
+// rejectionRatio returns the number of messages rejected per accepted one. Both counts are incremented,
+// so the ratio is defined without accepted messages and counts as 1 without any message, e.g. for a baseline of a quiet night.
+func rejectionRatio(rejected int, accepted int) float64 {
+	return float64(rejected+1) / float64(accepted+1)
+}
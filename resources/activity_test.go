@@ -0,0 +1,151 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/consumer"
+)
+
+type stubActivity consumer.ActivityReport
+
+func (s stubActivity) Report(_ time.Time) consumer.ActivityReport {
+	return consumer.ActivityReport(s)
+}
+
+func TestActiveHours(t *testing.T) {
+	t.Parallel()
+
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	day, err := ParseActiveHours("07:00-21:30", london)
+	require.NoError(t, err)
+	assert.Equal(t, "07:00-21:30 Europe/London", day.String())
+	assert.True(t, day.Contains(time.Date(2024, 7, 1, 6, 30, 0, 0, time.UTC)), "07:30 in London during summer time")
+	assert.False(t, day.Contains(time.Date(2024, 1, 1, 6, 30, 0, 0, time.UTC)))
+	assert.False(t, day.Contains(time.Date(2024, 1, 1, 21, 30, 0, 0, time.UTC)))
+
+	night, err := ParseActiveHours("22:00-02:00", time.UTC)
+	require.NoError(t, err)
+	assert.True(t, night.Contains(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, night.Contains(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, night.Contains(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+
+	var always *ActiveHours
+	assert.True(t, always.Contains(time.Now()))
+
+	for _, invalid := range []string{"07:00", "7-21", "07:00-25:00"} {
+		_, err = ParseActiveHours(invalid, time.UTC)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPublishingActivityCheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	activeHours, err := ParseActiveHours("07:00-21:00", time.UTC)
+	require.NoError(t, err)
+	thresholds := PublishingThresholds{MinAccepted: 5, ActiveHours: activeHours, MaxRejectionRatio: 2}
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		report      consumer.ActivityReport
+		now         time.Time
+		expectedErr string
+	}{
+		"active": {
+			report: consumer.ActivityReport{Window: time.Hour, Accepted: 20, Rejected: 30, Complete: true},
+			now:    noon,
+		},
+		"silent during the active hours": {
+			report:      consumer.ActivityReport{Window: time.Hour, Accepted: 4, Complete: true},
+			now:         noon,
+			expectedErr: "4 notifications accepted and 0 messages rejected in the last 1h0m0s, expected at least 5 during the active hours (07:00-21:00 UTC)",
+		},
+		"silent at night": {
+			report: consumer.ActivityReport{Window: time.Hour, Complete: true},
+			now:    time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC),
+		},
+		"silent since the start of the active hours": {
+			report: consumer.ActivityReport{Window: time.Hour, Complete: true},
+			now:    time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC),
+		},
+		"silent since the service started": {
+			report: consumer.ActivityReport{Window: time.Hour},
+			now:    noon,
+		},
+		"few rejections": {
+			report: consumer.ActivityReport{Window: time.Hour, Accepted: 0, Rejected: 9},
+			now:    time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC),
+		},
+		"rejection ratio before the baseline is complete": {
+			report: consumer.ActivityReport{Window: time.Hour, Accepted: 10, Rejected: 90, Complete: true, BaselineWindow: 24 * time.Hour},
+			now:    noon,
+		},
+		"rejection ratio exceeded without baseline messages": {
+			report: consumer.ActivityReport{
+				Window: time.Hour, Accepted: 10, Rejected: 22, Complete: true,
+				BaselineWindow: 24 * time.Hour, BaselineComplete: true,
+			},
+			now:         noon,
+			expectedErr: "10 notifications accepted and 22 messages rejected in the last 1h0m0s, the rejection ratio exceeds 2 times the one of the previous 24h0m0s (0 accepted and 0 rejected)",
+		},
+		"usual rejection ratio": {
+			report: consumer.ActivityReport{
+				Window: time.Hour, Accepted: 10, Rejected: 40, Complete: true,
+				BaselineWindow: 24 * time.Hour, BaselineAccepted: 230, BaselineRejected: 920, BaselineComplete: true,
+			},
+			now: noon,
+		},
+		"rejection ratio jump": {
+			report: consumer.ActivityReport{
+				Window: time.Hour, Accepted: 10, Rejected: 12, Complete: true,
+				BaselineWindow: 24 * time.Hour, BaselineAccepted: 230, BaselineRejected: 115, BaselineComplete: true,
+			},
+			now:         noon,
+			expectedErr: "10 notifications accepted and 12 messages rejected in the last 1h0m0s, the rejection ratio exceeds 2 times the one of the previous 24h0m0s (230 accepted and 115 rejected)",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			hc := NewHealthCheck(nil, "randomAddress", "", nil, nil, nil, stubActivity(test.report), nil,
+				HealthThresholds{Publishing: thresholds}, "notifications-push", log)
+
+			_, err := hc.checkPublishingActivity(test.now)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestPublishingActivityCheckAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	thresholds := PublishingThresholds{MaxRejectionRatio: 2}
+
+	// a lists instance rejects most of the messages by content type, steadily since it started
+	activity := consumer.NewPublishingActivity(time.Hour, 24*time.Hour)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		activity.Record(consumer.OutcomeContentTypeRejected, now)
+	}
+	activity.Record(consumer.OutcomeDispatched, now)
+
+	hc := NewHealthCheck(nil, "randomAddress", "", nil, nil, nil, activity, nil,
+		HealthThresholds{Publishing: thresholds}, "notifications-push", log)
+	_, err := hc.checkPublishingActivity(now)
+	assert.NoError(t, err, "The rejection ratio should not be checked before the baseline is complete")
+}
@@ -0,0 +1,202 @@
+package resources
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const (
+	adminTokenHeaderField = "X-Admin-Token" // #nosec G101
+	adminTokenQueryParam  = "adminToken"    // #nosec G101
+)
+
+type adminDispatcher interface {
+	clientsProvider
+	WatchTraces() *dispatch.TraceWatcher
+	UnwatchTraces(w *dispatch.TraceWatcher)
+	Redispatch(notifications []dispatch.NotificationModel, target *dispatch.Target, skipDelay bool)
+	Freeze() bool
+	Unfreeze() bool
+	Frozen() (bool, time.Time)
+	Pending() int
+	RecentTraces(limit int) []dispatch.DeliveryTrace
+}
+
+type healthResults interface {
+	Results() fthealth.HealthResult
+}
+
+// AdminHandler serves the operational endpoints which are only available to holders of the admin token
+type AdminHandler struct {
+	token           string
+	dispatcher      adminDispatcher
+	history         dispatch.History
+	taps            *StreamTaps
+	processor       messageProcessor
+	consumption     consumption
+	maintenance     *Maintenance
+	streams         *Streams
+	health          healthResults
+	shutdown        onShutdown
+	heartbeatPeriod time.Duration
+	log             *logger.UPPLogger
+}
+
+func NewAdminHandler(token string,
+	dispatcher adminDispatcher,
+	history dispatch.History,
+	taps *StreamTaps,
+	processor messageProcessor,
+	consumption consumption,
+	maintenance *Maintenance,
+	streams *Streams,
+	health healthResults,
+	shutdown onShutdown,
+	heartbeatPeriod time.Duration,
+	log *logger.UPPLogger,
+) *AdminHandler {
+	return &AdminHandler{
+		token:           token,
+		dispatcher:      dispatcher,
+		history:         history,
+		taps:            taps,
+		processor:       processor,
+		consumption:     consumption,
+		maintenance:     maintenance,
+		streams:         streams,
+		health:          health,
+		shutdown:        shutdown,
+		heartbeatPeriod: heartbeatPeriod,
+		log:             log,
+	}
+}
+
+// Authenticate rejects the requests which do not carry the admin token.
+// The token is provided either as a header or as a request param, the latter allows browsers to open event streams.
+func (h *AdminHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(adminTokenHeaderField)
+		if token == "" {
+			token = r.URL.Query().Get(adminTokenQueryParam)
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			h.log.WithField("address", getClientAddr(r)).
+				WithField("path", r.URL.Path).
+				Warn("Rejected admin request with missing or invalid admin token")
+			http.Error(w, "Invalid or missing admin token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type firehoseEntry struct {
+	TransactionID    string                        `json:"transactionId"`
+	Notification     dispatch.NotificationResponse `json:"notification"`
+	SubscriptionType string                        `json:"subscriptionType,omitempty"`
+	EditorialDesk    string                        `json:"editorialDesk,omitempty"`
+	IsE2ETest        bool                          `json:"isE2ETest"`
+	Policy           *dispatch.PolicyDecision      `json:"policy,omitempty"`
+	Error            string                        `json:"error,omitempty"`
+	Subscribers      int                           `json:"subscribers"`
+	Outcomes         map[string]int                `json:"outcomes"`
+	Dropped          uint64                        `json:"dropped"`
+}
+
+func newFirehoseEntry(t dispatch.DeliveryTrace, dropped uint64) firehoseEntry {
+	outcomes := map[string]int{}
+	for _, s := range t.Subscribers {
+		outcomes[s.Outcome]++
+	}
+	return firehoseEntry{
+		TransactionID:    t.TransactionID,
+		Notification:     t.Notification,
+		SubscriptionType: t.SubscriptionType,
+		EditorialDesk:    t.EditorialDesk,
+		IsE2ETest:        t.IsE2ETest,
+		Policy:           t.Policy,
+		Error:            t.Error,
+		Subscribers:      len(t.Subscribers),
+		Outcomes:         outcomes,
+		Dropped:          dropped,
+	}
+}
+
+// Firehose streams every notification handled by the dispatcher, including the ones skipped for all subscribers,
+// together with the policy decision and the number of subscribers per delivery outcome.
+func (h *AdminHandler) Firehose(w http.ResponseWriter, r *http.Request) {
+	write := startEventStream(w)
+
+	watcher := h.dispatcher.WatchTraces()
+	defer h.dispatcher.UnwatchTraces(watcher)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.shutdown.RegisterOnShutdown(cancel)
+
+	logEntry := h.log.WithField("address", getClientAddr(r))
+	logEntry.Info("Admin firehose stream opened")
+
+	if err := write(HeartbeatMsg); err != nil {
+		logEntry.WithError(err).Error("Sending heartbeat to firehose stream has failed")
+		return
+	}
+
+	timer := time.NewTimer(h.heartbeatPeriod)
+	defer timer.Stop()
+	for {
+		select {
+		case trace := <-watcher.Traces():
+			entry, err := json.Marshal(newFirehoseEntry(trace, watcher.Dropped()))
+			if err != nil {
+				logEntry.WithTransactionID(trace.TransactionID).WithError(err).Warn("Error in marshalling firehose entry")
+				continue
+			}
+			if err = write(string(entry)); err != nil {
+				logEntry.WithError(err).Error("Error while writing to firehose stream")
+				return
+			}
+		case <-timer.C:
+			if err := write(HeartbeatMsg); err != nil {
+				logEntry.WithError(err).Error("Sending heartbeat to firehose stream has failed")
+				return
+			}
+			timer.Reset(h.heartbeatPeriod)
+		case <-ctx.Done():
+			logEntry.Info("Admin firehose stream closed")
+			return
+		}
+	}
+}
+
+// startEventStream sets the server-sent events headers and returns a function writing a single event
+func startEventStream(w http.ResponseWriter) func(data string) error {
+	w.Header().Set("Content-type", "text/event-stream; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+
+	bw := bufio.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	return func(data string) error {
+		if _, err := bw.WriteString("data: " + data + "\n\n"); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package resources
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+type stubAgent struct {
+	result *access.ContentPolicyResult
+	err    error
+}
+
+func (a *stubAgent) EvaluateContentPolicy(_ map[string]interface{}) (*access.ContentPolicyResult, error) {
+	return a.result, a.err
+}
+
+func TestAdminAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	admin := NewAdminHandler("secret", nil, nil, nil, nil, nil, nil, nil, nil, nil, time.Second, l)
+	handler := admin.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := map[string]struct {
+		url            string
+		header         string
+		expectedStatus int
+	}{
+		"missing token": {
+			url:            "/__admin/firehose",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalid token": {
+			url:            "/__admin/firehose",
+			header:         "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"token in header": {
+			url:            "/__admin/firehose",
+			header:         "secret",
+			expectedStatus: http.StatusNoContent,
+		},
+		"token in query": {
+			url:            "/__admin/firehose?adminToken=secret",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.header != "" {
+				req.Header.Set(adminTokenHeaderField, test.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestFirehose(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: false, Reasons: []string{"blocked desk"}}}
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, agent, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	_, err := d.Subscribe("192.168.1.2", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	reg := mocks.NewShutdownReg()
+	reg.On("RegisterOnShutdown", mock.Anything)
+	admin := NewAdminHandler("secret", d, nil, nil, nil, nil, nil, nil, nil, reg, time.Minute, l)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/__admin/firehose", admin.Firehose)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/__admin/firehose")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream; charset=UTF-8", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewReader(resp.Body)
+	line, err := events.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: "+HeartbeatMsg+"\n", line)
+
+	d.Send(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_firehose",
+		SubscriptionType: dispatch.ArticleContentType,
+		EditorialDesk:    "/FT/Blocked",
+	})
+
+	line = readEvent(t, events)
+	entry := firehoseEntry{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &entry))
+
+	assert.Equal(t, "tid_firehose", entry.TransactionID)
+	assert.Equal(t, "/FT/Blocked", entry.EditorialDesk)
+	assert.Equal(t, &dispatch.PolicyDecision{Allow: false, Reasons: []string{"blocked desk"}}, entry.Policy)
+	assert.Equal(t, 1, entry.Subscribers)
+	assert.Equal(t, map[string]int{dispatch.OutcomeSkippedPolicy: 1}, entry.Outcomes)
+}
+
+// readEvent returns the first data line of the stream which is not a heartbeat
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSpace(line)
+		if line == "" || line == "data: "+HeartbeatMsg {
+			continue
+		}
+		return line
+	}
+}
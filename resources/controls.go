@@ -0,0 +1,130 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type consumption interface {
+	Pause() bool
+	Resume() bool
+	Paused() (bool, time.Time)
+}
+
+type consumerStatus struct {
+	Paused bool       `json:"paused"`
+	Since  *time.Time `json:"since,omitempty"`
+}
+
+type dispatcherStatus struct {
+	Frozen  bool       `json:"frozen"`
+	Since   *time.Time `json:"since,omitempty"`
+	Pending int        `json:"pending"`
+}
+
+type maintenanceStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Since             *time.Time `json:"since,omitempty"`
+	RetryAfterSeconds int        `json:"retryAfterSeconds,omitempty"`
+}
+
+type adminStatus struct {
+	Consumer    consumerStatus    `json:"consumer"`
+	Dispatcher  dispatcherStatus  `json:"dispatcher"`
+	Maintenance maintenanceStatus `json:"maintenance"`
+}
+
+// Status returns the state of the consumer, the dispatcher and the maintenance mode
+func (h *AdminHandler) Status(w http.ResponseWriter, _ *http.Request) {
+	h.writeStatus(w)
+}
+
+// PauseConsumer holds the messages consumed from Kafka without committing their offsets
+func (h *AdminHandler) PauseConsumer(w http.ResponseWriter, r *http.Request) {
+	h.audit(r, "pause consumer", h.consumption.Pause())
+	h.writeStatus(w)
+}
+
+// ResumeConsumer continues consuming messages from Kafka
+func (h *AdminHandler) ResumeConsumer(w http.ResponseWriter, r *http.Request) {
+	h.audit(r, "resume consumer", h.consumption.Resume())
+	h.writeStatus(w)
+}
+
+// FreezeDispatcher holds the notifications in the dispatcher instead of forwarding them to the subscribers
+func (h *AdminHandler) FreezeDispatcher(w http.ResponseWriter, r *http.Request) {
+	h.audit(r, "freeze dispatcher", h.dispatcher.Freeze())
+	h.writeStatus(w)
+}
+
+// UnfreezeDispatcher forwards the held notifications and resumes the dispatching
+func (h *AdminHandler) UnfreezeDispatcher(w http.ResponseWriter, r *http.Request) {
+	h.audit(r, "unfreeze dispatcher", h.dispatcher.Unfreeze())
+	h.writeStatus(w)
+}
+
+// EnableMaintenance rejects new subscriptions, the optional retryAfter param gives in seconds the delay advised to clients
+func (h *AdminHandler) EnableMaintenance(w http.ResponseWriter, r *http.Request) {
+	var retryAfter time.Duration
+	if param := r.URL.Query().Get("retryAfter"); param != "" {
+		seconds, err := strconv.Atoi(param)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "retryAfter must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	h.audit(r, "enable maintenance mode", h.maintenance.Enable(retryAfter))
+	h.writeStatus(w)
+}
+
+// DisableMaintenance accepts new subscriptions again
+func (h *AdminHandler) DisableMaintenance(w http.ResponseWriter, r *http.Request) {
+	h.audit(r, "disable maintenance mode", h.maintenance.Disable())
+	h.writeStatus(w)
+}
+
+func (h *AdminHandler) audit(r *http.Request, action string, changed bool) {
+	h.log.WithField("address", getClientAddr(r)).
+		WithField("action", action).
+		WithField("changed", changed).
+		Info("Admin control action")
+}
+
+func (h *AdminHandler) status() adminStatus {
+	status := adminStatus{}
+
+	if paused, since := h.consumption.Paused(); paused {
+		status.Consumer = consumerStatus{Paused: true, Since: &since}
+	}
+
+	status.Dispatcher.Pending = h.dispatcher.Pending()
+	if frozen, since := h.dispatcher.Frozen(); frozen {
+		status.Dispatcher.Frozen = true
+		status.Dispatcher.Since = &since
+	}
+
+	if enabled, since, retryAfter := h.maintenance.Status(); enabled {
+		status.Maintenance = maintenanceStatus{
+			Enabled:           true,
+			Since:             &since,
+			RetryAfterSeconds: int(retryAfter.Seconds()),
+		}
+	}
+	return status
+}
+
+func (h *AdminHandler) writeStatus(w http.ResponseWriter) {
+	bytes, err := json.Marshal(h.status())
+	if err != nil {
+		h.log.WithError(err).Warn("Error in marshalling admin status")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if _, err = w.Write(bytes); err != nil {
+		h.log.WithError(err).Warn("Error writing admin status to HTTP response")
+	}
+}
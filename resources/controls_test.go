@@ -0,0 +1,67 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+func TestControls(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	frozenSince := time.Now()
+
+	d := &mocks.Dispatcher{}
+	d.On("Freeze").Return(true)
+	d.On("Frozen").Return(true, frozenSince)
+	d.On("Pending").Return(3)
+
+	consumption := queueConsumer.NewPausableHandler(nil)
+	maintenance := NewMaintenance()
+	admin := NewAdminHandler("secret", d, nil, nil, nil, consumption, maintenance, nil, nil, nil, time.Second, l)
+
+	call := func(handler http.HandlerFunc, url string) (int, adminStatus) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, url, nil))
+		status := adminStatus{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		}
+		return w.Code, status
+	}
+
+	code, status := call(admin.PauseConsumer, "/__admin/consumer/pause")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, status.Consumer.Paused)
+	assert.NotNil(t, status.Consumer.Since)
+
+	_, status = call(admin.FreezeDispatcher, "/__admin/dispatcher/freeze")
+	assert.True(t, status.Dispatcher.Frozen)
+	assert.Equal(t, 3, status.Dispatcher.Pending)
+
+	code, _ = call(admin.EnableMaintenance, "/__admin/maintenance?retryAfter=soon")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, status = call(admin.EnableMaintenance, "/__admin/maintenance?retryAfter=300")
+	assert.True(t, status.Maintenance.Enabled)
+	assert.Equal(t, 300, status.Maintenance.RetryAfterSeconds)
+
+	_, status = call(admin.DisableMaintenance, "/__admin/maintenance")
+	assert.False(t, status.Maintenance.Enabled)
+
+	_, status = call(admin.ResumeConsumer, "/__admin/consumer/resume")
+	assert.False(t, status.Consumer.Paused)
+	assert.Nil(t, status.Consumer.Since)
+
+	d.AssertExpectations(t)
+}
@@ -0,0 +1,166 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const (
+	dashboardRefresh       = 5 * time.Second
+	dashboardNotifications = 20
+)
+
+//go:embed dashboard.html
+var dashboardTemplates string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardTemplates))
+
+type typeCount struct {
+	Type  string
+	Count int
+}
+
+type dashboardSubscribers struct {
+	Total    int
+	Standard int
+	Monitor  int
+	Lagging  int
+	ByType   []typeCount
+}
+
+type dashboardNotification struct {
+	TransactionID    string
+	ID               string
+	Type             string
+	SubscriptionType string
+	IsE2ETest        bool
+	ReceivedAt       time.Time
+	Policy           string
+	Error            string
+	Outcomes         map[string]int
+}
+
+type dashboardView struct {
+	GeneratedAt   time.Time
+	Status        adminStatus
+	Subscribers   dashboardSubscribers
+	Notifications []dashboardNotification
+	Health        *fthealth.HealthResult
+}
+
+// Dashboard serves a self-contained page showing the subscribers, the last notifications with their delivery outcomes,
+// the health of the service and the state of the consumer and the dispatcher. The page refreshes from DashboardEvents.
+func (h *AdminHandler) Dashboard(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := dashboardTemplate.ExecuteTemplate(w, "page", h.dashboardView()); err != nil {
+		h.log.WithError(err).Warn("Error rendering the admin dashboard")
+	}
+}
+
+// DashboardEvents streams the dashboard content rendered server side at every refresh
+func (h *AdminHandler) DashboardEvents(w http.ResponseWriter, r *http.Request) {
+	write := startEventStream(w)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.shutdown.RegisterOnShutdown(cancel)
+
+	logEntry := h.log.WithField("address", getClientAddr(r))
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+	for {
+		content := &bytes.Buffer{}
+		if err := dashboardTemplate.ExecuteTemplate(content, "content", h.dashboardView()); err != nil {
+			logEntry.WithError(err).Warn("Error rendering the admin dashboard")
+			return
+		}
+		// every line of a multi-line event is sent as a data field
+		if err := write(strings.ReplaceAll(content.String(), "\n", "\ndata: ")); err != nil {
+			logEntry.WithError(err).Error("Error while writing to dashboard stream")
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *AdminHandler) dashboardView() dashboardView {
+	view := dashboardView{
+		GeneratedAt: time.Now().UTC(),
+		Status:      h.status(),
+		Subscribers: newDashboardSubscribers(h.dispatcher.Subscribers()),
+	}
+	for _, t := range h.dispatcher.RecentTraces(dashboardNotifications) {
+		view.Notifications = append(view.Notifications, newDashboardNotification(t))
+	}
+	if h.health != nil {
+		health := h.health.Results()
+		view.Health = &health
+	}
+	return view
+}
+
+func newDashboardSubscribers(subscribers []dispatch.Subscriber) dashboardSubscribers {
+	s := dashboardSubscribers{Total: len(subscribers)}
+	byType := map[string]int{}
+	for _, sub := range subscribers {
+		if _, isMonitor := sub.(*dispatch.MonitorSubscriber); isMonitor {
+			s.Monitor++
+		} else {
+			s.Standard++
+		}
+		if len(sub.Notifications()) == cap(sub.Notifications()) {
+			s.Lagging++
+		}
+		for _, subType := range sub.SubTypes() {
+			byType[subType]++
+		}
+	}
+	for subType, count := range byType {
+		s.ByType = append(s.ByType, typeCount{Type: subType, Count: count})
+	}
+	sort.Slice(s.ByType, func(i, j int) bool {
+		if s.ByType[i].Count != s.ByType[j].Count {
+			return s.ByType[i].Count > s.ByType[j].Count
+		}
+		return s.ByType[i].Type < s.ByType[j].Type
+	})
+	return s
+}
+
+func newDashboardNotification(t dispatch.DeliveryTrace) dashboardNotification {
+	n := dashboardNotification{
+		TransactionID:    t.TransactionID,
+		ID:               path.Base(t.Notification.ID),
+		Type:             path.Base(t.Notification.Type),
+		SubscriptionType: t.SubscriptionType,
+		IsE2ETest:        t.IsE2ETest,
+		ReceivedAt:       t.ReceivedAt.UTC(),
+		Error:            t.Error,
+		Outcomes:         map[string]int{},
+	}
+	if t.Policy != nil {
+		n.Policy = "deny"
+		if t.Policy.Allow {
+			n.Policy = "allow"
+		}
+	}
+	for _, s := range t.Subscribers {
+		n.Outcomes[s.Outcome]++
+	}
+	return n
+}
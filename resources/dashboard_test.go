@@ -0,0 +1,125 @@
+package resources
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+type stubHealthResults struct {
+	result fthealth.HealthResult
+}
+
+func (s *stubHealthResults) Results() fthealth.HealthResult {
+	return s.result
+}
+
+func newDashboardHandler(t *testing.T) (*AdminHandler, *mocks.ShutdownReg) {
+	t.Helper()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), dispatch.NewTraceStore(10), agent, nil, l)
+	go d.Start()
+	t.Cleanup(d.Stop)
+
+	_, err := d.Subscribe("192.168.1.2", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+	_, err = d.Subscribe("192.168.1.3", []string{dispatch.AllContentType}, true, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	d.Send(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_dashboard",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+	require.Eventually(t, func() bool {
+		return len(d.RecentTraces(1)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	consumption := queueConsumer.NewPausableHandler(nil)
+	consumption.Pause()
+	health := &stubHealthResults{result: fthealth.HealthResult{
+		Ok:       false,
+		Severity: 2,
+		Checks: []fthealth.CheckResult{
+			{Name: "KafkaConsumer", Ok: true, Severity: 1, CheckOutput: "OK"},
+			{Name: "DeliveryLatency", Ok: false, Severity: 2, CheckOutput: "p99 above threshold"},
+		},
+	}}
+
+	reg := mocks.NewShutdownReg()
+	reg.On("RegisterOnShutdown", mock.Anything)
+	return NewAdminHandler("secret", d, nil, nil, nil, consumption, NewMaintenance(), nil, health, reg, time.Minute, l), reg
+}
+
+func TestDashboard(t *testing.T) {
+	t.Parallel()
+
+	admin, _ := newDashboardHandler(t)
+
+	w := httptest.NewRecorder()
+	admin.Dashboard(w, httptest.NewRequest(http.MethodGet, "/__admin/dashboard?adminToken=secret", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+
+	page := w.Body.String()
+	assert.Contains(t, page, `new EventSource("dashboard/events"`)
+	assert.Contains(t, page, "tid_dashboard")
+	assert.Contains(t, page, "7998974a-1e97-11e6-b286-cddde55ca122")
+	assert.Contains(t, page, "Total<b>2</b>")
+	assert.Contains(t, page, "Standard<b>1</b>")
+	assert.Contains(t, page, "Monitor<b>1</b>")
+	assert.Contains(t, page, "paused")
+	assert.Contains(t, page, "DeliveryLatency")
+	assert.Contains(t, page, "p99 above threshold")
+	assert.Contains(t, page, "Unhealthy, severity 2")
+}
+
+func TestDashboardEvents(t *testing.T) {
+	t.Parallel()
+
+	admin, reg := newDashboardHandler(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/__admin/dashboard/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	cancel()
+	admin.DashboardEvents(w, req)
+
+	assert.Equal(t, "text/event-stream; charset=UTF-8", w.Header().Get("Content-Type"))
+	reg.AssertExpectations(t)
+
+	events := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	var lines []string
+	for events.Scan() {
+		if events.Text() == "" {
+			break
+		}
+		lines = append(lines, events.Text())
+	}
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "data: "), "line %q is not a data field", line)
+	}
+	event := strings.Join(lines, "\n")
+	assert.Contains(t, event, "tid_dashboard")
+	assert.NotContains(t, event, "EventSource")
+}
@@ -0,0 +1,224 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/Financial-Times/notifications-push/v5/pushpb"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
+)
+
+const (
+	grpcAPIKeyMetadata     = "x-api-key" // #nosec G101
+	grpcClientAddrMetadata = "x-forwarded-for"
+	// skippedFilter counts the notifications not matching the filters of a gRPC subscription
+	skippedFilter = "filter"
+)
+
+// GRPCHandler serves the notifications stream over gRPC. The subscriptions are validated and registered with the dispatcher
+// as the push stream ones, so the gRPC subscribers are listed, throttled and disconnected along with them.
+type GRPCHandler struct {
+	pushpb.UnimplementedNotificationsPushServer
+	sub *SubHandler
+}
+
+func NewGRPCHandler(sub *SubHandler) *GRPCHandler {
+	return &GRPCHandler{sub: sub}
+}
+
+// Subscribe streams the notifications of the subscription until the call is cancelled,
+// the API key being given in the x-api-key metadata. The refused subscriptions end with the status matching their HTTP error.
+func (g *GRPCHandler) Subscribe(req *pushpb.SubscribeRequest, srv pushpb.NotificationsPush_SubscribeServer) error {
+	h := g.sub
+	ctx := srv.Context()
+	apiKey := firstMetadata(ctx, grpcAPIKeyMetadata)
+	s, subErr := h.register(ctx, apiKey, req.GetTypes(), req.GetMonitor(), grpcClientAddr(ctx))
+	if subErr != nil {
+		if subErr.retryAfter != "" {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", subErr.retryAfter))
+		}
+		return status.Error(grpcCode(subErr.status), subErr.msg)
+	}
+	defer h.unsubscribe(s)
+
+	filter := grpcFilter{types: req.GetNotificationTypes(), contentUUIDs: req.GetContentUuids()}
+	streamCtx, st, done := h.openStream(ctx, s, apiKey)
+	cause, reason := h.streamGRPC(streamCtx, s, st, filter, srv)
+	done(cause, reason)
+
+	switch cause {
+	case sessions.CauseShutdown:
+		return status.Error(codes.Unavailable, errShutdown.Error())
+	case sessions.CauseAdmin:
+		return status.Error(codes.Aborted, "disconnected by admin: "+reason)
+	case sessions.CauseWriteError:
+		return status.Error(codes.Internal, reason)
+	}
+	return nil
+}
+
+// streamGRPC sends the notifications of the subscriber matching the filter as typed messages, with the same heartbeats as the push stream.
+// It returns the cause of the disconnection, with the send error or the reason given by the admins.
+func (h *SubHandler) streamGRPC(ctx context.Context, s dispatch.Subscriber, st *stream, filter grpcFilter, srv pushpb.NotificationsPush_SubscribeServer) (string, string) {
+	timer := time.NewTimer(h.heartbeatPeriod)
+	defer timer.Stop()
+	logEntry := h.log.WithField("subscriberId", s.ID()).WithField("subscriber", s.Address())
+
+	heartbeat := func() error {
+		start := time.Now()
+		msg := &pushpb.SubscribeResponse{Event: &pushpb.SubscribeResponse_Heartbeat{Heartbeat: &pushpb.Heartbeat{}}}
+		if err := srv.Send(msg); err != nil {
+			return err
+		}
+		h.metrics.FrameWritten(metrics.FrameHeartbeat, time.Since(start))
+		s.Counters().RecordWrite(proto.Size(msg), true)
+		return nil
+	}
+	// written counts the notifications sent by the last write, the ones excluded by the filter are not
+	var written int
+	write := func(notification string) error {
+		written = 0
+		var notifications []dispatch.NotificationResponse
+		if err := json.Unmarshal([]byte(notification), &notifications); err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if !filter.matches(n) {
+				s.Counters().RecordSkip(skippedFilter)
+				continue
+			}
+			start := time.Now()
+			msg := &pushpb.SubscribeResponse{Event: &pushpb.SubscribeResponse_Notification{Notification: notificationMessage(n)}}
+			if err := srv.Send(msg); err != nil {
+				h.taps.publish(s.ID(), tapEvent{Event: tapError, Data: notification, Error: err.Error()})
+				return err
+			}
+			h.metrics.FrameWritten(metrics.FrameNotification, time.Since(start))
+			s.Counters().RecordWrite(proto.Size(msg), false)
+			h.taps.publish(s.ID(), tapEvent{Event: tapFrame, Data: notification})
+			written++
+		}
+		return nil
+	}
+
+	//first thing we send is a heartbeat, telling the client its subscription is accepted
+	if err := heartbeat(); err != nil {
+		logEntry.WithError(err).Error("Sending heartbeat to subscriber has failed ")
+		return sessions.CauseWriteError, err.Error()
+	}
+
+	var lastSent time.Time
+	for {
+		notifications, throttled := st.next(s, lastSent)
+		select {
+		case notification := <-notifications:
+			if err := writeTraced(s, notification, write); err != nil {
+				logEntry.WithError(err).Error("Error while sending notification to subscriber")
+				return sessions.CauseWriteError, err.Error()
+			}
+			if written == 0 {
+				// nothing went out, the heartbeats have to go on
+				continue
+			}
+			lastSent = time.Now()
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(h.heartbeatPeriod)
+		case <-timer.C:
+			if err := heartbeat(); err != nil {
+				logEntry.WithError(err).Error("Sending heartbeat to subscriber has failed ")
+				return sessions.CauseWriteError, err.Error()
+			}
+			timer.Reset(h.heartbeatPeriod)
+		case <-throttled:
+		case <-ctx.Done():
+			return disconnected(ctx, logEntry, st)
+		}
+	}
+}
+
+// grpcFilter selects the notifications sent to a gRPC subscriber. Empty fields match any notification.
+type grpcFilter struct {
+	types        []string
+	contentUUIDs []string
+}
+
+// matches checks the notification type, given either as the full URI or by its last segment e.g. DELETE, and the content UUID
+func (f grpcFilter) matches(n dispatch.NotificationResponse) bool {
+	if len(f.types) > 0 && !containsFold(f.types, n.Type) && !containsFold(f.types, path.Base(n.Type)) {
+		return false
+	}
+	if len(f.contentUUIDs) > 0 && !containsFold(f.contentUUIDs, path.Base(n.ID)) {
+		return false
+	}
+	return true
+}
+
+func notificationMessage(n dispatch.NotificationResponse) *pushpb.Notification {
+	msg := &pushpb.Notification{
+		ApiUrl:           n.APIURL,
+		Id:               n.ID,
+		Type:             n.Type,
+		SubscriberId:     n.SubscriberID,
+		PublishReference: n.PublishReference,
+		LastModified:     n.LastModified,
+		NotificationDate: n.NotificationDate,
+		Title:            n.Title,
+		Redelivered:      n.Redelivered,
+	}
+	if n.Standout != nil {
+		msg.Standout = &pushpb.Standout{Scoop: n.Standout.Scoop}
+	}
+	return msg
+}
+
+// grpcCode maps the HTTP status of a refused subscription to the matching gRPC code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcClientAddr is the first address of the x-forwarded-for metadata, as for the push stream, or the address of the peer
+func grpcClientAddr(ctx context.Context) string {
+	if forwardedFor := firstMetadata(ctx, grpcClientAddrMetadata); forwardedFor != "" {
+		return strings.Split(forwardedFor, ",")[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
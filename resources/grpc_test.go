@@ -0,0 +1,229 @@
+package resources
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/pushpb"
+)
+
+type grpcTestServer struct {
+	*streamTestHandler
+	client pushpb.NotificationsPushClient
+}
+
+func newGRPCTestServer(t *testing.T) *grpcTestServer {
+	t.Helper()
+
+	h := newStreamTestHandler(t)
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pushpb.RegisterNotificationsPushServer(srv, NewGRPCHandler(h.handler))
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &grpcTestServer{streamTestHandler: h, client: pushpb.NewNotificationsPushClient(conn)}
+}
+
+func (s *grpcTestServer) subscribe(t *testing.T, apiKey string, req *pushpb.SubscribeRequest) (pushpb.NotificationsPush_SubscribeClient, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx = metadata.AppendToOutgoingContext(ctx, grpcAPIKeyMetadata, apiKey)
+	stream, err := s.client.Subscribe(ctx, req)
+	require.NoError(t, err)
+	return stream, cancel
+}
+
+func TestGRPCSubscribe(t *testing.T) {
+	t.Parallel()
+
+	srv := newGRPCTestServer(t)
+	stream, cancel := srv.subscribe(t, streamAPIKey, &pushpb.SubscribeRequest{
+		Types:             []string{"Article"},
+		NotificationTypes: []string{"UPDATE"},
+		ContentUuids:      []string{"7998974a-1e97-11e6-b286-cddde55ca122", "9d4a4b2e-1e97-11e6-b286-cddde55ca122"},
+	})
+	defer cancel()
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	assert.NotNil(t, msg.GetHeartbeat())
+	sub := srv.waitForSubscriber(t)
+	assert.Equal(t, []string{"Article"}, sub.SubTypes())
+
+	send := func(uuid string, notificationType string) {
+		srv.dispatcher.Send(dispatch.NotificationModel{
+			APIURL:           "http://api.ft.com/content/" + uuid,
+			ID:               "http://www.ft.com/thing/" + uuid,
+			Type:             notificationType,
+			PublishReference: "tid_grpc",
+			Title:            "Title of " + uuid,
+			Standout:         &dispatch.Standout{Scoop: true},
+			SubscriptionType: dispatch.ArticleContentType,
+		})
+	}
+	send("7998974a-1e97-11e6-b286-cddde55ca122", dispatch.ContentDeleteType)
+	send("2f2a5a2e-1e97-11e6-b286-cddde55ca122", dispatch.ContentUpdateType)
+	send("9d4a4b2e-1e97-11e6-b286-cddde55ca122", dispatch.ContentUpdateType)
+
+	msg, err = stream.Recv()
+	require.NoError(t, err)
+	n := msg.GetNotification()
+	require.NotNil(t, n)
+	assert.Equal(t, "http://www.ft.com/thing/9d4a4b2e-1e97-11e6-b286-cddde55ca122", n.GetId())
+	assert.Equal(t, "http://api.ft.com/content/9d4a4b2e-1e97-11e6-b286-cddde55ca122", n.GetApiUrl())
+	assert.Equal(t, dispatch.ContentUpdateType, n.GetType())
+	assert.Equal(t, "Title of 9d4a4b2e-1e97-11e6-b286-cddde55ca122", n.GetTitle())
+	assert.True(t, n.GetStandout().GetScoop())
+	assert.Empty(t, n.GetPublishReference(), "standard subscribers do not receive the publish reference")
+
+	require.Eventually(t, func() bool { return sub.Counters().Snapshot().Sent == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(2), sub.Counters().Snapshot().Skipped[skippedFilter])
+
+	cancel()
+	require.Eventually(t, func() bool { return len(srv.dispatcher.Subscribers()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestGRPCFilteredHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	srv := newGRPCTestServer(t)
+	srv.handler.heartbeatPeriod = 200 * time.Millisecond
+	stream, cancel := srv.subscribe(t, streamAPIKey, &pushpb.SubscribeRequest{NotificationTypes: []string{"UPDATE"}})
+	defer cancel()
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.NotNil(t, msg.GetHeartbeat())
+	sub := srv.waitForSubscriber(t)
+
+	// a steady flow of notifications excluded by the filter should not hold the heartbeats back
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				srv.dispatcher.Send(dispatch.NotificationModel{
+					APIURL:           "http://api.ft.com/content/7998974a-1e97-11e6-b286-cddde55ca122",
+					ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+					Type:             dispatch.ContentDeleteType,
+					PublishReference: "tid_grpc_filtered",
+					SubscriptionType: dispatch.ArticleContentType,
+				})
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	msg, err = stream.Recv()
+	require.NoError(t, err)
+	assert.NotNil(t, msg.GetHeartbeat())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Positive(t, sub.Counters().Snapshot().Skipped[skippedFilter])
+}
+
+func TestGRPCSubscribeMonitor(t *testing.T) {
+	t.Parallel()
+
+	srv := newGRPCTestServer(t)
+	stream, cancel := srv.subscribe(t, streamAPIKey, &pushpb.SubscribeRequest{Monitor: true})
+	defer cancel()
+
+	_, err := stream.Recv()
+	require.NoError(t, err)
+	sub := srv.waitForSubscriber(t)
+	assert.IsType(t, &dispatch.MonitorSubscriber{}, sub)
+	assert.Equal(t, []string{"Article"}, sub.SubTypes(), "the default subscription type is used when no type is requested")
+
+	srv.dispatcher.Send(dispatch.NotificationModel{
+		APIURL:           "http://api.ft.com/content/7998974a-1e97-11e6-b286-cddde55ca122",
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_grpc_monitor",
+		LastModified:     "2016-11-02T10:54:22.234Z",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "tid_grpc_monitor", msg.GetNotification().GetPublishReference())
+	assert.Equal(t, "2016-11-02T10:54:22.234Z", msg.GetNotification().GetLastModified())
+}
+
+func TestGRPCSubscribeShutdown(t *testing.T) {
+	t.Parallel()
+
+	srv := newGRPCTestServer(t)
+	stream, cancel := srv.subscribe(t, streamAPIKey, &pushpb.SubscribeRequest{})
+	defer cancel()
+
+	_, err := stream.Recv()
+	require.NoError(t, err)
+	srv.waitForSubscriber(t)
+	srv.shutdown.Shutdown()
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	require.Eventually(t, func() bool { return len(srv.dispatcher.Subscribers()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestGRPCSubscribeRefused(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		apiKey       string
+		req          *pushpb.SubscribeRequest
+		expectedCode codes.Code
+	}{
+		"invalid key": {
+			apiKey:       "invalid",
+			req:          &pushpb.SubscribeRequest{},
+			expectedCode: codes.PermissionDenied,
+		},
+		"unsupported type": {
+			apiKey:       streamAPIKey,
+			req:          &pushpb.SubscribeRequest{Types: []string{"Page"}},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newGRPCTestServer(t)
+			stream, cancel := srv.subscribe(t, test.apiKey, test.req)
+			defer cancel()
+
+			_, err := stream.Recv()
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			assert.Empty(t, srv.dispatcher.Subscribers())
+		})
+	}
+}
@@ -4,14 +4,22 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/canary"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
 	"github.com/Financial-Times/service-status-go/gtg"
 )
 
-const panicGuideURL = "https://runbooks.in.ft.com/upp-notifications-push"
+const (
+	panicGuideURL = "https://runbooks.in.ft.com/upp-notifications-push"
+	// resultsTTL is how long the results of the checks are shared by the callers of Results
+	resultsTTL = dashboardRefresh
+)
 
 type RequestStatusFn func(ctx context.Context, url string) (int, error)
 
@@ -20,31 +28,85 @@ type kafkaConsumer interface {
 	MonitorCheck() error
 }
 
+type dispatcherHealth interface {
+	Latency() dispatch.LatencyReport
+	Pending() int
+	Subscribers() []dispatch.Subscriber
+	Frozen() (bool, time.Time)
+}
+
+// HealthThresholds configures the optional checks, a zero threshold leaves its check or condition out.
+// DeliveryLatency is the p95 time from the last modification of the content to the end of the fan-out
+// above which the delivery latency check fails, Saturation the limits of the dispatcher saturation check.
+type HealthThresholds struct {
+	DeliveryLatency time.Duration
+	Publishing      PublishingThresholds
+	Saturation      SaturationThresholds
+}
+
 type HealthCheck struct {
 	consumer             kafkaConsumer
 	StatusFunc           RequestStatusFn
 	apiGatewayGTGAddress string
+	policiesAddress      string
+	opaAgent             access.Agent
+	dispatcher           dispatcherHealth
+	activity             publishingActivity
+	canary               *canary.Canary
+	thresholds           HealthThresholds
 	serviceName          string
 	log                  *logger.UPPLogger
+	resultsLock          sync.Mutex
+	results              *fthealth.HealthResult
+	resultsAt            time.Time
 }
 
-func NewHealthCheck(kafkaConsumer kafkaConsumer, apiGatewayGTGAddress string, statusFunc RequestStatusFn, serviceName string, log *logger.UPPLogger) *HealthCheck {
+// NewHealthCheck creates the service healthcheck.
+// The API key policies check is left out when its address is empty, the OPA check when the agent is nil,
+// the delivery latency and saturation checks when the dispatcher is nil, the publishing activity check
+// when the activity is nil and the canary check when the canary is nil.
+func NewHealthCheck(kafkaConsumer kafkaConsumer, apiGatewayGTGAddress string, policiesAddress string, statusFunc RequestStatusFn, opaAgent access.Agent,
+	d dispatcherHealth, activity publishingActivity, probes *canary.Canary, thresholds HealthThresholds, serviceName string, log *logger.UPPLogger) *HealthCheck {
 	return &HealthCheck{
 		consumer:             kafkaConsumer,
 		apiGatewayGTGAddress: apiGatewayGTGAddress,
+		policiesAddress:      policiesAddress,
 		StatusFunc:           statusFunc,
+		opaAgent:             opaAgent,
+		dispatcher:           d,
+		activity:             activity,
+		canary:               probes,
+		thresholds:           thresholds,
 		serviceName:          serviceName,
 		log:                  log,
 	}
 }
 
-func (h *HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
+func (h *HealthCheck) healthCheck() fthealth.TimedHealthCheck {
 	var checks []fthealth.Check
 	checks = append(checks, h.kafkaConnectivityCheck())
 	checks = append(checks, h.kafkaLagCheck())
 	checks = append(checks, h.apiGatewayCheck())
+	if h.policiesAddress != "" {
+		checks = append(checks, h.apiKeyPoliciesCheck())
+	}
+	if h.opaAgent != nil {
+		checks = append(checks, h.opaCheck())
+	}
+	if h.dispatcher != nil && h.thresholds.DeliveryLatency > 0 {
+		checks = append(checks, h.deliveryLatencyCheck())
+	}
+	if h.dispatcher != nil && h.thresholds.Saturation.enabled() {
+		checks = append(checks, h.saturationCheck())
+	}
+	if h.activity != nil && h.thresholds.Publishing.enabled() {
+		checks = append(checks, h.publishingActivityCheck())
+	}
+	if h.canary != nil {
+		checks = append(checks, h.canaryCheck())
+	}
 
-	hc := fthealth.TimedHealthCheck{
+	return fthealth.TimedHealthCheck{
 		HealthCheck: fthealth.HealthCheck{
 			SystemCode:  "upp-notifications-push",
 			Name:        h.serviceName,
@@ -53,7 +115,24 @@ func (h *HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
 		},
 		Timeout: 10 * time.Second,
 	}
-	return fthealth.Handler(hc)
+}
+
+func (h *HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
+	return fthealth.Handler(h.healthCheck())
+}
+
+// Results returns the results of the checks. They are run at most once per resultsTTL,
+// the callers in between getting the cached results, so that every open dashboard does not run them again.
+func (h *HealthCheck) Results() fthealth.HealthResult {
+	h.resultsLock.Lock()
+	defer h.resultsLock.Unlock()
+
+	if h.results == nil || time.Since(h.resultsAt) >= resultsTTL {
+		results := fthealth.RunCheck(h.healthCheck())
+		h.results = &results
+		h.resultsAt = time.Now()
+	}
+	return *h.results
 }
 
 func (h *HealthCheck) kafkaLagCheck() fthealth.Check {
@@ -89,6 +168,13 @@ func (h *HealthCheck) GTG() gtg.Status {
 		return gtg.Status{GoodToGo: false, Message: err.Error()}
 	}
 
+	// without OPA every notification is dropped, the traffic is better served by another instance
+	if h.opaAgent != nil {
+		if _, err := h.checkOPA(); err != nil {
+			return gtg.Status{GoodToGo: false, Message: err.Error()}
+		}
+	}
+
 	return gtg.Status{GoodToGo: true}
 }
 
@@ -137,6 +223,110 @@ func (h *HealthCheck) checkAPIGatewayService() (string, error) {
 	return "", fmt.Errorf("unable to verify ApiGateway service is working")
 }
 
+func (h *HealthCheck) deliveryLatencyCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "delivery-latency",
+		Name:             "DeliveryLatency",
+		Severity:         2,
+		BusinessImpact:   "Notifications reach the subscribers later than promised after the content is published.",
+		TechnicalSummary: fmt.Sprintf("The p95 time from the last modification of the content to the notification being forwarded to the subscribers exceeds %v over the last %v. The report splits it into the Kafka arrival, the configured delay and the fan-out to find the slow step.", h.thresholds.DeliveryLatency, dispatch.LatencyWindow),
+		PanicGuide:       panicGuideURL,
+		Checker:          loggingCheck(h.log.WithField("check", "DeliveryLatency"), h.checkDeliveryLatency),
+	}
+}
+
+func (h *HealthCheck) checkDeliveryLatency() (string, error) {
+	r := h.dispatcher.Latency()
+	if r.Samples == 0 {
+		return fmt.Sprintf("No notification delivered in the last %v.", dispatch.LatencyWindow), nil
+	}
+	msg := fmt.Sprintf("p95 latency over %d notifications: total %v (kafka %v, delay %v, fan-out %v)",
+		r.Samples, r.Total.P95, r.Kafka.P95, r.Delay.P95, r.FanOut.P95)
+	if r.Total.P95 > h.thresholds.DeliveryLatency {
+		return "", fmt.Errorf("%s exceeds the %v threshold", msg, h.thresholds.DeliveryLatency)
+	}
+	return msg, nil
+}
+
+func (h *HealthCheck) canaryCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "canary-delivery",
+		Name:             "CanaryDelivery",
+		Severity:         2,
+		BusinessImpact:   "Notifications may not reach the subscribers even though they are consumed from Kafka.",
+		TechnicalSummary: "The canary notification sent periodically to the dispatcher did not come back to the canary subscriber within the deadline. Check whether the dispatcher is frozen, the OPA agent responds and the subscribers are not piling up.",
+		PanicGuide:       panicGuideURL,
+		Checker: loggingCheck(h.log.WithField("check", "CanaryDelivery"), func() (string, error) {
+			return h.checkCanary(time.Now())
+		}),
+	}
+}
+
+func (h *HealthCheck) checkCanary(now time.Time) (string, error) {
+	last, ok := h.canary.Last()
+	if !ok {
+		return "No canary notification sent yet.", nil
+	}
+	if last.Err != nil {
+		return "", fmt.Errorf("canary notification %s sent at %s failed: %w", last.TransactionID, last.At.Format(time.RFC3339), last.Err)
+	}
+	// the canary probes at every interval, a result older than two intervals means it is stuck
+	if age := now.Sub(last.At); age > 2*h.canary.Interval()+last.RoundTrip {
+		return "", fmt.Errorf("no canary notification sent for %v", age.Round(time.Second))
+	}
+	return fmt.Sprintf("Canary notification %s received in %v.", last.TransactionID, last.RoundTrip), nil
+}
+
+func (h *HealthCheck) opaCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "opa-policy-evaluation",
+		Name:             "OPAPolicyEvaluation",
+		Severity:         1,
+		BusinessImpact:   "No notification reaches the subscribers, every notification is dropped when its content policy cannot be evaluated.",
+		TechnicalSummary: "A sample notification content policy could not be evaluated by the OPA sidecar. Check the OPA container of the pod and the policy bundle it loaded.",
+		PanicGuide:       panicGuideURL,
+		Checker:          loggingCheck(h.log.WithField("check", "OPAPolicyEvaluation"), h.checkOPA),
+	}
+}
+
+func (h *HealthCheck) checkOPA() (string, error) {
+	result, err := h.opaAgent.EvaluateContentPolicy(map[string]interface{}{
+		"EditorialDesk": "",
+		"Publication":   "",
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Sample content policy evaluated, allow: %v, decision ID: %q", result.Allow, result.DecisionID), nil
+}
+
+func (h *HealthCheck) apiKeyPoliciesCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "api-key-policies-check",
+		Name:             "ApiKeyPoliciesCheck",
+		Severity:         2,
+		BusinessImpact:   "New subscribers cannot connect, the connected ones keep receiving notifications.",
+		TechnicalSummary: "The API Gateway endpoint giving the X-Policies of the API keys is not reachable or fails. Check api_key_policies_endpoint and the API Gateway.",
+		PanicGuide:       panicGuideURL,
+		Checker:          loggingCheck(h.log.WithField("check", "ApiKeyPoliciesCheck"), h.checkAPIKeyPolicies),
+	}
+}
+
+// checkAPIKeyPolicies calls the policies endpoint without API key, any response but a server error shows it is available
+func (h *HealthCheck) checkAPIKeyPolicies() (string, error) {
+	if h.StatusFunc == nil {
+		return "", fmt.Errorf("no status func")
+	}
+	statusCode, err := h.StatusFunc(context.Background(), h.policiesAddress)
+	if err != nil {
+		return "", err
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("API key policies endpoint returned status %d", statusCode)
+	}
+	return "API key policies endpoint is available", nil
+}
+
 type checker func() (string, error)
 
 func loggingCheck(log *logger.LogEntry, f checker) checker {
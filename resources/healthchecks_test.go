@@ -6,12 +6,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/canary"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
 	"github.com/Financial-Times/notifications-push/v5/mocks"
 )
 
@@ -95,7 +101,13 @@ func TestHealthcheck(t *testing.T) {
 			hc := NewHealthCheck(
 				test.kafkaConsumerMock,
 				"randomAddress",
+				"",
 				test.statusFn,
+				nil,
+				nil,
+				nil,
+				nil,
+				HealthThresholds{},
 				"notifications-push",
 				log)
 
@@ -119,3 +131,230 @@ func TestHealthcheck(t *testing.T) {
 		})
 	}
 }
+
+type stubDispatcherHealth struct {
+	latency     dispatch.LatencyReport
+	pending     int
+	subscribers []dispatch.Subscriber
+	frozenSince time.Time
+}
+
+func (s *stubDispatcherHealth) Latency() dispatch.LatencyReport {
+	return s.latency
+}
+
+func (s *stubDispatcherHealth) Pending() int {
+	return s.pending
+}
+
+func (s *stubDispatcherHealth) Subscribers() []dispatch.Subscriber {
+	return s.subscribers
+}
+
+func (s *stubDispatcherHealth) Frozen() (bool, time.Time) {
+	return !s.frozenSince.IsZero(), s.frozenSince
+}
+
+func TestDeliveryLatencyCheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+
+	tests := map[string]struct {
+		report      dispatch.LatencyReport
+		expectedMsg string
+		expectedErr string
+	}{
+		"no notification": {
+			expectedMsg: "No notification delivered in the last 15m0s.",
+		},
+		"within the threshold": {
+			report: dispatch.LatencyReport{
+				Samples: 10,
+				Kafka:   dispatch.LatencyPercentiles{P95: 2 * time.Second},
+				Delay:   dispatch.LatencyPercentiles{P95: 30 * time.Second},
+				FanOut:  dispatch.LatencyPercentiles{P95: time.Millisecond},
+				Total:   dispatch.LatencyPercentiles{P95: 32 * time.Second},
+			},
+			expectedMsg: "p95 latency over 10 notifications: total 32s (kafka 2s, delay 30s, fan-out 1ms)",
+		},
+		"above the threshold": {
+			report: dispatch.LatencyReport{
+				Samples: 10,
+				Kafka:   dispatch.LatencyPercentiles{P95: 2 * time.Minute},
+				Delay:   dispatch.LatencyPercentiles{P95: 30 * time.Second},
+				Total:   dispatch.LatencyPercentiles{P95: 2*time.Minute + 30*time.Second},
+			},
+			expectedErr: "p95 latency over 10 notifications: total 2m30s (kafka 2m0s, delay 30s, fan-out 0s) exceeds the 1m0s threshold",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			hc := NewHealthCheck(nil, "randomAddress", "", nil, nil, &stubDispatcherHealth{latency: test.report}, nil, nil,
+				HealthThresholds{DeliveryLatency: time.Minute}, "notifications-push", log)
+
+			check := hc.deliveryLatencyCheck()
+			assert.Equal(t, panicGuideURL, check.PanicGuide)
+			msg, err := check.Checker()
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedMsg, msg)
+		})
+	}
+}
+
+func TestDeliveryLatencyCheckDisabled(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	kafka := &mocks.KafkaConsumer{
+		ConnectivityCheckF: func() error { return nil },
+		MonitorCheckF:      func() error { return nil },
+	}
+	statusFn := func(ctx context.Context, url string) (int, error) { return http.StatusOK, nil }
+
+	for threshold, expected := range map[time.Duration]bool{0: false, time.Minute: true} {
+		hc := NewHealthCheck(kafka, "randomAddress", "", statusFn, nil, &stubDispatcherHealth{}, nil, nil,
+			HealthThresholds{DeliveryLatency: threshold}, "notifications-push", log)
+		rr := httptest.NewRecorder()
+		hc.Health()(rr, httptest.NewRequest(http.MethodGet, "/__health", nil))
+		assert.Equal(t, expected, bytes.Contains(rr.Body.Bytes(), []byte(`"id":"delivery-latency"`)))
+	}
+}
+
+func TestHealthResultsCached(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	var connectivityChecks int32
+	kafka := &mocks.KafkaConsumer{
+		ConnectivityCheckF: func() error {
+			atomic.AddInt32(&connectivityChecks, 1)
+			return nil
+		},
+		MonitorCheckF: func() error { return nil },
+	}
+	statusFn := func(ctx context.Context, url string) (int, error) { return http.StatusOK, nil }
+	hc := NewHealthCheck(kafka, "randomAddress", "", statusFn, nil, nil, nil, nil, HealthThresholds{}, "notifications-push", log)
+
+	first := hc.Results()
+	second := hc.Results()
+	assert.True(t, first.Ok)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connectivityChecks), "The checks should run once within the TTL")
+
+	hc.resultsLock.Lock()
+	hc.resultsAt = hc.resultsAt.Add(-resultsTTL)
+	hc.resultsLock.Unlock()
+	hc.Results()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&connectivityChecks), "The checks should run again once the TTL is over")
+}
+
+func TestCanaryCheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil,
+		&stubAgent{result: &access.ContentPolicyResult{Allow: true}}, nil, log)
+	go d.Start()
+	defer d.Stop()
+
+	probes := canary.New(d, time.Minute, 50*time.Millisecond, nil, log)
+	hc := NewHealthCheck(nil, "randomAddress", "", nil, nil, nil, nil, probes, HealthThresholds{}, "notifications-push", log)
+
+	msg, err := hc.checkCanary(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "No canary notification sent yet.", msg)
+
+	result := probes.Probe()
+	require.NoError(t, result.Err)
+	msg, err = hc.checkCanary(time.Now())
+	assert.NoError(t, err)
+	assert.Contains(t, msg, result.TransactionID)
+
+	_, err = hc.checkCanary(result.At.Add(3 * time.Minute))
+	assert.EqualError(t, err, "no canary notification sent for 3m0s")
+
+	d.Freeze()
+	defer d.Unfreeze()
+	result = probes.Probe()
+	_, err = hc.checkCanary(time.Now())
+	assert.ErrorContains(t, err, "canary notification "+result.TransactionID)
+	assert.ErrorContains(t, err, "not received within 50ms")
+}
+
+func TestOPACheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+	kafka := &mocks.KafkaConsumer{
+		ConnectivityCheckF: func() error { return nil },
+		MonitorCheckF:      func() error { return nil },
+	}
+	statusFn := func(ctx context.Context, url string) (int, error) { return http.StatusOK, nil }
+
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true, DecisionID: "decision-1"}}
+	hc := NewHealthCheck(kafka, "randomAddress", "", statusFn, agent, nil, nil, nil, HealthThresholds{}, "notifications-push", log)
+	msg, err := hc.checkOPA()
+	assert.NoError(t, err)
+	assert.Equal(t, `Sample content policy evaluated, allow: true, decision ID: "decision-1"`, msg)
+	assert.True(t, hc.GTG().GoodToGo)
+
+	agent = &stubAgent{err: fmt.Errorf("%w: connection refused", access.ErrEvaluatePolicy)}
+	hc = NewHealthCheck(kafka, "randomAddress", "", statusFn, agent, nil, nil, nil, HealthThresholds{}, "notifications-push", log)
+	_, err = hc.checkOPA()
+	assert.ErrorIs(t, err, access.ErrEvaluatePolicy)
+	status := hc.GTG()
+	assert.False(t, status.GoodToGo)
+	assert.Equal(t, "error evaluating policy: connection refused", status.Message)
+}
+
+func TestAPIKeyPoliciesCheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+
+	tests := map[string]struct {
+		statusFn    RequestStatusFn
+		expectedErr string
+	}{
+		"unauthorized without key": {
+			statusFn: func(ctx context.Context, url string) (int, error) { return http.StatusUnauthorized, nil },
+		},
+		"server error": {
+			statusFn:    func(ctx context.Context, url string) (int, error) { return http.StatusBadGateway, nil },
+			expectedErr: "API key policies endpoint returned status 502",
+		},
+		"unreachable": {
+			statusFn:    func(ctx context.Context, url string) (int, error) { return 0, fmt.Errorf("connection refused") },
+			expectedErr: "connection refused",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var called string
+			statusFn := func(ctx context.Context, url string) (int, error) {
+				called = url
+				return test.statusFn(ctx, url)
+			}
+			hc := NewHealthCheck(nil, "randomAddress", "http://api.ft.com/t800/policy", statusFn, nil, nil, nil, nil, HealthThresholds{}, "notifications-push", log)
+
+			_, err := hc.checkAPIKeyPolicies()
+			assert.Equal(t, "http://api.ft.com/t800/policy", called)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
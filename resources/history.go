@@ -1,21 +1,55 @@
 package resources
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
 )
 
+const (
+	standardHistoryView = "standard"
+	advancedHistoryView = "advanced"
+)
+
+type historyQuery struct {
+	filter  dispatch.HistoryFilter
+	limit   int
+	offset  int
+	view    string
+	format  string
+	columns []historyColumn
+}
+
 // History returns history data
 func History(history dispatch.History, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
 	errMsg := "Serving /__history request"
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-type", "application/json; charset=UTF-8")
+		q, err := parseHistoryQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		matching := q.filter.Apply(history.Notifications())
+		page := paginate(matching, q.offset, q.limit)
+
+		options := &access.NotificationSubscriptionOptions{
+			ReceiveAdvancedNotifications: q.view == advancedHistoryView,
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(matching)))
+		if q.format != jsonFormat {
+			exportHistory(w, q, page, options, log)
+			return
+		}
 
-		var notifications []dispatch.NotificationResponse
-		for _, n := range history.Notifications() {
-			notifications = append(notifications, dispatch.CreateNotificationResponse(n, nil))
+		notifications := make([]dispatch.NotificationResponse, 0, len(page))
+		for _, n := range page {
+			notifications = append(notifications, dispatch.CreateNotificationResponse(n, options))
 		}
 
 		historyJSON, err := dispatch.MarshalNotificationResponsesJSON(notifications)
@@ -25,6 +59,7 @@ func History(history dispatch.History, log *logger.UPPLogger) func(w http.Respon
 			return
 		}
 
+		w.Header().Set("Content-type", "application/json; charset=UTF-8")
 		_, err = w.Write(historyJSON)
 		if err != nil {
 			log.WithError(err).Warn(errMsg)
@@ -32,3 +67,102 @@ func History(history dispatch.History, log *logger.UPPLogger) func(w http.Respon
 		}
 	}
 }
+
+// exportHistory streams the notifications in the requested export format.
+// Once streaming has started errors can only be logged, as the status has already been sent.
+func exportHistory(w http.ResponseWriter, q historyQuery, notifications []dispatch.NotificationModel, options *access.NotificationSubscriptionOptions, log *logger.UPPLogger) {
+	errMsg := "Exporting /__history"
+
+	exporter, err := newHistoryExporter(q.format, q.columns, w)
+	if err != nil {
+		log.WithError(err).Warn(errMsg)
+		return
+	}
+	for _, n := range notifications {
+		if err = exporter.writeRow(dispatch.CreateNotificationResponse(n, options), n); err != nil {
+			log.WithError(err).Warn(errMsg)
+			return
+		}
+	}
+	if err = exporter.close(); err != nil {
+		log.WithError(err).Warn(errMsg)
+	}
+}
+
+func parseHistoryQuery(r *http.Request) (historyQuery, error) {
+	values := r.URL.Query()
+	q := historyQuery{
+		filter: dispatch.HistoryFilter{
+			ContentUUID:       values.Get("uuid"),
+			TransactionID:     values.Get("tid"),
+			Types:             values["type"],
+			SubscriptionTypes: values["subscriptionType"],
+			Publication:       values.Get("publication"),
+			EditorialDesk:     values.Get("editorialDesk"),
+		},
+		view: standardHistoryView,
+	}
+
+	var err error
+	if q.filter.From, err = parseTimeParam(values.Get("from")); err != nil {
+		return q, fmt.Errorf("invalid from parameter: %w", err)
+	}
+	if q.filter.To, err = parseTimeParam(values.Get("to")); err != nil {
+		return q, fmt.Errorf("invalid to parameter: %w", err)
+	}
+	if q.limit, err = parseNonNegativeParam(values.Get("limit")); err != nil {
+		return q, fmt.Errorf("invalid limit parameter: %w", err)
+	}
+	if q.offset, err = parseNonNegativeParam(values.Get("offset")); err != nil {
+		return q, fmt.Errorf("invalid offset parameter: %w", err)
+	}
+
+	if view := values.Get("view"); view != "" {
+		if view != standardHistoryView && view != advancedHistoryView {
+			return q, fmt.Errorf("invalid view parameter: %q is neither %q nor %q", view, standardHistoryView, advancedHistoryView)
+		}
+		q.view = view
+	}
+
+	if q.format, err = negotiateHistoryFormat(r); err != nil {
+		return q, err
+	}
+	if q.columns, err = selectHistoryColumns(values.Get("fields")); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+func parseNonNegativeParam(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("%d is negative", i)
+	}
+	return i, nil
+}
+
+// paginate returns limit items starting from offset. A zero limit returns all the remaining items.
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
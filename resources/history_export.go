@@ -0,0 +1,237 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const (
+	jsonFormat   = "json"
+	ndjsonFormat = "ndjson"
+	csvFormat    = "csv"
+)
+
+var exportMediaTypes = map[string]string{
+	"application/x-ndjson": ndjsonFormat,
+	"application/ndjson":   ndjsonFormat,
+	"text/csv":             csvFormat,
+}
+
+// historyColumn is a field of a history export
+type historyColumn struct {
+	name  string
+	value func(r dispatch.NotificationResponse, n dispatch.NotificationModel) interface{}
+}
+
+var historyColumns = []historyColumn{
+	{"apiUrl", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} { return r.APIURL }},
+	{"id", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} { return r.ID }},
+	{"type", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} { return r.Type }},
+	{"publishReference", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} {
+		return r.PublishReference
+	}},
+	{"lastModified", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} { return r.LastModified }},
+	{"notificationDate", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} {
+		return r.NotificationDate
+	}},
+	{"title", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} { return r.Title }},
+	{"scoop", func(r dispatch.NotificationResponse, _ dispatch.NotificationModel) interface{} {
+		return r.Standout != nil && r.Standout.Scoop
+	}},
+	{"editorialDesk", func(_ dispatch.NotificationResponse, n dispatch.NotificationModel) interface{} {
+		return n.EditorialDesk
+	}},
+	{"subscriptionType", func(_ dispatch.NotificationResponse, n dispatch.NotificationModel) interface{} {
+		return n.SubscriptionType
+	}},
+	{"publication", func(_ dispatch.NotificationResponse, n dispatch.NotificationModel) interface{} {
+		publications := []string{}
+		if n.Publication != nil {
+			for _, u := range n.Publication.UUIDS {
+				publications = append(publications, u.String())
+			}
+		}
+		return publications
+	}},
+	{"isE2ETest", func(_ dispatch.NotificationResponse, n dispatch.NotificationModel) interface{} { return n.IsE2ETest }},
+}
+
+// negotiateHistoryFormat returns the export format requested either by the format query parameter or the Accept header.
+func negotiateHistoryFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case jsonFormat, ndjsonFormat, csvFormat:
+			return format, nil
+		default:
+			return "", fmt.Errorf("invalid format parameter: %q", format)
+		}
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if format, ok := exportMediaTypes[mediaType]; ok {
+			return format, nil
+		}
+	}
+
+	return jsonFormat, nil
+}
+
+// selectHistoryColumns returns the columns listed in the comma separated fields, or all columns if fields is empty.
+func selectHistoryColumns(fields string) ([]historyColumn, error) {
+	if fields == "" {
+		return historyColumns, nil
+	}
+
+	var columns []historyColumn
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, c := range historyColumns {
+			if strings.EqualFold(c.name, name) {
+				columns = append(columns, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid fields parameter: unknown field %q", name)
+		}
+	}
+
+	return columns, nil
+}
+
+// historyExporter writes the history rows one by one, flushing each to the client.
+type historyExporter interface {
+	writeRow(r dispatch.NotificationResponse, n dispatch.NotificationModel) error
+	close() error
+}
+
+func newHistoryExporter(format string, columns []historyColumn, w http.ResponseWriter) (historyExporter, error) {
+	flusher, _ := w.(http.Flusher)
+
+	switch format {
+	case ndjsonFormat:
+		w.Header().Set("Content-type", "application/x-ndjson; charset=UTF-8")
+		return &ndjsonExporter{w: w, flusher: flusher, columns: columns}, nil
+	case csvFormat:
+		w.Header().Set("Content-type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="history.csv"`)
+		e := &csvExporter{w: csv.NewWriter(w), flusher: flusher, columns: columns}
+		return e, e.writeHeader()
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type ndjsonExporter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	columns []historyColumn
+}
+
+// writeRow writes the row as a JSON object with the fields in the order of the columns
+func (e *ndjsonExporter) writeRow(r dispatch.NotificationResponse, n dispatch.NotificationModel) error {
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte('{')
+	for i, c := range e.columns {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := encodeJSONValue(buffer, c.name); err != nil {
+			return err
+		}
+		buffer.WriteByte(':')
+		if err := encodeJSONValue(buffer, c.value(r, n)); err != nil {
+			return err
+		}
+	}
+	buffer.WriteString("}\n")
+
+	if _, err := e.w.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+func (e *ndjsonExporter) close() error {
+	return nil
+}
+
+func encodeJSONValue(buffer *bytes.Buffer, v interface{}) error {
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	// the encoder ends each value with a newline
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+type csvExporter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	columns []historyColumn
+}
+
+func (e *csvExporter) writeHeader() error {
+	header := make([]string, 0, len(e.columns))
+	for _, c := range e.columns {
+		header = append(header, c.name)
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvExporter) writeRow(r dispatch.NotificationResponse, n dispatch.NotificationModel) error {
+	row := make([]string, 0, len(e.columns))
+	for _, c := range e.columns {
+		switch v := c.value(r, n).(type) {
+		case string:
+			row = append(row, escapeFormula(v))
+		case bool:
+			row = append(row, strconv.FormatBool(v))
+		case []string:
+			row = append(row, strings.Join(v, ";"))
+		default:
+			row = append(row, fmt.Sprint(v))
+		}
+	}
+	if err := e.w.Write(row); err != nil {
+		return err
+	}
+	e.w.Flush()
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return e.w.Error()
+}
+
+func (e *csvExporter) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula prefixes the cells which spreadsheet tools would evaluate as a formula, such as titles starting with "=",
+// with a single quote so they are displayed as text.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
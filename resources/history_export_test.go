@@ -0,0 +1,156 @@
+package resources
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/publication"
+)
+
+func newExportHistory() dispatch.History {
+	history := dispatch.NewHistory(10, dispatch.OrderByArrival)
+	history.Push(dispatch.NotificationModel{
+		APIURL:           "http://api.ft.com/content/7998974a-1e97-11e6-b286-cddde55ca122",
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_1",
+		Title:            "Markets & economy",
+		SubscriptionType: dispatch.ArticleContentType,
+		EditorialDesk:    "/FT/Pink",
+		Standout:         &dispatch.Standout{Scoop: true},
+		Publication: &publication.Publications{
+			UUIDS: []uuid.UUID{uuid.MustParse(publication.PinkFt)},
+		},
+	})
+	history.Push(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/3cc23068-e501-11e9-9743-db5a370481bc",
+		Type:             dispatch.ContentDeleteType,
+		PublishReference: "tid_2",
+		IsE2ETest:        true,
+	})
+	return history
+}
+
+func TestHistoryExportNDJSON(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+
+	req, err := http.NewRequest("GET", "/__history", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	w := httptest.NewRecorder()
+	History(newExportHistory(), l)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson; charset=UTF-8", w.Header().Get("Content-Type"))
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		row := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, 2)
+	assert.Equal(t, "tid_2", rows[0]["publishReference"])
+	assert.Equal(t, true, rows[0]["isE2ETest"])
+	assert.Equal(t, "Markets & economy", rows[1]["title"])
+	assert.Equal(t, "/FT/Pink", rows[1]["editorialDesk"])
+	assert.Equal(t, "Article", rows[1]["subscriptionType"])
+	assert.Equal(t, []interface{}{publication.PinkFt}, rows[1]["publication"])
+	assert.Equal(t, true, rows[1]["scoop"])
+}
+
+func TestHistoryExportCSV(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+
+	req, err := http.NewRequest("GET", "/__history?format=csv&fields=publishReference,type,editorialDesk,publication&tid=tid_1", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	History(newExportHistory(), l)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", w.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"publishReference", "type", "editorialDesk", "publication"},
+		{"tid_1", dispatch.ContentUpdateType, "/FT/Pink", publication.PinkFt},
+	}, records)
+}
+
+func TestHistoryExportNDJSONFieldOrder(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+
+	req, err := http.NewRequest("GET", "/__history?format=ndjson&fields=type,publishReference,isE2ETest&tid=tid_2", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	History(newExportHistory(), l)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"type":"`+dispatch.ContentDeleteType+`","publishReference":"tid_2","isE2ETest":true}`+"\n", w.Body.String())
+}
+
+func TestHistoryExportCSVEscapesFormulas(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	history := dispatch.NewHistory(10, dispatch.OrderByArrival)
+	for _, title := range []string{`=HYPERLINK("http://example.com")`, "+1", "-1", "@SUM(A1)", "Markets - economy"} {
+		history.Push(dispatch.NotificationModel{Title: title, Type: dispatch.ContentUpdateType})
+	}
+
+	req, err := http.NewRequest("GET", "/__history?format=csv&fields=title", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	History(history, l)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"title"},
+		{"Markets - economy"},
+		{"'@SUM(A1)"},
+		{"'-1"},
+		{"'+1"},
+		{`'=HYPERLINK("http://example.com")`},
+	}, records)
+}
+
+func TestHistoryExportInvalidParameters(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+
+	for _, query := range []string{"?format=xml", "?format=csv&fields=apiUrl,unknown"} {
+		req, err := http.NewRequest("GET", "/__history"+query, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		History(newExportHistory(), l)(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
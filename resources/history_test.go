@@ -1,11 +1,13 @@
 package resources
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
@@ -15,7 +17,7 @@ func TestHistory(t *testing.T) {
 	t.Parallel()
 
 	l := logger.NewUPPLogger("test", "panic")
-	history := dispatch.NewHistory(1)
+	history := dispatch.NewHistory(1, dispatch.OrderByLastModified)
 
 	req, err := http.NewRequest("GET", "/__history", nil)
 	if err != nil {
@@ -28,3 +30,141 @@ func TestHistory(t *testing.T) {
 	assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "Should be json")
 	assert.Equal(t, 200, w.Code, "Should be OK")
 }
+
+func TestHistoryQuery(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	history := dispatch.NewHistory(10, dispatch.OrderByArrival)
+	history.Push(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentCreateType,
+		PublishReference: "tid_1",
+		NotificationDate: "2016-11-02T10:54:22.234Z",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+	history.Push(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/3cc23068-e501-11e9-9743-db5a370481bc",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_2",
+		NotificationDate: "2016-11-02T10:55:22.234Z",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+	history.Push(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/3cc23068-e501-11e9-9743-db5a370481bc",
+		Type:             dispatch.ContentDeleteType,
+		PublishReference: "tid_3",
+		NotificationDate: "2016-11-02T10:56:22.234Z",
+		SubscriptionType: dispatch.AudioContentType,
+	})
+
+	tests := map[string]struct {
+		query          string
+		expectedStatus int
+		expectedTIDs   []string
+		expectedTypes  []string
+		expectedTotal  string
+	}{
+		"no filters": {
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_3", "tid_2", "tid_1"},
+			expectedTotal:  "3",
+		},
+		"by content uuid": {
+			query:          "?uuid=3cc23068-e501-11e9-9743-db5a370481bc",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_3", "tid_2"},
+			expectedTotal:  "2",
+		},
+		"by transaction id": {
+			query:          "?tid=tid_2",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_2"},
+			expectedTotal:  "1",
+		},
+		"by type and subscription type": {
+			query:          "?type=UPDATE&type=DELETE&subscriptionType=Article",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_2"},
+			expectedTotal:  "1",
+		},
+		"by time range": {
+			query:          "?from=2016-11-02T10:55:00Z&to=2016-11-02T10:57:00Z",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_3", "tid_2"},
+			expectedTotal:  "2",
+		},
+		"paged": {
+			query:          "?limit=1&offset=1",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_2"},
+			expectedTotal:  "3",
+		},
+		"offset out of range": {
+			query:          "?offset=5",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{},
+			expectedTotal:  "3",
+		},
+		"standard view hides create events": {
+			query:          "?tid=tid_1",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_1"},
+			expectedTypes:  []string{dispatch.ContentUpdateType},
+			expectedTotal:  "1",
+		},
+		"advanced view keeps create events": {
+			query:          "?tid=tid_1&view=advanced",
+			expectedStatus: http.StatusOK,
+			expectedTIDs:   []string{"tid_1"},
+			expectedTypes:  []string{dispatch.ContentCreateType},
+			expectedTotal:  "1",
+		},
+		"invalid view": {
+			query:          "?view=full",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid time": {
+			query:          "?from=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"negative limit": {
+			query:          "?limit=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest("GET", "/__history"+test.query, nil)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			History(history, l)(w, req)
+
+			require.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var notifications []dispatch.NotificationResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &notifications))
+
+			tids := []string{}
+			types := []string{}
+			for _, n := range notifications {
+				tids = append(tids, n.PublishReference)
+				types = append(types, n.Type)
+			}
+			assert.Equal(t, test.expectedTIDs, tids)
+			if test.expectedTypes != nil {
+				assert.Equal(t, test.expectedTypes, types)
+			}
+			assert.Equal(t, test.expectedTotal, w.Header().Get("X-Total-Count"))
+		})
+	}
+}
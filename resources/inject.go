@@ -0,0 +1,181 @@
+package resources
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	ftMessageVersion = "FTMSG/1.0"
+	requestIDHeader  = "X-Request-Id"
+	maxInjectSize    = 1 << 20
+)
+
+type messageProcessor interface {
+	Process(queueMsg kafka.FTMessage) queueConsumer.Result
+}
+
+type injectRequest struct {
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+type injectResponse struct {
+	TransactionID    string                         `json:"transactionId"`
+	Outcome          queueConsumer.Outcome          `json:"outcome"`
+	Error            string                         `json:"error,omitempty"`
+	Notification     *dispatch.NotificationResponse `json:"notification,omitempty"`
+	SubscriptionType string                         `json:"subscriptionType,omitempty"`
+	EditorialDesk    string                         `json:"editorialDesk,omitempty"`
+	IsE2ETest        bool                           `json:"isE2ETest,omitempty"`
+}
+
+// Inject feeds a Kafka message given over HTTP to the queue handler, going through the same filtering, mapping
+// and dispatching as the consumed messages, and returns the notification produced or the reason it was rejected.
+// The message is given either in the FTMSG/1.0 format or as JSON with headers and body.
+// The injection is refused while the consumption is paused, as the consumed messages are held.
+func (h *AdminHandler) Inject(w http.ResponseWriter, r *http.Request) {
+	if h.consumption != nil {
+		if paused, _ := h.consumption.Paused(); paused {
+			http.Error(w, "Consumption is paused, resume it to inject messages", http.StatusConflict)
+			return
+		}
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxInjectSize))
+	if err != nil {
+		http.Error(w, "Failed to read message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var msg kafka.FTMessage
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		msg, err = parseInjectRequest(raw)
+	} else {
+		msg, err = parseFTMessage(string(raw))
+	}
+	if err != nil {
+		http.Error(w, "Invalid message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if msg.Headers[requestIDHeader] == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			http.Error(w, "Failed to generate transaction ID", http.StatusInternalServerError)
+			return
+		}
+		msg.Headers[requestIDHeader] = "tid_" + id.String()
+	}
+	tid := msg.Headers[requestIDHeader]
+
+	result := h.processor.Process(msg)
+
+	resp := injectResponse{
+		TransactionID: tid,
+		Outcome:       result.Outcome,
+	}
+	if result.Err != nil {
+		resp.Error = result.Err.Error()
+	}
+	if n := result.Notification; n != nil {
+		view := dispatch.CreateNotificationResponse(*n, &access.NotificationSubscriptionOptions{
+			ReceiveAdvancedNotifications: true,
+			ReceiveInternalUnstable:      true,
+		})
+		resp.Notification = &view
+		resp.SubscriptionType = n.SubscriptionType
+		resp.EditorialDesk = n.EditorialDesk
+		resp.IsE2ETest = n.IsE2ETest
+	}
+
+	h.log.WithTransactionID(tid).
+		WithField("address", getClientAddr(r)).
+		WithField("outcome", result.Outcome).
+		Info("Admin injected message")
+
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithTransactionID(tid).WithError(err).Warn("Error in marshalling inject response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	if _, err = w.Write(bytes); err != nil {
+		h.log.WithTransactionID(tid).WithError(err).Warn("Error writing inject response")
+	}
+}
+
+func parseInjectRequest(raw []byte) (kafka.FTMessage, error) {
+	req := injectRequest{}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return kafka.FTMessage{}, err
+	}
+	if len(req.Body) == 0 {
+		return kafka.FTMessage{}, errors.New("body is required")
+	}
+	if req.Headers == nil {
+		req.Headers = map[string]string{}
+	}
+
+	// the body is either the message JSON itself or a string holding it
+	body := string(req.Body)
+	var s string
+	if err := json.Unmarshal(req.Body, &s); err == nil {
+		body = s
+	}
+	return kafka.NewFTMessage(req.Headers, body), nil
+}
+
+// parseFTMessage reads a message in the FTMSG/1.0 format: the version line, one header per line,
+// a blank line and the body. Both CRLF and LF line endings are accepted.
+func parseFTMessage(raw string) (kafka.FTMessage, error) {
+	reader := bufio.NewReader(strings.NewReader(raw))
+
+	version, err := reader.ReadString('\n')
+	if strings.TrimSpace(version) != ftMessageVersion {
+		return kafka.FTMessage{}, fmt.Errorf("message must start with %s", ftMessageVersion)
+	}
+	if err != nil {
+		return kafka.FTMessage{}, errors.New("message has no body")
+	}
+
+	headers := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil {
+				return kafka.FTMessage{}, errors.New("message has no body")
+			}
+			break
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(key) == "" {
+			return kafka.FTMessage{}, fmt.Errorf("invalid header line %q", line)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		if err != nil {
+			return kafka.FTMessage{}, errors.New("message has no body")
+		}
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return kafka.FTMessage{}, err
+	}
+	return kafka.NewFTMessage(headers, strings.TrimSpace(string(body))), nil
+}
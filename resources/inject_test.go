@@ -0,0 +1,164 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	queueConsumer "github.com/Financial-Times/notifications-push/v5/consumer"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+func TestInject(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	mapper := queueConsumer.NotificationMapper{
+		APIBaseURL:      "http://api.ft.com",
+		APIUrlResource:  "content",
+		UpdateEventType: dispatch.ContentUpdateType,
+	}
+	contentTypes := queueConsumer.NewSet()
+	contentTypes.Add("application/vnd.ft-upp-live-blog-post+json")
+	allowlist := regexp.MustCompile(`^http://upp-content-validator\.svc\.ft\.com/content/[\w-]+$`)
+
+	tests := map[string]struct {
+		contentType        string
+		body               string
+		expectedStatus     int
+		expectedTID        string
+		expectedOutcome    queueConsumer.Outcome
+		expectNotification bool
+	}{
+		"ftmsg format": {
+			body: "FTMSG/1.0\r\nX-Request-Id: tid_inject\r\nContent-Type: application/vnd.ft-upp-live-blog-post+json\r\n\r\n" +
+				`{"payload": {"title": "Title", "type": "LiveBlogPost"}, "lastModified": "2020-08-11T09:00:00.020Z", ` +
+				`"contentURI": "http://upp-content-validator.svc.ft.com/content/661516b6-2917-42fb-92b9-326bb205ae53"}`,
+			expectedStatus:     http.StatusOK,
+			expectedTID:        "tid_inject",
+			expectedOutcome:    queueConsumer.OutcomeDispatched,
+			expectNotification: true,
+		},
+		"json format with rejected content uri": {
+			contentType:     "application/json",
+			body:            `{"headers": {"X-Request-Id": "tid_inject", "Content-Type": "application/json"}, "body": {"contentURI": "http://not-in-the-allowlist"}}`,
+			expectedStatus:  http.StatusOK,
+			expectedTID:     "tid_inject",
+			expectedOutcome: queueConsumer.OutcomeAllowlistRejected,
+		},
+		"json format with string body and generated transaction id": {
+			contentType:     "application/json",
+			body:            `{"headers": {"Content-Type": "application/vnd.ft-upp-article+json"}, "body": "{}"}`,
+			expectedStatus:  http.StatusOK,
+			expectedOutcome: queueConsumer.OutcomeContentTypeRejected,
+		},
+		"missing version": {
+			body:           "X-Request-Id: tid_inject\n\n{}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid header": {
+			body:           "FTMSG/1.0\nX-Request-Id\n\n{}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"missing body": {
+			body:           "FTMSG/1.0\nX-Request-Id: tid_inject\n",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"json without body": {
+			contentType:    "application/json",
+			body:           `{"headers": {"X-Request-Id": "tid_inject"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &mocks.Dispatcher{}
+			d.On("Send", mock.AnythingOfType("dispatch.NotificationModel")).Return()
+			handler := queueConsumer.NewQueueHandler(allowlist, contentTypes, nil, false, mapper, d, l)
+			admin := NewAdminHandler("secret", d, nil, nil, handler, nil, nil, nil, nil, nil, time.Second, l)
+
+			req := httptest.NewRequest(http.MethodPost, "/__admin/inject", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+			admin.Inject(w, req)
+
+			require.Equal(t, test.expectedStatus, w.Code, w.Body.String())
+			if test.expectedStatus != http.StatusOK {
+				d.AssertNotCalled(t, "Send", mock.Anything)
+				return
+			}
+
+			resp := injectResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, test.expectedOutcome, resp.Outcome)
+			if test.expectedTID != "" {
+				assert.Equal(t, test.expectedTID, resp.TransactionID)
+			} else {
+				assert.True(t, strings.HasPrefix(resp.TransactionID, "tid_"), "A transaction ID should be generated")
+			}
+			if !test.expectNotification {
+				assert.Nil(t, resp.Notification)
+				d.AssertNotCalled(t, "Send", mock.Anything)
+				return
+			}
+			require.NotNil(t, resp.Notification)
+			assert.Equal(t, "http://www.ft.com/thing/661516b6-2917-42fb-92b9-326bb205ae53", resp.Notification.ID)
+			assert.Equal(t, "Title", resp.Notification.Title)
+			assert.Equal(t, dispatch.LiveBlogPostType, resp.SubscriptionType)
+			d.AssertNumberOfCalls(t, "Send", 1)
+		})
+	}
+}
+
+type processorFunc func(queueMsg kafka.FTMessage) queueConsumer.Result
+
+func (f processorFunc) Process(queueMsg kafka.FTMessage) queueConsumer.Result {
+	return f(queueMsg)
+}
+
+func TestInjectWhilePaused(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := &mocks.Dispatcher{}
+	processed := 0
+	processor := processorFunc(func(_ kafka.FTMessage) queueConsumer.Result {
+		processed++
+		return queueConsumer.Result{Outcome: queueConsumer.OutcomeCarousel}
+	})
+	consumption := queueConsumer.NewPausableHandler(nil)
+	admin := NewAdminHandler("secret", d, nil, nil, processor, consumption, nil, nil, nil, nil, time.Second, l)
+
+	inject := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		admin.Inject(w, httptest.NewRequest(http.MethodPost, "/__admin/inject", strings.NewReader("FTMSG/1.0\nX-Request-Id: tid_inject\n\n{}")))
+		return w
+	}
+
+	consumption.Pause()
+	w := inject()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Zero(t, processed, "The message should not be processed while the consumption is paused")
+
+	consumption.Resume()
+	w = inject()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, processed)
+}
@@ -0,0 +1,61 @@
+package resources
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultRetryAfter = time.Minute
+
+// Maintenance rejects the new subscriptions while it is enabled. The streams already open are kept alive.
+type Maintenance struct {
+	mutex      *sync.RWMutex
+	since      time.Time
+	retryAfter time.Duration
+}
+
+func NewMaintenance() *Maintenance {
+	return &Maintenance{
+		mutex: &sync.RWMutex{},
+	}
+}
+
+// Enable starts rejecting new subscriptions, advising the clients to retry after the given duration.
+// It returns false if the maintenance mode was already enabled, in which case only the retry duration is updated.
+func (m *Maintenance) Enable(retryAfter time.Duration) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	m.retryAfter = retryAfter
+	if !m.since.IsZero() {
+		return false
+	}
+	m.since = time.Now()
+	return true
+}
+
+// Disable accepts new subscriptions again. It returns false if the maintenance mode was not enabled.
+func (m *Maintenance) Disable() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.since.IsZero() {
+		return false
+	}
+	m.since = time.Time{}
+	return true
+}
+
+// Status returns whether the maintenance mode is enabled, since when and the retry duration advised to the clients
+func (m *Maintenance) Status() (bool, time.Time, time.Duration) {
+	if m == nil {
+		return false, time.Time{}, 0
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return !m.since.IsZero(), m.since, m.retryAfter
+}
@@ -0,0 +1,123 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const (
+	defaultPullLimit = 50
+	maxPullLimit     = 500
+	// maxPullWait is shorter than the shutdown timeout, so the waiting requests end before the server is stopped
+	maxPullWait = 20 * time.Second
+)
+
+type pullQuery struct {
+	cursor  dispatch.Cursor
+	limit   int
+	wait    time.Duration
+	monitor bool
+}
+
+type pullPage struct {
+	Notifications json.RawMessage `json:"notifications"`
+	Cursor        string          `json:"cursor"`
+	Truncated     bool            `json:"truncated"`
+}
+
+// HandlePull returns the page of notifications following the cursor param, as they were sent on the push stream
+// to a subscriber with the same API key and the same type and monitor params, together with the cursor of the next page.
+// When there is no notification yet the request waits for the wait param duration for a new one.
+// The page is flagged as truncated when notifications following the cursor dropped out of the history before they were pulled.
+func (h *SubHandler) HandlePull(w http.ResponseWriter, r *http.Request) {
+	q, err := parsePullQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, subTypes, subErr := h.authorize(r.Context(), getAPIKey(r), r.URL.Query()["type"])
+	if subErr != nil {
+		subErr.write(w)
+		return
+	}
+
+	var timeout <-chan time.Time
+	if q.wait > 0 {
+		timer := time.NewTimer(q.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		// the channel is taken before reading the history, so no notification added in between is missed
+		updated := h.notif.HistoryUpdated()
+		notifications, next, truncated := h.notif.Pull(q.cursor, subTypes, q.monitor, options, q.limit)
+		q.cursor = next
+		if len(notifications) > 0 || truncated || timeout == nil {
+			h.writePullPage(w, notifications, next, truncated)
+			return
+		}
+		select {
+		case <-updated:
+		case <-timeout:
+			timeout = nil
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *SubHandler) writePullPage(w http.ResponseWriter, notifications []dispatch.NotificationResponse, next dispatch.Cursor, truncated bool) {
+	errMsg := "Serving notifications pull request"
+	notificationsJSON, err := dispatch.MarshalNotificationResponsesJSON(notifications)
+	if err != nil {
+		h.log.WithError(err).Warn(errMsg)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	page := &bytes.Buffer{}
+	encoder := json.NewEncoder(page)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(pullPage{Notifications: notificationsJSON, Cursor: next.String(), Truncated: truncated}); err != nil {
+		h.log.WithError(err).Warn(errMsg)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if _, err = w.Write(page.Bytes()); err != nil {
+		h.log.WithError(err).Warn(errMsg)
+	}
+}
+
+func parsePullQuery(r *http.Request) (pullQuery, error) {
+	values := r.URL.Query()
+	q := pullQuery{limit: defaultPullLimit}
+
+	var err error
+	if q.cursor, err = dispatch.ParseCursor(values.Get("cursor")); err != nil {
+		return q, fmt.Errorf("invalid cursor parameter: %w", err)
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.limit, err = strconv.Atoi(limit); err != nil || q.limit < 1 || q.limit > maxPullLimit {
+			return q, fmt.Errorf("invalid limit parameter: expected a number between 1 and %d", maxPullLimit)
+		}
+	}
+	if wait := values.Get("wait"); wait != "" {
+		if q.wait, err = time.ParseDuration(wait); err != nil {
+			return q, fmt.Errorf("invalid wait parameter: %w", err)
+		}
+		if q.wait < 0 || q.wait > maxPullWait {
+			return q, fmt.Errorf("invalid wait parameter: expected at most %v", maxPullWait)
+		}
+	}
+	q.monitor, _ = strconv.ParseBool(values.Get("monitor"))
+	return q, nil
+}
@@ -0,0 +1,178 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+type pullResponse struct {
+	Notifications []dispatch.NotificationResponse `json:"notifications"`
+	Cursor        string                          `json:"cursor"`
+	Truncated     bool                            `json:"truncated"`
+}
+
+func (s *streamTestHandler) pull(t *testing.T, query string) (int, pullResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/content/notifications?"+query, nil)
+	req.Header.Set(apiKeyHeaderField, streamAPIKey)
+	w := httptest.NewRecorder()
+	s.handler.HandlePull(w, req)
+
+	page := pullResponse{}
+	if w.Code == http.StatusOK {
+		assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-type"))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	}
+	return w.Code, page
+}
+
+// sendAndWait dispatches the notification and waits until it is added to the history
+func (s *streamTestHandler) sendAndWait(t *testing.T, id string, subType string) {
+	t.Helper()
+	updated := s.dispatcher.HistoryUpdated()
+	s.dispatcher.Send(dispatch.NotificationModel{
+		APIURL:           "http://api.ft.com/content/" + id,
+		ID:               "http://www.ft.com/thing/" + id,
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_pull",
+		SubscriptionType: subType,
+	})
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("notification not added to the history")
+	}
+}
+
+func TestPull(t *testing.T) {
+	t.Parallel()
+
+	h := newStreamTestHandler(t)
+	h.sendAndWait(t, "7998974a-1e97-11e6-b286-cddde55ca122", dispatch.ArticleContentType)
+	h.sendAndWait(t, "a4a2b2e6-6ba3-4b1d-9c52-2d7d3a5f5a7e", dispatch.AudioContentType)
+	h.sendAndWait(t, "d6c4b2a0-4d0e-4b5a-8d43-4a1e2a3c2d11", dispatch.ArticleContentType)
+
+	code, page := h.pull(t, "limit=1")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Notifications, 1)
+	assert.Equal(t, "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122", page.Notifications[0].ID)
+	assert.Empty(t, page.Notifications[0].PublishReference, "the monitoring fields are only returned to the monitors")
+
+	code, page = h.pull(t, "cursor="+page.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Notifications, 1, "the notifications of the other types are skipped")
+	assert.Equal(t, "http://www.ft.com/thing/d6c4b2a0-4d0e-4b5a-8d43-4a1e2a3c2d11", page.Notifications[0].ID)
+
+	code, last := h.pull(t, "cursor="+page.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, last.Notifications)
+	assert.Equal(t, page.Cursor, last.Cursor)
+
+	code, page = h.pull(t, "type=Audio&monitor=true")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Notifications, 1)
+	assert.Equal(t, "http://www.ft.com/thing/a4a2b2e6-6ba3-4b1d-9c52-2d7d3a5f5a7e", page.Notifications[0].ID)
+	assert.Equal(t, "tid_pull", page.Notifications[0].PublishReference)
+}
+
+func TestPullWait(t *testing.T) {
+	t.Parallel()
+
+	h := newStreamTestHandler(t)
+	code, page := h.pull(t, "wait=50ms")
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, page.Notifications)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		// a notification of another type does not end the wait
+		updated := h.dispatcher.HistoryUpdated()
+		h.dispatcher.Send(dispatch.NotificationModel{
+			ID:               "http://www.ft.com/thing/a4a2b2e6-6ba3-4b1d-9c52-2d7d3a5f5a7e",
+			Type:             dispatch.ContentUpdateType,
+			SubscriptionType: dispatch.AudioContentType,
+		})
+		<-updated
+		time.Sleep(50 * time.Millisecond)
+		h.dispatcher.Send(dispatch.NotificationModel{
+			ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+			Type:             dispatch.ContentUpdateType,
+			SubscriptionType: dispatch.ArticleContentType,
+		})
+	}()
+	start := time.Now()
+	code, page = h.pull(t, "wait=10s&cursor="+page.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, page.Notifications, 1)
+	assert.Equal(t, "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122", page.Notifications[0].ID)
+}
+
+func TestPullTruncated(t *testing.T) {
+	t.Parallel()
+
+	h := newStreamTestHandler(t)
+	h.sendAndWait(t, "7998974a-1e97-11e6-b286-cddde55ca122", dispatch.ArticleContentType)
+	code, page := h.pull(t, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Notifications, 1)
+	assert.False(t, page.Truncated)
+
+	// the history keeps 10 notifications, the first two following the cursor drop out of it
+	for i := 0; i < 12; i++ {
+		h.sendAndWait(t, fmt.Sprintf("a4a2b2e6-6ba3-4b1d-9c52-2d7d3a5f5a%02d", i), dispatch.ArticleContentType)
+	}
+
+	start := time.Now()
+	code, truncated := h.pull(t, "wait=10s&limit=1&cursor="+page.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, truncated.Truncated, "The page should tell that notifications were lost")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, truncated.Notifications, 1)
+	assert.Equal(t, "http://www.ft.com/thing/a4a2b2e6-6ba3-4b1d-9c52-2d7d3a5f5a02", truncated.Notifications[0].ID,
+		"The page should continue from the oldest retained notification")
+
+	code, page = h.pull(t, "cursor="+truncated.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Notifications, 9)
+	assert.False(t, page.Truncated)
+}
+
+func TestPullInvalidRequest(t *testing.T) {
+	t.Parallel()
+
+	h := newStreamTestHandler(t)
+	tests := map[string]struct {
+		query string
+		code  int
+	}{
+		"invalid cursor": {query: "cursor=not-a-cursor", code: http.StatusBadRequest},
+		"invalid limit":  {query: "limit=0", code: http.StatusBadRequest},
+		"limit too high": {query: "limit=501", code: http.StatusBadRequest},
+		"invalid wait":   {query: "wait=soon", code: http.StatusBadRequest},
+		"wait too long":  {query: "wait=1m", code: http.StatusBadRequest},
+		"invalid type":   {query: "type=Page", code: http.StatusBadRequest},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			code, _ := h.pull(t, test.query)
+			assert.Equal(t, test.code, code)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/content/notifications", nil)
+	req.Header.Set(apiKeyHeaderField, "some-other-api-key")
+	w := httptest.NewRecorder()
+	h.handler.HandlePull(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
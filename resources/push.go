@@ -13,6 +13,11 @@ import (
 	logger "github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/access"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
+	"github.com/Financial-Times/notifications-push/v5/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -22,6 +27,9 @@ const (
 	ClientAdrKey      = "X-Forwarded-For"
 )
 
+// errShutdown cancels the subscriber connections when the server shuts down
+var errShutdown = errors.New("server shutting down")
+
 type keyProcessor interface {
 	Validate(ctx context.Context, key string) error
 }
@@ -33,6 +41,8 @@ type policyProcessor interface {
 type notifier interface {
 	Subscribe(address string, subTypes []string, monitoring bool, options *access.NotificationSubscriptionOptions) (dispatch.Subscriber, error)
 	Unsubscribe(subscriber dispatch.Subscriber)
+	Pull(cursor dispatch.Cursor, subTypes []string, monitor bool, options *access.NotificationSubscriptionOptions, limit int) ([]dispatch.NotificationResponse, dispatch.Cursor, bool)
+	HistoryUpdated() <-chan struct{}
 }
 
 type onShutdown interface {
@@ -45,6 +55,11 @@ type SubHandler struct {
 	keyProcessor              keyProcessor
 	policyProcessor           policyProcessor
 	shutdown                  onShutdown
+	taps                      *StreamTaps
+	maintenance               *Maintenance
+	streams                   *Streams
+	sessions                  *sessions.Recorder
+	metrics                   *metrics.Metrics
 	heartbeatPeriod           time.Duration
 	log                       *logger.UPPLogger
 	contentTypesIncludedInAll []string
@@ -56,6 +71,11 @@ func NewSubHandler(n notifier,
 	keyProcessor keyProcessor,
 	policyProcessor policyProcessor,
 	shutdown onShutdown,
+	taps *StreamTaps,
+	maintenance *Maintenance,
+	streams *Streams,
+	sessionRecorder *sessions.Recorder,
+	m *metrics.Metrics,
 	heartbeatPeriod time.Duration,
 	log *logger.UPPLogger,
 	contentTypesIncludedInAll []string,
@@ -67,6 +87,11 @@ func NewSubHandler(n notifier,
 		keyProcessor:              keyProcessor,
 		policyProcessor:           policyProcessor,
 		shutdown:                  shutdown,
+		taps:                      taps,
+		maintenance:               maintenance,
+		streams:                   streams,
+		sessions:                  sessionRecorder,
+		metrics:                   m,
 		heartbeatPeriod:           heartbeatPeriod,
 		log:                       log,
 		contentTypesIncludedInAll: contentTypesIncludedInAll,
@@ -82,102 +107,202 @@ func (h *SubHandler) HandleSubscription(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
 
+	s, apiKey, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.unsubscribe(s)
+
+	ctx, st, done := h.openStream(r.Context(), s, apiKey)
+	done(h.listenForNotifications(ctx, s, st, w))
+}
+
+// subscribe validates the API key of the subscription request, resolves its options and types and subscribes it to the dispatcher.
+// The request is answered with an error when it cannot be subscribed.
+func (h *SubHandler) subscribe(w http.ResponseWriter, r *http.Request) (dispatch.Subscriber, string, bool) {
 	apiKey := getAPIKey(r)
-	err := h.keyProcessor.Validate(r.Context(), apiKey)
+	isMonitor, _ := strconv.ParseBool(r.URL.Query().Get("monitor"))
+	s, subErr := h.register(r.Context(), apiKey, r.URL.Query()["type"], isMonitor, getClientAddr(r))
+	if subErr != nil {
+		subErr.write(w)
+		return nil, "", false
+	}
+	return s, apiKey, true
+}
+
+// unsubscribe removes the subscriber from the dispatcher, then ends the taps opened on it
+func (h *SubHandler) unsubscribe(s dispatch.Subscriber) {
+	h.notif.Unsubscribe(s)
+	h.taps.disconnected(s.ID())
+}
+
+// subscriptionErr tells why a subscription was refused, with the HTTP status answering it
+type subscriptionErr struct {
+	msg        string
+	status     int
+	retryAfter string
+}
+
+func (e *subscriptionErr) Error() string {
+	return e.msg
+}
+
+func (e *subscriptionErr) write(w http.ResponseWriter) {
+	if e.retryAfter != "" {
+		w.Header().Set("Retry-After", e.retryAfter)
+	}
+	http.Error(w, e.msg, e.status)
+}
+
+// register subscribes a subscriber to the dispatcher, whatever its transport, once its subscription is authorized
+func (h *SubHandler) register(ctx context.Context, apiKey string, subTypes []string, isMonitor bool, address string) (dispatch.Subscriber, *subscriptionErr) {
+	subscriptionOptions, subscriptionParams, subErr := h.authorize(ctx, apiKey, subTypes)
+	if subErr != nil {
+		return nil, subErr
+	}
+
+	s, err := h.notif.Subscribe(address, subscriptionParams, isMonitor, subscriptionOptions)
+	if err != nil {
+		h.log.WithError(err).Error("Error creating subscription")
+		return nil, &subscriptionErr{msg: err.Error(), status: http.StatusInternalServerError}
+	}
+	return s, nil
+}
+
+// authorize validates the API key of a subscription and resolves its options and subscription types.
+// The default subscription type is used when no type is requested.
+func (h *SubHandler) authorize(ctx context.Context, apiKey string, subTypes []string) (*access.NotificationSubscriptionOptions, []string, *subscriptionErr) {
+	if inMaintenance, _, retryAfter := h.maintenance.Status(); inMaintenance {
+		return nil, nil, &subscriptionErr{
+			msg:        "Service under maintenance, new subscriptions are not accepted.",
+			status:     http.StatusServiceUnavailable,
+			retryAfter: strconv.Itoa(int(retryAfter.Seconds())),
+		}
+	}
+
+	err := h.keyProcessor.Validate(ctx, apiKey)
 	if err != nil {
 		keyErr := &access.KeyErr{}
 		if !errors.As(err, &keyErr) {
-			http.Error(w, "Cannot stream.", http.StatusInternalServerError)
-			return
+			return nil, nil, &subscriptionErr{msg: "Cannot stream.", status: http.StatusInternalServerError}
 		}
-		http.Error(w, keyErr.Msg, keyErr.Status)
-		return
+		return nil, nil, &subscriptionErr{msg: keyErr.Msg, status: keyErr.Status}
 	}
 
-	subscriptionOptions, err := h.policyProcessor.GetNotificationSubscriptionOptions(r.Context(), apiKey)
+	subscriptionOptions, err := h.policyProcessor.GetNotificationSubscriptionOptions(ctx, apiKey)
 	if err != nil {
 		logEntry := h.log.WithError(err)
 
 		policyErr := &access.PolicyErr{}
-		if errors.As(err, &policyErr) {
-			if policyErr.KeySuffix != "" {
-				logEntry = logEntry.WithField("apiKeyLastChars", policyErr.KeySuffix)
-			}
-			if policyErr.Description != "" {
-				logEntry = logEntry.WithField("description", policyErr.Description)
-			}
-
-			http.Error(w, policyErr.Msg, policyErr.Status)
-		} else {
-			http.Error(w, "Extracting subscription options based on API Key X-Policies failed", http.StatusInternalServerError)
-
-			return
+		if !errors.As(err, &policyErr) {
+			return nil, nil, &subscriptionErr{msg: "Extracting subscription options based on API Key X-Policies failed", status: http.StatusInternalServerError}
+		}
+		if policyErr.KeySuffix != "" {
+			logEntry = logEntry.WithField("apiKeyLastChars", policyErr.KeySuffix)
+		}
+		if policyErr.Description != "" {
+			logEntry = logEntry.WithField("description", policyErr.Description)
 		}
-
 		logEntry.Error("Extracting subscription options based on API Key X-Policies failed")
 
-		return
+		return nil, nil, &subscriptionErr{msg: policyErr.Msg, status: policyErr.Status}
 	}
 
-	subscriptionParams, err := resolveSubType(r, h.contentTypesIncludedInAll, h.contentTypesSupported, h.defaultSubscriptionType)
-	if err != nil {
-		h.log.WithError(err).Error("Invalid content type")
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	subscriptionParams := []string{h.defaultSubscriptionType}
+	if len(subTypes) > 0 {
+		subscriptionParams, err = resolveTypes(subTypes, h.contentTypesIncludedInAll, h.contentTypesSupported)
+		if err != nil {
+			h.log.WithError(err).Error("Invalid content type")
+			return nil, nil, &subscriptionErr{msg: err.Error(), status: http.StatusBadRequest}
+		}
 	}
-	monitorParam := r.URL.Query().Get("monitor")
-	isMonitor, _ := strconv.ParseBool(monitorParam)
 
-	s, err := h.notif.Subscribe(getClientAddr(r), subscriptionParams, isMonitor, subscriptionOptions)
-	if err != nil {
-		h.log.WithError(err).Error("Error creating subscription")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	return subscriptionOptions, subscriptionParams, nil
+}
+
+// openStream registers the connection of the subscriber, so it can be closed on shutdown or by the admins.
+// The returned function unregisters it and records the session with the cause of the disconnection.
+func (h *SubHandler) openStream(parent context.Context, s dispatch.Subscriber, apiKey string) (context.Context, *stream, func(cause string, reason string)) {
+	ctx, cancel := context.WithCancelCause(parent)
+	h.shutdown.RegisterOnShutdown(func() { cancel(errShutdown) })
+	st := h.streams.add(s, keyFingerprint(apiKey), func() { cancel(nil) })
+	return ctx, st, func(cause string, reason string) {
+		cancel(nil)
+		h.streams.remove(st)
+		h.recordSession(s, keyFingerprint(apiKey), cause, reason)
 	}
-	defer h.notif.Unsubscribe(s)
+}
 
-	ctx, cancel := context.WithCancel(r.Context())
-	h.shutdown.RegisterOnShutdown(cancel)
-	h.listenForNotifications(ctx, s, w)
+func (h *SubHandler) recordSession(s dispatch.Subscriber, keyFingerprint string, cause string, reason string) {
+	counters := s.Counters().Snapshot()
+	_, isMonitor := s.(*dispatch.MonitorSubscriber)
+	h.sessions.Record(sessions.Record{
+		SubscriberID:      s.ID(),
+		KeyFingerprint:    keyFingerprint,
+		Address:           s.Address(),
+		SubscriptionTypes: s.SubTypes(),
+		Monitor:           isMonitor,
+		ConnectedAt:       s.Since(),
+		DisconnectedAt:    time.Now(),
+		NotificationsSent: counters.Sent,
+		BytesSent:         counters.BytesWritten,
+		Dropped:           counters.Lagging,
+		Failed:            counters.Failed,
+		Cause:             cause,
+		Reason:            reason,
+	})
 }
 
 // listenForNotifications starts listening on the subscribes channel for notifications
-func (h *SubHandler) listenForNotifications(ctx context.Context, s dispatch.Subscriber, w http.ResponseWriter) {
+// The stream, when registered, holds the rate cap of the subscriber and the reason it was closed by the admins.
+// It returns the cause of the disconnection, with the write error or the reason given by the admins.
+func (h *SubHandler) listenForNotifications(ctx context.Context, s dispatch.Subscriber, st *stream, w http.ResponseWriter) (string, string) {
 	bw := bufio.NewWriter(w)
 	timer := time.NewTimer(h.heartbeatPeriod)
 	logEntry := h.log.WithField("subscriberId", s.ID()).WithField("subscriber", s.Address())
 
 	write := func(notification string) error {
-		_, err := bw.WriteString("data: " + notification + "\n\n")
-		if err != nil {
-			return err
+		start := time.Now()
+		n, err := bw.WriteString("data: " + notification + "\n\n")
+		if err == nil {
+			err = bw.Flush()
 		}
-
-		err = bw.Flush()
 		if err != nil {
+			h.taps.publish(s.ID(), tapEvent{Event: tapError, Data: notification, Error: err.Error()})
 			return err
 		}
 
 		flusher := w.(http.Flusher)
 		flusher.Flush()
+		heartbeat := notification == HeartbeatMsg
+		frame := metrics.FrameNotification
+		if heartbeat {
+			frame = metrics.FrameHeartbeat
+		}
+		h.metrics.FrameWritten(frame, time.Since(start))
+		s.Counters().RecordWrite(n, heartbeat)
+		h.taps.publish(s.ID(), tapEvent{Event: tapFrame, Data: notification})
 		return nil
 	}
 	//first thing we write is a heartbeat
 	err := write(HeartbeatMsg)
 	if err != nil {
 		logEntry.WithError(err).Error("Sending heartbeat to subscriber has failed ")
-		return
+		return sessions.CauseWriteError, err.Error()
 	}
 
 	logEntry.Info("Heartbeat sent to subscriber successfully")
+	var lastSent time.Time
 	for {
+		notifications, throttled := st.next(s, lastSent)
 		select {
-		case notification := <-s.Notifications():
-			err := write(notification)
+		case notification := <-notifications:
+			err := writeTraced(s, notification, write)
 			if err != nil {
 				logEntry.WithError(err).Error("Error while sending notification to subscriber")
-				return
+				return sessions.CauseWriteError, err.Error()
 			}
+			lastSent = time.Now()
 			if !timer.Stop() {
 				<-timer.C
 			}
@@ -186,19 +311,52 @@ func (h *SubHandler) listenForNotifications(ctx context.Context, s dispatch.Subs
 			err := write(HeartbeatMsg)
 			if err != nil {
 				logEntry.WithError(err).Error("Sending heartbeat to subscriber has failed ")
-				return
+				return sessions.CauseWriteError, err.Error()
 			}
 
 			timer.Reset(h.heartbeatPeriod)
 
 			logEntry.Info("Heartbeat sent to subscriber successfully")
+		case <-throttled:
 		case <-ctx.Done():
-			logEntry.Info("Notification subscriber disconnected remotely")
-			return
+			return disconnected(ctx, logEntry, st)
 		}
 	}
 }
 
+// writeTraced writes the notification within a span child of its fan-out, when the notification is traced
+func writeTraced(s dispatch.Subscriber, notification string, write func(string) error) error {
+	traced, ok := s.(dispatch.TracedSubscriber)
+	if !ok {
+		return write(notification)
+	}
+	_, span := tracing.Tracer().Start(tracing.ContextWith(traced.TakeSpanContext(notification)), "WriteNotification",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.SubscriberIDKey.String(s.ID())))
+	defer span.End()
+
+	err := write(notification)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// disconnected logs the end of the subscriber connection and returns its cause with the reason given by the admins
+func disconnected(ctx context.Context, logEntry *logger.LogEntry, st *stream) (string, string) {
+	if reason := st.closeReason(); reason != "" {
+		logEntry.WithField("reason", reason).Info("Notification subscriber disconnected by admin")
+		return sessions.CauseAdmin, reason
+	}
+	if errors.Is(context.Cause(ctx), errShutdown) {
+		logEntry.Info("Notification subscriber disconnected on shutdown")
+		return sessions.CauseShutdown, ""
+	}
+	logEntry.Info("Notification subscriber disconnected remotely")
+	return sessions.CauseClient, ""
+}
+
 func getClientAddr(r *http.Request) string {
 	xForwardedFor := r.Header.Get(ClientAdrKey)
 	if xForwardedFor != "" {
@@ -208,15 +366,11 @@ func getClientAddr(r *http.Request) string {
 	return r.RemoteAddr
 }
 
-func resolveSubType(r *http.Request, contentTypesIncludedInAll []string, contentTypeSupported []string, defaultSubscriptionType string) ([]string, error) {
+// resolveTypes matches the types requested by the subscriber with the supported ones, All being expanded to the types it includes
+func resolveTypes(subTypes []string, contentTypesIncludedInAll []string, contentTypeSupported []string) ([]string, error) {
 	retVal := make([]string, 0)
-
-	values := r.URL.Query()
-	subTypes := values["type"]
-	if len(subTypes) == 0 {
-		return []string{defaultSubscriptionType}, nil
-	}
-	// subTypes are being send by the client (subscriber), and needs to be matched with such string value
+	// subTypes are being send by the client (subscriber), and are matched case insensitively with the supported types.
+	// The supported type is kept, so the subscriptions and their metric labels do not depend on the casing of the client.
 	for _, subType := range subTypes {
 		if strings.EqualFold(subType, dispatch.AllContentType) {
 			retVal = append(retVal, contentTypesIncludedInAll...)
@@ -224,14 +378,14 @@ func resolveSubType(r *http.Request, contentTypesIncludedInAll []string, content
 		}
 		for _, supportedType := range contentTypeSupported {
 			if strings.EqualFold(subType, supportedType) {
-				retVal = append(retVal, subType)
+				retVal = append(retVal, supportedType)
 				break
 			}
 		}
 	}
 
 	if len(retVal) == 0 {
-		return nil, fmt.Errorf("specified type (%s) is unsupported", r.URL.Query().Get("type"))
+		return nil, fmt.Errorf("specified type (%s) is unsupported", subTypes[0])
 	}
 
 	return retVal, nil
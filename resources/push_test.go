@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -12,11 +13,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	logger "github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/access"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
 	"github.com/Financial-Times/notifications-push/v5/mocks"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
 )
 
 func TestSubscription(t *testing.T) {
@@ -79,6 +82,16 @@ func TestSubscription(t *testing.T) {
 				ReceiveAdvancedNotifications: false,
 			},
 		},
+		"Test Push uppercase Subscriber": {
+			ExpectedType:   []string{"Audio"},
+			Request:        "/content/notifications-push?type=AUDIO",
+			ExpectedBody:   "data: []\n\n",
+			ExpectedStatus: http.StatusOK,
+			ExpectStream:   true,
+			SubscriptionOptions: &access.NotificationSubscriptionOptions{
+				ReceiveAdvancedNotifications: false,
+			},
+		},
 		"Test Push Monitor Subscriber": {
 			ExpectedType:   []string{"Article"},
 			Request:        "/content/notifications-push?monitor=true",
@@ -165,11 +178,11 @@ func TestSubscription(t *testing.T) {
 			r := mocks.NewShutdownReg()
 			r.On("RegisterOnShutdown", mock.Anything).Return()
 			defer r.Shutdown()
-			handler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+			handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 				[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
-			listHandler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{}, []string{}, "List")
-			pageHandler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{}, []string{}, "Page")
+			listHandler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{}, []string{}, "List")
+			pageHandler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{}, []string{}, "Page")
 
 			ctx, cancel := context.WithCancel(context.Background())
 
@@ -261,7 +274,7 @@ func TestPassKeyAsParameter(t *testing.T) {
 	r.On("RegisterOnShutdown", mock.Anything).Return()
 	defer r.Shutdown()
 
-	handler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+	handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 		[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
 	handler.HandleSubscription(resp, req)
@@ -294,7 +307,7 @@ func TestInvalidKey(t *testing.T) {
 	d := &mocks.Dispatcher{}
 	r := mocks.NewShutdownReg()
 
-	handler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+	handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 		[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
 	resp := httptest.NewRecorder()
@@ -310,6 +323,34 @@ func TestInvalidKey(t *testing.T) {
 	kp.AssertExpectations(t)
 }
 
+func TestMaintenanceRejectsSubscription(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("TEST", "PANIC")
+
+	kp := &mocks.KeyProcessor{}
+	pp := &mocks.PolicyProcessor{}
+	d := &mocks.Dispatcher{}
+	r := mocks.NewShutdownReg()
+
+	maintenance := NewMaintenance()
+	maintenance.Enable(2 * time.Minute)
+
+	handler := NewSubHandler(d, kp, pp, r, nil, maintenance, nil, nil, nil, time.Second, l, []string{"Article", "ContentPackage", "Audio"},
+		[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/content/notifications-push", nil)
+	req.Header.Set(apiKeyHeaderField, "some-test-api-key")
+
+	handler.HandleSubscription(resp, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, "120", resp.Header().Get("Retry-After"))
+	kp.AssertNotCalled(t, "Validate", mock.Anything, mock.Anything)
+	d.AssertNotCalled(t, "Subscribe", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestHeartbeat(t *testing.T) {
 	t.Parallel()
 
@@ -342,7 +383,7 @@ func TestHeartbeat(t *testing.T) {
 	r.On("RegisterOnShutdown", mock.Anything).Return()
 	defer r.Shutdown()
 
-	handler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+	handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 		[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
 	req, _ := http.NewRequest(http.MethodGet, "/content/notifications-push", nil)
@@ -427,7 +468,7 @@ func TestPushNotificationDelay(t *testing.T) {
 	r.On("RegisterOnShutdown", mock.Anything).Return()
 	defer r.Shutdown()
 
-	handler := NewSubHandler(d, kp, pp, r, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
+	handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, nil, nil, heartbeat, l, []string{"Article", "ContentPackage", "Audio"},
 		[]string{"Annotations", "Article", "ContentPackage", "Audio", "All", "LiveBlogPackage", "LiveBlogPost", "Content"}, "Article")
 
 	req, _ := http.NewRequest(http.MethodGet, "/content/notifications-push", nil)
@@ -522,3 +563,74 @@ func (p *pipedResponse) readString() (string, error) {
 	}
 	return string(buf[:idx]), nil
 }
+
+func TestSessionRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		disconnect    func(cancel context.CancelFunc, r *mocks.ShutdownReg)
+		expectedCause string
+	}{
+		"client disconnects": {
+			disconnect:    func(cancel context.CancelFunc, _ *mocks.ShutdownReg) { cancel() },
+			expectedCause: sessions.CauseClient,
+		},
+		"server shuts down": {
+			disconnect:    func(_ context.CancelFunc, r *mocks.ShutdownReg) { r.Shutdown() },
+			expectedCause: sessions.CauseShutdown,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := logger.NewUPPLogger("TEST", "PANIC")
+			out := &bytes.Buffer{}
+			sessionLog := logger.NewUPPInfoLogger("TEST")
+			sessionLog.SetOutput(out)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			apiKey := "some-test-api-key"
+			req := httptest.NewRequest(http.MethodGet, "/content/notifications-push?monitor=true", nil).WithContext(ctx)
+			req.Header.Set(apiKeyHeaderField, apiKey)
+
+			kp := &mocks.KeyProcessor{}
+			kp.On("Validate", mock.Anything, apiKey).Return(nil)
+			pp := &mocks.PolicyProcessor{}
+			pp.On("GetNotificationSubscriptionOptions", mock.Anything, apiKey).Return(&access.NotificationSubscriptionOptions{}, nil)
+
+			r := mocks.NewShutdownReg()
+			r.On("RegisterOnShutdown", mock.Anything).Return()
+
+			sub, _ := dispatch.NewMonitorSubscriber(req.RemoteAddr, []string{"Article"}, &access.NotificationSubscriptionOptions{})
+			d := &mocks.Dispatcher{}
+			d.On("Subscribe", req.RemoteAddr, []string{"Article"}, true, &access.NotificationSubscriptionOptions{}).Run(func(_ mock.Arguments) {
+				go func() {
+					<-time.After(time.Millisecond * 10)
+					test.disconnect(cancel, r)
+				}()
+			}).Return(sub)
+			d.On("Unsubscribe", sub).Return()
+
+			handler := NewSubHandler(d, kp, pp, r, nil, nil, nil, sessions.NewRecorder(sessionLog), nil, time.Minute, l, []string{"Article"},
+				[]string{"Article"}, "Article")
+			handler.HandleSubscription(httptest.NewRecorder(), req)
+
+			record := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+			assert.Equal(t, "session", record["logType"])
+			assert.Equal(t, sub.ID(), record["subscriberId"])
+			assert.Equal(t, keyFingerprint(apiKey), record["keyFingerprint"])
+			assert.Equal(t, req.RemoteAddr, record["address"])
+			assert.Equal(t, []interface{}{"Article"}, record["subscriptionTypes"])
+			assert.Equal(t, true, record["monitor"])
+			assert.Equal(t, float64(len("data: []\n\n")), record["bytesSent"])
+			assert.Equal(t, float64(0), record["notificationsSent"])
+			assert.Equal(t, test.expectedCause, record["cause"])
+			assert.Greater(t, record["durationSeconds"], float64(0))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+type redispatchRequest struct {
+	UUID          string    `json:"uuid"`
+	TransactionID string    `json:"tid"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	SubscriberID  string    `json:"subscriberId"`
+	Group         string    `json:"group"`
+	SkipDelay     bool      `json:"skipDelay"`
+}
+
+type redispatchResponse struct {
+	Redispatched   int      `json:"redispatched"`
+	TransactionIDs []string `json:"transactionIds"`
+}
+
+func (q redispatchRequest) filter() (dispatch.HistoryFilter, error) {
+	if q.UUID == "" && q.TransactionID == "" && q.From.IsZero() && q.To.IsZero() {
+		return dispatch.HistoryFilter{}, errors.New("at least one of uuid, tid, from or to is required")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return dispatch.HistoryFilter{}, errors.New("from must not be after to")
+	}
+	return dispatch.HistoryFilter{
+		ContentUUID:   q.UUID,
+		TransactionID: q.TransactionID,
+		From:          q.From,
+		To:            q.To,
+	}, nil
+}
+
+// Redispatch sends the notifications selected from the history through the dispatcher again.
+// They can be targeted to a single subscriber or subscriber group and can skip the delay.
+func (h *AdminHandler) Redispatch(w http.ResponseWriter, r *http.Request) {
+	q := redispatchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, "Invalid re-dispatch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter, err := q.filter()
+	if err != nil {
+		http.Error(w, "Invalid re-dispatch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, err := dispatch.NewTarget(q.SubscriberID, q.Group)
+	if err != nil {
+		http.Error(w, "Invalid re-dispatch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.SubscriberID != "" && !h.isSubscribed(q.SubscriberID) {
+		http.Error(w, "No subscriber found with id "+q.SubscriberID, http.StatusNotFound)
+		return
+	}
+
+	// the history lists the most recent notifications first, they are sent again in the order they were received
+	matching := filter.Apply(h.history.Notifications())
+	notifications := make([]dispatch.NotificationModel, 0, len(matching))
+	resp := redispatchResponse{TransactionIDs: make([]string, 0, len(matching))}
+	for i := len(matching) - 1; i >= 0; i-- {
+		notifications = append(notifications, matching[i])
+		resp.TransactionIDs = append(resp.TransactionIDs, matching[i].PublishReference)
+	}
+	resp.Redispatched = len(notifications)
+
+	h.log.WithFields(map[string]interface{}{
+		"address":        getClientAddr(r),
+		"uuid":           q.UUID,
+		"tid":            q.TransactionID,
+		"from":           q.From,
+		"to":             q.To,
+		"subscriberId":   q.SubscriberID,
+		"group":          q.Group,
+		"skipDelay":      q.SkipDelay,
+		"redispatched":   resp.Redispatched,
+		"transactionIds": resp.TransactionIDs,
+	}).Info("Admin re-dispatch of notifications from history")
+
+	if len(notifications) > 0 {
+		h.dispatcher.Redispatch(notifications, target, q.SkipDelay)
+	}
+
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithError(err).Warn("Error in marshalling re-dispatch response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if _, err = w.Write(bytes); err != nil {
+		h.log.WithError(err).Warn("Error writing re-dispatch response")
+	}
+}
@@ -0,0 +1,115 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+func TestRedispatch(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	history := dispatch.NewHistory(10, dispatch.OrderByArrival)
+	first := dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		PublishReference: "tid_1",
+		NotificationDate: "2024-07-31T10:00:00.000Z",
+	}
+	second := dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/3cc23068-e501-11e9-9743-db5a370481bc",
+		PublishReference: "tid_2",
+		NotificationDate: "2024-07-31T11:00:00.000Z",
+	}
+	history.Push(first)
+	history.Push(second)
+
+	subscriber, err := dispatch.NewMonitorSubscriber("192.168.1.2", []string{dispatch.ArticleContentType}, nil)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		body                  string
+		expectedStatus        int
+		expectedNotifications []dispatch.NotificationModel
+		expectedTarget        *dispatch.Target
+		expectedSkipDelay     bool
+	}{
+		"time range in arrival order": {
+			body:                  `{"from":"2024-07-31T09:00:00Z","skipDelay":true}`,
+			expectedStatus:        http.StatusAccepted,
+			expectedNotifications: []dispatch.NotificationModel{first, second},
+			expectedSkipDelay:     true,
+		},
+		"single subscriber": {
+			body:                  `{"tid":"tid_2","subscriberId":"` + subscriber.ID() + `"}`,
+			expectedStatus:        http.StatusAccepted,
+			expectedNotifications: []dispatch.NotificationModel{second},
+			expectedTarget:        &dispatch.Target{SubscriberID: subscriber.ID()},
+		},
+		"subscriber group": {
+			body:                  `{"uuid":"7998974a-1e97-11e6-b286-cddde55ca122","group":"monitor"}`,
+			expectedStatus:        http.StatusAccepted,
+			expectedNotifications: []dispatch.NotificationModel{first},
+			expectedTarget:        &dispatch.Target{Group: dispatch.MonitorGroup},
+		},
+		"nothing matching": {
+			body:           `{"tid":"tid_unknown"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		"no selection": {
+			body:           `{"group":"monitor"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid group": {
+			body:           `{"tid":"tid_1","group":"internal"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid body": {
+			body:           `{"tid":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"unknown subscriber": {
+			body:           `{"tid":"tid_1","subscriberId":"unknown"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &mocks.Dispatcher{}
+			d.On("Subscribers").Return([]dispatch.Subscriber{subscriber}).Maybe()
+			if len(test.expectedNotifications) > 0 {
+				d.On("Redispatch", test.expectedNotifications, test.expectedTarget, test.expectedSkipDelay)
+			}
+			admin := NewAdminHandler("secret", d, history, nil, nil, nil, nil, nil, nil, nil, time.Second, l)
+
+			w := httptest.NewRecorder()
+			admin.Redispatch(w, httptest.NewRequest(http.MethodPost, "/__admin/redispatch", strings.NewReader(test.body)))
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			d.AssertExpectations(t)
+			if test.expectedStatus != http.StatusAccepted {
+				d.AssertNotCalled(t, "Redispatch", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			resp := redispatchResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, len(test.expectedNotifications), resp.Redispatched)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"fmt"
+	"time"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+)
+
+// SaturationThresholds configures the dispatcher saturation check.
+// MaxPending is the number of notifications waiting for their delay or for the dispatcher to be unfrozen,
+// MaxLaggingRatio the share of subscribers with a full buffer, between 0 and 1, above which the check fails.
+type SaturationThresholds struct {
+	MaxPending      int
+	MaxLaggingRatio float64
+}
+
+func (t SaturationThresholds) enabled() bool {
+	return t.MaxPending > 0 || t.MaxLaggingRatio > 0
+}
+
+func (h *HealthCheck) saturationCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "dispatcher-saturation",
+		Name:             "DispatcherSaturation",
+		Severity:         2,
+		BusinessImpact:   "Notifications are delayed, or dropped for the subscribers that cannot keep up.",
+		TechnicalSummary: "Too many notifications are waiting to be forwarded, or too many subscribers have a full buffer. Check whether the dispatcher is frozen, then the subscribers listed in /__stats sorted by bufferOccupancy.",
+		PanicGuide:       panicGuideURL,
+		Checker:          loggingCheck(h.log.WithField("check", "DispatcherSaturation"), h.checkSaturation),
+	}
+}
+
+func (h *HealthCheck) checkSaturation() (string, error) {
+	pending := h.dispatcher.Pending()
+	subscribers := h.dispatcher.Subscribers()
+	lagging := 0
+	for _, s := range subscribers {
+		if len(s.Notifications()) == cap(s.Notifications()) {
+			lagging++
+		}
+	}
+	msg := fmt.Sprintf("%d pending notifications, %d of %d subscribers lagging", pending, lagging, len(subscribers))
+	if frozen, since := h.dispatcher.Frozen(); frozen {
+		msg += fmt.Sprintf(", frozen since %s", since.Format(time.RFC3339))
+	}
+
+	t := h.thresholds.Saturation
+	if t.MaxPending > 0 && pending > t.MaxPending {
+		return "", fmt.Errorf("%s, more than %d pending", msg, t.MaxPending)
+	}
+	if t.MaxLaggingRatio > 0 && len(subscribers) > 0 && float64(lagging) > t.MaxLaggingRatio*float64(len(subscribers)) {
+		return "", fmt.Errorf("%s, more than %v%% lagging", msg, t.MaxLaggingRatio*100)
+	}
+	return msg, nil
+}
@@ -0,0 +1,70 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+func TestSaturationCheck(t *testing.T) {
+	t.Parallel()
+
+	log := logger.NewUPPLogger("test-service", "panic")
+
+	subscribers := make([]dispatch.Subscriber, 0, 4)
+	for i := 0; i < 4; i++ {
+		s, err := dispatch.NewStandardSubscriber("192.168.1.2", []string{dispatch.ArticleContentType}, nil)
+		require.NoError(t, err)
+		subscribers = append(subscribers, s)
+	}
+	lagging := subscribers[0].(dispatch.NotificationConsumer)
+	for len(lagging.Notifications()) < cap(lagging.Notifications()) {
+		require.NoError(t, lagging.Send(dispatch.NotificationResponse{}))
+	}
+	frozenSince := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		status      *stubDispatcherHealth
+		expectedMsg string
+		expectedErr string
+	}{
+		"idle": {
+			status:      &stubDispatcherHealth{},
+			expectedMsg: "0 pending notifications, 0 of 0 subscribers lagging",
+		},
+		"within the thresholds": {
+			status:      &stubDispatcherHealth{pending: 10, subscribers: subscribers},
+			expectedMsg: "10 pending notifications, 1 of 4 subscribers lagging",
+		},
+		"too many pending": {
+			status:      &stubDispatcherHealth{pending: 11, subscribers: subscribers, frozenSince: frozenSince},
+			expectedErr: "11 pending notifications, 1 of 4 subscribers lagging, frozen since 2024-01-01T12:00:00Z, more than 10 pending",
+		},
+		"too many lagging": {
+			status:      &stubDispatcherHealth{subscribers: subscribers[:2]},
+			expectedErr: "0 pending notifications, 1 of 2 subscribers lagging, more than 25% lagging",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			hc := NewHealthCheck(nil, "randomAddress", "", nil, nil, test.status, nil, nil,
+				HealthThresholds{Saturation: SaturationThresholds{MaxPending: 10, MaxLaggingRatio: 0.25}}, "notifications-push", log)
+
+			msg, err := hc.checkSaturation()
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedMsg, msg)
+		})
+	}
+}
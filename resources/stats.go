@@ -2,29 +2,134 @@ package resources
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
 )
 
 type subscriptionStats struct {
-	NrOfSubscribers int                   `json:"nrOfSubscribers"`
-	Subscribers     []dispatch.Subscriber `json:"subscribers"`
+	NrOfSubscribers int               `json:"nrOfSubscribers"`
+	Subscribers     []subscriberStats `json:"subscribers"`
+	Summary         statsSummary      `json:"summary"`
+}
+
+type subscriberStats struct {
+	*dispatch.SubscriberPayload
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+
+	since    time.Time
+	counters dispatch.CountersSnapshot
+}
+
+type ageBucket struct {
+	Bucket string `json:"bucket"`
+	Count  int    `json:"count"`
+}
+
+type statsSummary struct {
+	BySubscriptionType           map[string]int `json:"bySubscriptionType"`
+	Standard                     int            `json:"standard"`
+	Monitor                      int            `json:"monitor"`
+	ReceiveAdvancedNotifications int            `json:"receiveAdvancedNotifications"`
+	ReceiveInternalUnstable      int            `json:"receiveInternalUnstable"`
+	ConnectionAge                []ageBucket    `json:"connectionAge"`
+}
+
+// connectionAgeBuckets are the upper bounds of the connection age histogram, the last bucket holds the older connections
+var connectionAgeBuckets = []struct {
+	label string
+	upTo  time.Duration
+}{
+	{"0-1m", time.Minute},
+	{"1m-10m", 10 * time.Minute},
+	{"10m-1h", time.Hour},
+	{"1h-6h", 6 * time.Hour},
+	{"6h-24h", 24 * time.Hour},
+}
+
+const oldestConnectionsBucket = "24h+"
+
+type statsQuery struct {
+	subTypes       []string
+	monitor        *bool
+	addressPrefix  string
+	keyFingerprint string
+	minAge         time.Duration
+	sortBy         string
+	descending     bool
+	limit          int
+	offset         int
 }
 
 type clientsProvider interface {
 	Subscribers() []dispatch.Subscriber
 }
 
-// Stats returns subscriber stats
-func Stats(provider clientsProvider, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+// statsOrder compares subscribers by the sort fields of the stats
+var statsOrder = map[string]func(a, b subscriberStats) bool{
+	"id":                          func(a, b subscriberStats) bool { return a.ID < b.ID },
+	"address":                     func(a, b subscriberStats) bool { return a.Address < b.Address },
+	"since":                       func(a, b subscriberStats) bool { return a.since.Before(b.since) },
+	"connectionDuration":          func(a, b subscriberStats) bool { return a.since.After(b.since) },
+	"type":                        func(a, b subscriberStats) bool { return a.Type < b.Type },
+	"monitor":                     func(a, b subscriberStats) bool { return !a.Monitor && b.Monitor },
+	"keyFingerprint":              func(a, b subscriberStats) bool { return a.KeyFingerprint < b.KeyFingerprint },
+	"notificationsSent":           func(a, b subscriberStats) bool { return a.counters.Sent < b.counters.Sent },
+	"notificationsSkipped":        func(a, b subscriberStats) bool { return totalSkipped(a) < totalSkipped(b) },
+	"notificationsDroppedLagging": func(a, b subscriberStats) bool { return a.counters.Lagging < b.counters.Lagging },
+	"notificationsFailed":         func(a, b subscriberStats) bool { return a.counters.Failed < b.counters.Failed },
+	"bytesWritten":                func(a, b subscriberStats) bool { return a.counters.BytesWritten < b.counters.BytesWritten },
+	"lastNotification": func(a, b subscriberStats) bool {
+		return a.counters.LastNotification.Before(b.counters.LastNotification)
+	},
+	"lastHeartbeat": func(a, b subscriberStats) bool {
+		return a.counters.LastHeartbeat.Before(b.counters.LastHeartbeat)
+	},
+	"bufferOccupancy": func(a, b subscriberStats) bool { return a.BufferOccupancy < b.BufferOccupancy },
+}
+
+// Stats returns subscriber stats. The subscribers can be filtered, sorted and paged through,
+// the summary aggregates all the subscribers matching the filters.
+func Stats(provider clientsProvider, streams *Streams, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		subscribers := provider.Subscribers()
+		q, err := parseStatsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fingerprints := streams.keyFingerprints()
+		matching := []subscriberStats{}
+		for _, s := range provider.Subscribers() {
+			entry := newSubscriberStats(s, fingerprints[s.ID()])
+			if q.matches(entry) {
+				matching = append(matching, entry)
+			}
+		}
+
+		less := statsOrder[q.sortBy]
+		sort.SliceStable(matching, func(a, b int) bool {
+			if q.descending {
+				return less(matching[b], matching[a])
+			}
+			return less(matching[a], matching[b])
+		})
+
+		page := paginate(matching, q.offset, q.limit)
+		if page == nil {
+			page = []subscriberStats{}
+		}
 
 		stats := subscriptionStats{
-			NrOfSubscribers: len(subscribers),
-			Subscribers:     subscribers,
+			NrOfSubscribers: len(matching),
+			Subscribers:     page,
+			Summary:         summarize(matching),
 		}
 
 		bytes, err := json.Marshal(stats)
@@ -35,6 +140,7 @@ func Stats(provider clientsProvider, log *logger.UPPLogger) func(w http.Response
 		}
 
 		w.Header().Set("Content-type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(matching)))
 		b, err := w.Write(bytes)
 		if b == 0 {
 			log.Warn("Response written to HTTP was empty.")
@@ -45,3 +151,129 @@ func Stats(provider clientsProvider, log *logger.UPPLogger) func(w http.Response
 		}
 	}
 }
+
+func newSubscriberStats(s dispatch.Subscriber, keyFingerprint string) subscriberStats {
+	payload := dispatch.NewSubscriberPayload(s)
+	return subscriberStats{
+		SubscriberPayload: payload,
+		KeyFingerprint:    keyFingerprint,
+		since:             s.Since(),
+		counters:          s.Counters().Snapshot(),
+	}
+}
+
+func totalSkipped(s subscriberStats) uint64 {
+	var total uint64
+	for _, count := range s.counters.Skipped {
+		total += count
+	}
+	return total
+}
+
+func parseStatsQuery(r *http.Request) (statsQuery, error) {
+	values := r.URL.Query()
+	q := statsQuery{
+		subTypes:       values["type"],
+		addressPrefix:  values.Get("address"),
+		keyFingerprint: values.Get("keyFingerprint"),
+		sortBy:         "since",
+	}
+
+	var err error
+	if monitor := values.Get("monitor"); monitor != "" {
+		isMonitor, err := strconv.ParseBool(monitor)
+		if err != nil {
+			return q, fmt.Errorf("invalid monitor parameter: %w", err)
+		}
+		q.monitor = &isMonitor
+	}
+	if minAge := values.Get("minAge"); minAge != "" {
+		if q.minAge, err = time.ParseDuration(minAge); err != nil {
+			return q, fmt.Errorf("invalid minAge parameter: %w", err)
+		}
+	}
+	if sortBy := values.Get("sort"); sortBy != "" {
+		if _, found := statsOrder[sortBy]; !found {
+			return q, fmt.Errorf("invalid sort parameter: %q is not a subscriber field", sortBy)
+		}
+		q.sortBy = sortBy
+	}
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.descending = true
+	default:
+		return q, fmt.Errorf("invalid order parameter: %q is neither asc nor desc", order)
+	}
+	if q.limit, err = parseNonNegativeParam(values.Get("limit")); err != nil {
+		return q, fmt.Errorf("invalid limit parameter: %w", err)
+	}
+	if q.offset, err = parseNonNegativeParam(values.Get("offset")); err != nil {
+		return q, fmt.Errorf("invalid offset parameter: %w", err)
+	}
+	return q, nil
+}
+
+func (q statsQuery) matches(s subscriberStats) bool {
+	if len(q.subTypes) > 0 {
+		found := false
+		for _, subType := range q.subTypes {
+			found = found || containsFold(s.SubscriptionTypes, subType)
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.monitor != nil && s.Monitor != *q.monitor {
+		return false
+	}
+	if q.addressPrefix != "" && !strings.HasPrefix(s.Address, q.addressPrefix) {
+		return false
+	}
+	if q.keyFingerprint != "" && s.KeyFingerprint != q.keyFingerprint {
+		return false
+	}
+	if q.minAge > 0 && time.Since(s.since) < q.minAge {
+		return false
+	}
+	return true
+}
+
+func summarize(subscribers []subscriberStats) statsSummary {
+	summary := statsSummary{
+		BySubscriptionType: map[string]int{},
+		ConnectionAge:      make([]ageBucket, 0, len(connectionAgeBuckets)+1),
+	}
+	for _, b := range connectionAgeBuckets {
+		summary.ConnectionAge = append(summary.ConnectionAge, ageBucket{Bucket: b.label})
+	}
+	summary.ConnectionAge = append(summary.ConnectionAge, ageBucket{Bucket: oldestConnectionsBucket})
+
+	for _, s := range subscribers {
+		for _, subType := range s.SubscriptionTypes {
+			summary.BySubscriptionType[subType]++
+		}
+		if s.Monitor {
+			summary.Monitor++
+		} else {
+			summary.Standard++
+		}
+		if s.Options.ReceiveAdvancedNotifications {
+			summary.ReceiveAdvancedNotifications++
+		}
+		if s.Options.ReceiveInternalUnstable {
+			summary.ReceiveInternalUnstable++
+		}
+
+		age := time.Since(s.since)
+		bucket := len(connectionAgeBuckets)
+		for i, b := range connectionAgeBuckets {
+			if age < b.upTo {
+				bucket = i
+				break
+			}
+		}
+		summary.ConnectionAge[bucket].Count++
+	}
+	return summary
+}
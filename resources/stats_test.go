@@ -1,13 +1,16 @@
 package resources
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
 	"github.com/Financial-Times/notifications-push/v5/dispatch"
 	"github.com/Financial-Times/notifications-push/v5/mocks"
 )
@@ -25,11 +28,91 @@ func TestStats(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	Stats(d, l)(w, req)
+	Stats(d, nil, l)(w, req)
 
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "Should be json")
-	assert.Equal(t, `{"nrOfSubscribers":0,"subscribers":[]}`, w.Body.String(), "Should be empty array")
+	assert.Equal(t, `{"nrOfSubscribers":0,"subscribers":[],"summary":{"bySubscriptionType":{},"standard":0,"monitor":0,`+
+		`"receiveAdvancedNotifications":0,"receiveInternalUnstable":0,"connectionAge":[{"bucket":"0-1m","count":0},`+
+		`{"bucket":"1m-10m","count":0},{"bucket":"10m-1h","count":0},{"bucket":"1h-6h","count":0},{"bucket":"6h-24h","count":0},`+
+		`{"bucket":"24h+","count":0}]}}`, w.Body.String(), "Should be empty array")
 	assert.Equal(t, 200, w.Code, "Should be OK")
 
 	d.AssertExpectations(t)
 }
+
+func TestStatsQuery(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, nil, nil, l)
+
+	article, err := d.Subscribe("192.168.1.2:5000", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+	audio, err := d.Subscribe("192.168.1.3:5000", []string{dispatch.AudioContentType}, false,
+		&access.NotificationSubscriptionOptions{ReceiveAdvancedNotifications: true})
+	require.NoError(t, err)
+	monitor, err := d.Subscribe("10.0.0.1:5000", []string{dispatch.AllContentType}, true, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	audio.Counters().RecordWrite(100, false)
+	monitor.Counters().RecordWrite(50, false)
+
+	streams := NewStreams()
+	streams.add(article, keyFingerprint("article-key"), func() {})
+
+	get := func(query string) (int, subscriptionStats) {
+		w := httptest.NewRecorder()
+		Stats(d, streams, l)(w, httptest.NewRequest(http.MethodGet, "/__stats"+query, nil))
+		stats := subscriptionStats{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		}
+		return w.Code, stats
+	}
+	ids := func(stats subscriptionStats) []string {
+		ids := []string{}
+		for _, s := range stats.Subscribers {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	_, stats := get("")
+	assert.Equal(t, 3, stats.NrOfSubscribers)
+	assert.Equal(t, []string{article.ID(), audio.ID(), monitor.ID()}, ids(stats), "Subscribers should be sorted by age by default")
+	assert.Equal(t, 2, stats.Summary.Standard)
+	assert.Equal(t, 1, stats.Summary.Monitor)
+	assert.Equal(t, 1, stats.Summary.ReceiveAdvancedNotifications)
+	assert.Equal(t, map[string]int{
+		dispatch.ArticleContentType: 1,
+		dispatch.AudioContentType:   1,
+		dispatch.AllContentType:     1,
+	}, stats.Summary.BySubscriptionType)
+	assert.Equal(t, ageBucket{Bucket: "0-1m", Count: 3}, stats.Summary.ConnectionAge[0])
+
+	_, stats = get("?address=192.168.&monitor=false")
+	assert.Equal(t, []string{article.ID(), audio.ID()}, ids(stats))
+	assert.Equal(t, 2, stats.Summary.Standard)
+
+	_, stats = get("?keyFingerprint=" + keyFingerprint("article-key"))
+	assert.Equal(t, []string{article.ID()}, ids(stats))
+	assert.Equal(t, keyFingerprint("article-key"), stats.Subscribers[0].KeyFingerprint)
+
+	_, stats = get("?type=audio")
+	assert.Equal(t, []string{audio.ID()}, ids(stats))
+
+	_, stats = get("?minAge=1h")
+	assert.Empty(t, stats.Subscribers)
+
+	_, stats = get("?sort=bytesWritten&order=desc&limit=2")
+	assert.Equal(t, 3, stats.NrOfSubscribers, "The count should not be limited by the page")
+	assert.Equal(t, []string{audio.ID(), monitor.ID()}, ids(stats))
+
+	_, stats = get("?sort=bytesWritten&order=desc&offset=2")
+	assert.Equal(t, []string{article.ID()}, ids(stats))
+
+	for _, query := range []string{"?sort=unknown", "?order=up", "?minAge=old", "?monitor=maybe", "?limit=-1"} {
+		code, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
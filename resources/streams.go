@@ -0,0 +1,243 @@
+package resources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const keyFingerprintLength = 16
+
+// keyFingerprint identifies an API key without revealing it
+func keyFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:keyFingerprintLength]
+}
+
+type throttle struct {
+	perMinute int
+	until     time.Time
+}
+
+// interval is the minimum time between two notifications written to the subscriber
+func (t throttle) interval() time.Duration {
+	return time.Minute / time.Duration(t.perMinute)
+}
+
+// stream is an open subscriber connection
+type stream struct {
+	subscriber     dispatch.Subscriber
+	keyFingerprint string
+	cancel         context.CancelFunc
+	mutex          *sync.Mutex
+	throttle       *throttle
+	closedBy       string
+}
+
+// close cancels the context of the subscriber connection, the reason is logged when the connection ends
+func (s *stream) close(reason string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.closedBy == "" {
+		s.closedBy = reason
+	}
+	s.mutex.Unlock()
+	s.cancel()
+}
+
+func (s *stream) closeReason() string {
+	if s == nil {
+		return ""
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closedBy
+}
+
+func (s *stream) setThrottle(t *throttle) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.throttle = t
+}
+
+// activeThrottle returns the rate cap of the subscriber, nil when it is not throttled or the cap has expired
+func (s *stream) activeThrottle() *throttle {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.throttle != nil && time.Now().After(s.throttle.until) {
+		s.throttle = nil
+	}
+	return s.throttle
+}
+
+// throttleWait returns how long the next notification is held to respect the rate cap of the subscriber
+func (s *stream) throttleWait(lastSent time.Time) time.Duration {
+	t := s.activeThrottle()
+	if t == nil || lastSent.IsZero() {
+		return 0
+	}
+	return time.Until(lastSent.Add(t.interval()))
+}
+
+// next returns the channel to read the next notification of the subscriber from, nil while the rate cap holds it,
+// together with the channel firing once the hold ends. Waiting on both alongside the heartbeats keeps a throttled stream alive.
+func (s *stream) next(sub dispatch.Subscriber, lastSent time.Time) (<-chan string, <-chan time.Time) {
+	if wait := s.throttleWait(lastSent); wait > 0 {
+		return nil, time.After(wait)
+	}
+	return sub.Notifications(), nil
+}
+
+// Streams keeps track of the open subscriber connections, so they can be inspected, throttled and closed by the admins
+type Streams struct {
+	mutex   *sync.RWMutex
+	streams map[string]*stream
+}
+
+func NewStreams() *Streams {
+	return &Streams{
+		mutex:   &sync.RWMutex{},
+		streams: map[string]*stream{},
+	}
+}
+
+func (s *Streams) add(sub dispatch.Subscriber, keyFingerprint string, cancel context.CancelFunc) *stream {
+	if s == nil {
+		return nil
+	}
+	st := &stream{
+		subscriber:     sub,
+		keyFingerprint: keyFingerprint,
+		cancel:         cancel,
+		mutex:          &sync.Mutex{},
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.streams[sub.ID()] = st
+	return st
+}
+
+func (s *Streams) remove(st *stream) {
+	if s == nil || st == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.streams, st.subscriber.ID())
+}
+
+func (s *Streams) get(subscriberID string) (*stream, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	st, ok := s.streams[subscriberID]
+	return st, ok
+}
+
+// keyFingerprints returns the API key fingerprints of the connected subscribers by subscriber ID
+func (s *Streams) keyFingerprints() map[string]string {
+	fingerprints := map[string]string{}
+	if s == nil {
+		return fingerprints
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for id, st := range s.streams {
+		fingerprints[id] = st.keyFingerprint
+	}
+	return fingerprints
+}
+
+// list returns the streams matching the filter, oldest subscriber first
+func (s *Streams) list(filter streamFilter) []*stream {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	matching := make([]*stream, 0, len(s.streams))
+	for _, st := range s.streams {
+		if filter.matches(st) {
+			matching = append(matching, st)
+		}
+	}
+	sort.Slice(matching, func(a, b int) bool {
+		return matching[a].subscriber.Since().Before(matching[b].subscriber.Since())
+	})
+	return matching
+}
+
+// streamFilter selects subscriber connections. Empty fields match any connection.
+type streamFilter struct {
+	IDs            []string
+	Address        string
+	KeyFingerprint string
+	SubType        string
+	Monitor        *bool
+}
+
+// selective reports whether the filter identifies subscribers by ID, address or API key
+func (f streamFilter) selective() bool {
+	return len(f.IDs) > 0 || f.Address != "" || f.KeyFingerprint != ""
+}
+
+func (f streamFilter) matches(st *stream) bool {
+	sub := st.subscriber
+	if len(f.IDs) > 0 && !contains(f.IDs, sub.ID()) {
+		return false
+	}
+	if f.Address != "" && sub.Address() != f.Address {
+		return false
+	}
+	if f.KeyFingerprint != "" && st.keyFingerprint != f.KeyFingerprint {
+		return false
+	}
+	if f.SubType != "" && !containsFold(sub.SubTypes(), f.SubType) {
+		return false
+	}
+	if f.Monitor != nil {
+		_, isMonitor := sub.(*dispatch.MonitorSubscriber)
+		if isMonitor != *f.Monitor {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/gorilla/mux"
+)
+
+const defaultThrottleDuration = 10 * time.Minute
+
+type throttleInfo struct {
+	PerMinute int       `json:"perMinute"`
+	Until     time.Time `json:"until"`
+}
+
+type subscriberInfo struct {
+	ID                string        `json:"id"`
+	Address           string        `json:"address"`
+	Since             time.Time     `json:"since"`
+	SubscriptionTypes []string      `json:"subscriptionTypes"`
+	Monitor           bool          `json:"monitor"`
+	KeyFingerprint    string        `json:"keyFingerprint,omitempty"`
+	Throttle          *throttleInfo `json:"throttle,omitempty"`
+}
+
+type subscriberList struct {
+	NrOfSubscribers int              `json:"nrOfSubscribers"`
+	Subscribers     []subscriberInfo `json:"subscribers"`
+}
+
+type disconnectResponse struct {
+	Disconnected  int      `json:"disconnected"`
+	SubscriberIDs []string `json:"subscriberIds"`
+}
+
+func newSubscriberInfo(st *stream) subscriberInfo {
+	sub := st.subscriber
+	_, isMonitor := sub.(*dispatch.MonitorSubscriber)
+	info := subscriberInfo{
+		ID:                sub.ID(),
+		Address:           sub.Address(),
+		Since:             sub.Since(),
+		SubscriptionTypes: sub.SubTypes(),
+		Monitor:           isMonitor,
+		KeyFingerprint:    st.keyFingerprint,
+	}
+	if t := st.activeThrottle(); t != nil {
+		info.Throttle = &throttleInfo{PerMinute: t.perMinute, Until: t.until}
+	}
+	return info
+}
+
+// parseStreamFilter reads the subscriber selection from the request params.
+// The API key is matched through its fingerprint, so it is never logged or returned.
+func parseStreamFilter(query url.Values) (streamFilter, error) {
+	filter := streamFilter{
+		IDs:            query["id"],
+		Address:        query.Get("address"),
+		KeyFingerprint: query.Get("keyFingerprint"),
+		SubType:        query.Get("type"),
+	}
+	if apiKey := query.Get(apiKeyQueryParam); apiKey != "" {
+		fingerprint := keyFingerprint(apiKey)
+		if filter.KeyFingerprint != "" && filter.KeyFingerprint != fingerprint {
+			return streamFilter{}, errors.New("apiKey and keyFingerprint select different keys")
+		}
+		filter.KeyFingerprint = fingerprint
+	}
+	if param := query.Get("monitor"); param != "" {
+		monitor, err := strconv.ParseBool(param)
+		if err != nil {
+			return streamFilter{}, errors.New("monitor must be true or false")
+		}
+		filter.Monitor = &monitor
+	}
+	return filter, nil
+}
+
+// ListSubscribers returns the connected subscribers, filtered by the id, address, apiKey, keyFingerprint, type and monitor params
+func (h *AdminHandler) ListSubscribers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStreamFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid subscriber filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	streams := h.streams.list(filter)
+	list := subscriberList{
+		NrOfSubscribers: len(streams),
+		Subscribers:     make([]subscriberInfo, 0, len(streams)),
+	}
+	for _, st := range streams {
+		list.Subscribers = append(list.Subscribers, newSubscriberInfo(st))
+	}
+	h.writeJSON(w, http.StatusOK, list)
+}
+
+// DisconnectSubscribers closes the connections of the subscribers selected by ID, address or API key.
+// The type and monitor params narrow the selection down but are not enough on their own.
+func (h *AdminHandler) DisconnectSubscribers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStreamFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid subscriber filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !filter.selective() {
+		http.Error(w, "At least one of id, address, apiKey or keyFingerprint is required", http.StatusBadRequest)
+		return
+	}
+
+	streams := h.streams.list(filter)
+	resp := disconnectResponse{SubscriberIDs: make([]string, 0, len(streams))}
+	for _, st := range streams {
+		st.close("disconnected by admin")
+		resp.SubscriberIDs = append(resp.SubscriberIDs, st.subscriber.ID())
+	}
+	resp.Disconnected = len(resp.SubscriberIDs)
+
+	h.log.WithFields(map[string]interface{}{
+		"address":           getClientAddr(r),
+		"action":            "disconnect subscribers",
+		"subscriberAddress": filter.Address,
+		"keyFingerprint":    filter.KeyFingerprint,
+		"subscriberIds":     resp.SubscriberIDs,
+	}).Info("Admin subscriber action")
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// ThrottleSubscriber caps the number of notifications written to a subscriber per minute, given by the perMinute param,
+// for the number of seconds given by the duration param. The notifications over the cap are held in the subscriber buffer
+// and are dropped once it is full, as for any subscriber lagging behind.
+func (h *AdminHandler) ThrottleSubscriber(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	st, found := h.streams.get(id)
+	if !found {
+		http.Error(w, "No subscriber found with id "+id, http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	perMinute, err := strconv.Atoi(query.Get("perMinute"))
+	if err != nil || perMinute <= 0 {
+		http.Error(w, "perMinute must be a positive number", http.StatusBadRequest)
+		return
+	}
+	duration := defaultThrottleDuration
+	if param := query.Get("duration"); param != "" {
+		seconds, err := strconv.Atoi(param)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "duration must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	t := &throttle{perMinute: perMinute, until: time.Now().Add(duration)}
+	st.setThrottle(t)
+
+	h.log.WithFields(map[string]interface{}{
+		"address":       getClientAddr(r),
+		"action":        "throttle subscriber",
+		"subscriberIds": []string{id},
+		"perMinute":     perMinute,
+		"until":         t.until,
+	}).Info("Admin subscriber action")
+
+	h.writeJSON(w, http.StatusOK, newSubscriberInfo(st))
+}
+
+// UnthrottleSubscriber lifts the rate cap of a subscriber
+func (h *AdminHandler) UnthrottleSubscriber(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	st, found := h.streams.get(id)
+	if !found {
+		http.Error(w, "No subscriber found with id "+id, http.StatusNotFound)
+		return
+	}
+	st.setThrottle(nil)
+
+	h.log.WithFields(map[string]interface{}{
+		"address":       getClientAddr(r),
+		"action":        "unthrottle subscriber",
+		"subscriberIds": []string{id},
+	}).Info("Admin subscriber action")
+
+	h.writeJSON(w, http.StatusOK, newSubscriberInfo(st))
+}
+
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		h.log.WithError(err).Warn("Error in marshalling admin response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(bytes); err != nil {
+		h.log.WithError(err).Warn("Error writing admin response")
+	}
+}
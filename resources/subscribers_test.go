@@ -0,0 +1,195 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
+)
+
+func TestSubscriberManagement(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, nil, nil, l)
+
+	article, err := d.Subscribe("192.168.1.2", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+	monitor, err := d.Subscribe("192.168.1.3", []string{dispatch.AllContentType}, true, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	streams := NewStreams()
+	articleCtx, articleCancel := context.WithCancel(context.Background())
+	defer articleCancel()
+	streams.add(article, keyFingerprint("article-key"), articleCancel)
+	monitorCtx, monitorCancel := context.WithCancel(context.Background())
+	defer monitorCancel()
+	streams.add(monitor, keyFingerprint("monitor-key"), monitorCancel)
+
+	admin := NewAdminHandler("secret", d, nil, nil, nil, nil, nil, streams, nil, nil, time.Second, l)
+	router := mux.NewRouter()
+	router.HandleFunc("/__admin/subscribers", admin.ListSubscribers).Methods("GET")
+	router.HandleFunc("/__admin/subscribers", admin.DisconnectSubscribers).Methods("DELETE")
+	router.HandleFunc("/__admin/subscribers/{id}/throttle", admin.ThrottleSubscriber).Methods("POST")
+	router.HandleFunc("/__admin/subscribers/{id}/throttle", admin.UnthrottleSubscriber).Methods("DELETE")
+
+	call := func(method string, url string, v interface{}) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		if v != nil && w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+		}
+		return w.Code
+	}
+
+	list := subscriberList{}
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/__admin/subscribers", &list))
+	assert.Equal(t, 2, list.NrOfSubscribers)
+
+	list = subscriberList{}
+	call(http.MethodGet, "/__admin/subscribers?monitor=true", &list)
+	require.Len(t, list.Subscribers, 1)
+	assert.Equal(t, monitor.ID(), list.Subscribers[0].ID)
+	assert.True(t, list.Subscribers[0].Monitor)
+
+	list = subscriberList{}
+	call(http.MethodGet, "/__admin/subscribers?apiKey=article-key", &list)
+	require.Len(t, list.Subscribers, 1)
+	assert.Equal(t, article.ID(), list.Subscribers[0].ID)
+	assert.Equal(t, keyFingerprint("article-key"), list.Subscribers[0].KeyFingerprint)
+	assert.NotContains(t, list.Subscribers[0].KeyFingerprint, "article-key")
+
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodGet, "/__admin/subscribers?monitor=maybe", nil))
+
+	info := subscriberInfo{}
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/__admin/subscribers/unknown/throttle?perMinute=10", nil))
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/__admin/subscribers/"+article.ID()+"/throttle", nil))
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "/__admin/subscribers/"+article.ID()+"/throttle?perMinute=10&duration=60", &info))
+	require.NotNil(t, info.Throttle)
+	assert.Equal(t, 10, info.Throttle.PerMinute)
+
+	info = subscriberInfo{}
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/__admin/subscribers/"+article.ID()+"/throttle", &info))
+	assert.Nil(t, info.Throttle)
+
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodDelete, "/__admin/subscribers?type=Article", nil),
+		"Disconnecting should require selecting subscribers by id, address or API key")
+
+	resp := disconnectResponse{}
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/__admin/subscribers?address=192.168.1.3", &resp))
+	assert.Equal(t, []string{monitor.ID()}, resp.SubscriberIDs)
+	assert.Error(t, monitorCtx.Err(), "The disconnected subscriber context should be cancelled")
+	assert.NoError(t, articleCtx.Err())
+
+	st, _ := streams.get(monitor.ID())
+	assert.Equal(t, "disconnected by admin", st.closeReason())
+}
+
+func TestDisconnectEndsStream(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	s, err := dispatch.NewStandardSubscriber("192.168.1.2", []string{dispatch.ArticleContentType}, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	streams := NewStreams()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := streams.add(s, "", cancel)
+
+	sub := &SubHandler{streams: streams, heartbeatPeriod: time.Minute, log: l}
+	done := make(chan [2]string)
+	go func() {
+		cause, reason := sub.listenForNotifications(ctx, s, st, httptest.NewRecorder())
+		done <- [2]string{cause, reason}
+	}()
+
+	st.close("disconnected by admin")
+	select {
+	case disconnect := <-done:
+		assert.Equal(t, [2]string{sessions.CauseAdmin, "disconnected by admin"}, disconnect)
+	case <-time.After(time.Second):
+		t.Fatal("The subscriber stream should end once disconnected")
+	}
+}
+
+// frameWriter passes the frames written to a stream to the test
+type frameWriter struct {
+	header http.Header
+	frames chan string
+}
+
+func (w *frameWriter) Header() http.Header         { return w.header }
+func (w *frameWriter) WriteHeader(int)             {}
+func (w *frameWriter) Flush()                      {}
+func (w *frameWriter) Write(p []byte) (int, error) { w.frames <- string(p); return len(p), nil }
+
+func TestThrottledStreamHeartbeats(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	s, err := dispatch.NewStandardSubscriber("192.168.1.2", []string{dispatch.ArticleContentType}, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	streams := NewStreams()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := streams.add(s, "", cancel)
+	st.setThrottle(&throttle{perMinute: 1, until: time.Now().Add(time.Minute)})
+
+	w := &frameWriter{header: http.Header{}, frames: make(chan string, 16)}
+	sub := &SubHandler{streams: streams, heartbeatPeriod: 20 * time.Millisecond, log: l}
+	go sub.listenForNotifications(ctx, s, st, w)
+
+	next := func() string {
+		select {
+		case frame := <-w.frames:
+			return frame
+		case <-time.After(time.Second):
+			t.Fatal("The stream should keep writing frames")
+			return ""
+		}
+	}
+	assert.Equal(t, "data: "+HeartbeatMsg+"\n\n", next())
+
+	require.NoError(t, s.Send(dispatch.NotificationResponse{ID: "http://www.ft.com/thing/1"}))
+	require.NoError(t, s.Send(dispatch.NotificationResponse{ID: "http://www.ft.com/thing/2"}))
+	heartbeat := "data: " + HeartbeatMsg + "\n\n"
+	frame := next()
+	for frame == heartbeat {
+		frame = next()
+	}
+	assert.Contains(t, frame, "http://www.ft.com/thing/1")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, heartbeat, next(), "The heartbeats should be sent while the next notification is held")
+	}
+	assert.Len(t, s.Notifications(), 1, "The held notification should be left in the subscriber buffer")
+}
+
+func TestThrottleWait(t *testing.T) {
+	t.Parallel()
+
+	st := &stream{cancel: func() {}, mutex: &sync.Mutex{}}
+	assert.Zero(t, st.throttleWait(time.Now()), "An unthrottled subscriber should not wait")
+
+	st.setThrottle(&throttle{perMinute: 60, until: time.Now().Add(time.Minute)})
+	assert.Zero(t, st.throttleWait(time.Time{}), "The first notification should not wait")
+	wait := st.throttleWait(time.Now())
+	assert.True(t, wait > 900*time.Millisecond && wait <= time.Second, "Unexpected wait %v", wait)
+
+	st.setThrottle(&throttle{perMinute: 60, until: time.Now().Add(-time.Second)})
+	assert.Zero(t, st.throttleWait(time.Now()), "An expired rate cap should be lifted")
+	assert.Nil(t, st.activeThrottle())
+}
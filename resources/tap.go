@@ -0,0 +1,216 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/gorilla/mux"
+)
+
+// tap events
+const (
+	tapFrame   = "frame"
+	tapError   = "error"
+	tapDropped = "dropped"
+	tapClosed  = "closed"
+)
+
+const tapBuffer = 64
+
+type tapEvent struct {
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	Data          string    `json:"data,omitempty"`
+	TransactionID string    `json:"transactionId,omitempty"`
+	Outcome       string    `json:"outcome,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	TapDropped    uint64    `json:"tapDropped"`
+}
+
+type streamTap struct {
+	events  chan tapEvent
+	closed  chan struct{}
+	dropped uint64
+}
+
+func (t *streamTap) send(e tapEvent) {
+	select {
+	case t.events <- e:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// StreamTaps mirrors the frames written to subscriber streams to the admins tapping them.
+// Mirroring never blocks the subscriber, events are discarded when a tap is not keeping up.
+type StreamTaps struct {
+	mutex *sync.RWMutex
+	taps  map[string]map[*streamTap]struct{}
+}
+
+func NewStreamTaps() *StreamTaps {
+	return &StreamTaps{
+		mutex: &sync.RWMutex{},
+		taps:  map[string]map[*streamTap]struct{}{},
+	}
+}
+
+// open registers a tap on the subscriber, unless isSubscribed tells it is not connected.
+// The subscriber is looked up while holding the lock taken by disconnected, which is called once the subscriber is unsubscribed,
+// so a tap opened on a connected subscriber is always ended.
+func (t *StreamTaps) open(subscriberID string, isSubscribed func(id string) bool) (*streamTap, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !isSubscribed(subscriberID) {
+		return nil, false
+	}
+	tap := &streamTap{
+		events: make(chan tapEvent, tapBuffer),
+		closed: make(chan struct{}),
+	}
+	if t.taps[subscriberID] == nil {
+		t.taps[subscriberID] = map[*streamTap]struct{}{}
+	}
+	t.taps[subscriberID][tap] = struct{}{}
+	return tap, true
+}
+
+func (t *StreamTaps) close(subscriberID string, tap *streamTap) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.taps[subscriberID], tap)
+	if len(t.taps[subscriberID]) == 0 {
+		delete(t.taps, subscriberID)
+	}
+}
+
+func (t *StreamTaps) publish(subscriberID string, e tapEvent) {
+	if t == nil {
+		return
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if len(t.taps[subscriberID]) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for tap := range t.taps[subscriberID] {
+		tap.send(e)
+	}
+}
+
+// disconnected ends the taps of a subscriber once it is unsubscribed
+func (t *StreamTaps) disconnected(subscriberID string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for tap := range t.taps[subscriberID] {
+		close(tap.closed)
+	}
+	delete(t.taps, subscriberID)
+}
+
+// Tap streams a read-only copy of the frames written to a subscriber, heartbeats included,
+// together with the notifications dropped for it and the errors of its connection.
+func (h *AdminHandler) Tap(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	tap, ok := h.taps.open(id, h.isSubscribed)
+	if !ok {
+		http.Error(w, "No subscriber found with id "+id, http.StatusNotFound)
+		return
+	}
+	defer h.taps.close(id, tap)
+
+	write := startEventStream(w)
+
+	watcher := h.dispatcher.WatchTraces()
+	defer h.dispatcher.UnwatchTraces(watcher)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.shutdown.RegisterOnShutdown(cancel)
+
+	logEntry := h.log.WithField("address", getClientAddr(r)).WithField("subscriberId", id)
+	logEntry.Info("Admin tap opened on subscriber stream")
+
+	if err := write(HeartbeatMsg); err != nil {
+		logEntry.WithError(err).Error("Sending heartbeat to tap stream has failed")
+		return
+	}
+
+	writeEvent := func(e tapEvent) error {
+		e.TapDropped = atomic.LoadUint64(&tap.dropped)
+		event, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return write(string(event))
+	}
+
+	timer := time.NewTimer(h.heartbeatPeriod)
+	defer timer.Stop()
+	for {
+		select {
+		case e := <-tap.events:
+			if err := writeEvent(e); err != nil {
+				logEntry.WithError(err).Error("Error while writing to tap stream")
+				return
+			}
+		case trace := <-watcher.Traces():
+			for _, o := range trace.Subscribers {
+				if o.SubscriberID != id || (o.Outcome != dispatch.OutcomeLagging && o.Outcome != dispatch.OutcomeFailed) {
+					continue
+				}
+				err := writeEvent(tapEvent{
+					Event:         tapDropped,
+					Time:          time.Now(),
+					TransactionID: trace.TransactionID,
+					Outcome:       o.Outcome,
+					Error:         o.Error,
+				})
+				if err != nil {
+					logEntry.WithError(err).Error("Error while writing to tap stream")
+					return
+				}
+			}
+		case <-timer.C:
+			if err := write(HeartbeatMsg); err != nil {
+				logEntry.WithError(err).Error("Sending heartbeat to tap stream has failed")
+				return
+			}
+			timer.Reset(h.heartbeatPeriod)
+		case <-tap.closed:
+			for len(tap.events) > 0 {
+				if err := writeEvent(<-tap.events); err != nil {
+					return
+				}
+			}
+			_ = writeEvent(tapEvent{Event: tapClosed, Time: time.Now()})
+			logEntry.Info("Tapped subscriber disconnected")
+			return
+		case <-ctx.Done():
+			logEntry.Info("Admin tap closed")
+			return
+		}
+	}
+}
+
+func (h *AdminHandler) isSubscribed(id string) bool {
+	for _, s := range h.dispatcher.Subscribers() {
+		if s.ID() == id {
+			return true
+		}
+	}
+	return false
+}
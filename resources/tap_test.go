@@ -0,0 +1,135 @@
+package resources
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+func TestTap(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, agent, nil, l)
+	go d.Start()
+	defer d.Stop()
+
+	s, err := d.Subscribe("192.168.1.2", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	taps := NewStreamTaps()
+	reg := mocks.NewShutdownReg()
+	reg.On("RegisterOnShutdown", mock.Anything)
+	admin := NewAdminHandler("secret", d, nil, taps, nil, nil, nil, nil, nil, reg, time.Minute, l)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/__admin/subscribers/{id}/tap", admin.Tap)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/__admin/subscribers/unknown/tap")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/__admin/subscribers/" + s.ID() + "/tap")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := bufio.NewReader(resp.Body)
+	line, err := events.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: "+HeartbeatMsg+"\n", line, "The tap should be opened with a heartbeat")
+
+	sub := &SubHandler{notif: d, taps: taps, heartbeatPeriod: time.Minute, log: l}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sub.listenForNotifications(ctx, s, nil, httptest.NewRecorder())
+		close(done)
+	}()
+
+	e := readTapEvent(t, events)
+	assert.Equal(t, tapFrame, e.Event)
+	assert.Equal(t, HeartbeatMsg, e.Data, "The subscriber heartbeat should be mirrored")
+
+	d.Send(dispatch.NotificationModel{
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_tap",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+
+	e = readTapEvent(t, events)
+	assert.Equal(t, tapFrame, e.Event)
+	assert.Contains(t, e.Data, "7998974a-1e97-11e6-b286-cddde55ca122")
+
+	cancel()
+	<-done
+	sub.unsubscribe(s)
+
+	e = readTapEvent(t, events)
+	assert.Equal(t, tapClosed, e.Event)
+
+	resp, err = http.Get(srv.URL + "/__admin/subscribers/" + s.ID() + "/tap")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "The unsubscribed subscriber should not be tapped")
+}
+
+func TestTapHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, &stubAgent{}, nil, l)
+	s, err := d.Subscribe("192.168.1.2", []string{dispatch.ArticleContentType}, false, &access.NotificationSubscriptionOptions{})
+	require.NoError(t, err)
+
+	reg := mocks.NewShutdownReg()
+	reg.On("RegisterOnShutdown", mock.Anything)
+	admin := NewAdminHandler("secret", d, nil, NewStreamTaps(), nil, nil, nil, nil, nil, reg, 10*time.Millisecond, l)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/__admin/subscribers/{id}/tap", admin.Tap)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/__admin/subscribers/" + s.ID() + "/tap")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := events.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "data: "+HeartbeatMsg+"\n", line, "The idle tap should be kept alive with heartbeats")
+		_, err = events.ReadString('\n')
+		require.NoError(t, err)
+	}
+}
+
+func readTapEvent(t *testing.T, r *bufio.Reader) tapEvent {
+	t.Helper()
+
+	e := tapEvent{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(readEvent(t, r), "data: ")), &e))
+	return e
+}
@@ -0,0 +1,39 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/gorilla/mux"
+)
+
+type traceProvider interface {
+	Traces(tid string) []dispatch.DeliveryTrace
+}
+
+// DeliveryTraces returns the delivery decisions taken for the notifications of a transaction
+func DeliveryTraces(provider traceProvider, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tid := mux.Vars(r)["tid"]
+
+		traces := provider.Traces(tid)
+		if len(traces) == 0 {
+			http.Error(w, "No delivery trace found for transaction "+tid, http.StatusNotFound)
+			return
+		}
+
+		bytes, err := json.Marshal(traces)
+		if err != nil {
+			log.WithTransactionID(tid).WithError(err).Warn("Error in marshalling delivery traces")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		if _, err = w.Write(bytes); err != nil {
+			log.WithTransactionID(tid).WithError(err).Warn("Error writing delivery traces to HTTP response")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package resources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+)
+
+func TestDeliveryTraces(t *testing.T) {
+	t.Parallel()
+
+	l := logger.NewUPPLogger("test", "panic")
+	d := &mocks.Dispatcher{}
+	d.On("Traces", "tid_found").Return([]dispatch.DeliveryTrace{{
+		TransactionID: "tid_found",
+		Policy:        &dispatch.PolicyDecision{Allow: true, DecisionID: "decision"},
+		Subscribers: []dispatch.SubscriberOutcome{
+			{SubscriberID: "sub", Address: "127.0.0.1", Outcome: dispatch.OutcomeSkippedType},
+		},
+	}})
+	d.On("Traces", "tid_missing").Return([]dispatch.DeliveryTrace(nil))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/__history/{tid}", DeliveryTraces(d, l))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/__history/tid_found", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"decisionId":"decision"`)
+	assert.Contains(t, w.Body.String(), `"outcome":"skipped-type"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/__history/tid_missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	d.AssertExpectations(t)
+}
@@ -0,0 +1,210 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+const defaultVolumePeriod = time.Hour
+
+// volumeDimensions are the names of the volume breakdown, in the order they are listed
+var volumeDimensions = []string{"subscriptionType", "type", "editorialDesk", "publication", "policy"}
+
+func dimensionValue(key dispatch.VolumeKey, dimension string) string {
+	switch dimension {
+	case "subscriptionType":
+		return key.SubscriptionType
+	case "type":
+		return key.Type
+	case "editorialDesk":
+		return key.EditorialDesk
+	case "publication":
+		return key.Publication
+	case "policy":
+		return key.Policy
+	}
+	return ""
+}
+
+type volumeCount struct {
+	Dimensions map[string]string `json:"dimensions"`
+	Count      int               `json:"count"`
+}
+
+type volumePoint struct {
+	Start  time.Time     `json:"start"`
+	Total  int           `json:"total"`
+	Counts []volumeCount `json:"counts"`
+}
+
+type volumeSeries struct {
+	From     time.Time     `json:"from"`
+	Interval string        `json:"interval"`
+	GroupBy  []string      `json:"groupBy"`
+	Total    int           `json:"total"`
+	Points   []volumePoint `json:"points"`
+}
+
+type volumeQuery struct {
+	period   time.Duration
+	interval time.Duration
+	groupBy  []string
+	filters  map[string][]string
+}
+
+type volumeProvider interface {
+	Volume(since time.Time) []dispatch.VolumeCounts
+}
+
+// Volume returns the number of notifications dispatched over the last period as a time series.
+// The counts are broken down by the groupBy dimensions, all of them by default, and can be filtered on any dimension.
+func Volume(provider volumeProvider, log *logger.UPPLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseVolumeQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		series := q.aggregate(provider.Volume(now.Add(-q.period).Add(dispatch.VolumeBucket)))
+
+		bytes, err := json.Marshal(series)
+		if err != nil {
+			log.WithError(err).Warn("Error in marshalling the notification volume")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		if _, err = w.Write(bytes); err != nil {
+			log.WithError(err).Warn("Error writing the notification volume to HTTP response")
+		}
+	}
+}
+
+func parseVolumeQuery(r *http.Request) (volumeQuery, error) {
+	values := r.URL.Query()
+	q := volumeQuery{
+		period:   defaultVolumePeriod,
+		interval: dispatch.VolumeBucket,
+		groupBy:  volumeDimensions,
+		filters:  map[string][]string{},
+	}
+
+	var err error
+	if period := values.Get("period"); period != "" {
+		if q.period, err = time.ParseDuration(period); err != nil {
+			return q, fmt.Errorf("invalid period parameter: %w", err)
+		}
+		if q.period < dispatch.VolumeBucket || q.period > dispatch.VolumeWindow {
+			return q, fmt.Errorf("invalid period parameter: expected between %v and %v", dispatch.VolumeBucket, dispatch.VolumeWindow)
+		}
+	}
+	if interval := values.Get("interval"); interval != "" {
+		if q.interval, err = time.ParseDuration(interval); err != nil {
+			return q, fmt.Errorf("invalid interval parameter: %w", err)
+		}
+		if q.interval < dispatch.VolumeBucket || q.interval%dispatch.VolumeBucket != 0 {
+			return q, fmt.Errorf("invalid interval parameter: expected a multiple of %v", dispatch.VolumeBucket)
+		}
+	}
+	if groupBy, found := values["groupBy"]; found {
+		q.groupBy = []string{}
+		for _, dimension := range strings.Split(strings.Join(groupBy, ","), ",") {
+			if dimension == "" {
+				continue
+			}
+			if !contains(volumeDimensions, dimension) {
+				return q, fmt.Errorf("invalid groupBy parameter: %q is not one of %s", dimension, strings.Join(volumeDimensions, ", "))
+			}
+			if !contains(q.groupBy, dimension) {
+				q.groupBy = append(q.groupBy, dimension)
+			}
+		}
+	}
+	for _, dimension := range volumeDimensions {
+		if filter, found := values[dimension]; found {
+			q.filters[dimension] = filter
+		}
+	}
+	return q, nil
+}
+
+func (q volumeQuery) matches(key dispatch.VolumeKey) bool {
+	for dimension, accepted := range q.filters {
+		if !containsFold(accepted, dimensionValue(key, dimension)) {
+			return false
+		}
+	}
+	return true
+}
+
+// aggregate sums the counts of the minutes by interval, aligned on the interval, and of the keys by the groupBy dimensions
+func (q volumeQuery) aggregate(minutes []dispatch.VolumeCounts) volumeSeries {
+	series := volumeSeries{
+		Interval: q.interval.String(),
+		GroupBy:  q.groupBy,
+		Points:   []volumePoint{},
+	}
+	var point *volumePoint
+	var groups map[string]int
+	for _, m := range minutes {
+		start := m.Start.Truncate(q.interval)
+		if point == nil || !point.Start.Equal(start) {
+			series.Points = append(series.Points, volumePoint{Start: start, Counts: []volumeCount{}})
+			point = &series.Points[len(series.Points)-1]
+			groups = map[string]int{}
+		}
+		for key, count := range m.Counts {
+			if !q.matches(key) {
+				continue
+			}
+			dimensions := make(map[string]string, len(q.groupBy))
+			values := make([]string, 0, len(q.groupBy))
+			for _, dimension := range q.groupBy {
+				dimensions[dimension] = dimensionValue(key, dimension)
+				values = append(values, dimensions[dimension])
+			}
+			group := strings.Join(values, "\x00")
+			i, found := groups[group]
+			if !found {
+				i = len(point.Counts)
+				groups[group] = i
+				point.Counts = append(point.Counts, volumeCount{Dimensions: dimensions})
+			}
+			point.Counts[i].Count += count
+			point.Total += count
+			series.Total += count
+		}
+	}
+	for i := range series.Points {
+		sortVolumeCounts(series.Points[i].Counts, q.groupBy)
+	}
+	if len(series.Points) > 0 {
+		series.From = series.Points[0].Start
+	}
+	return series
+}
+
+// sortVolumeCounts orders the counts from the largest, then by dimension values
+func sortVolumeCounts(counts []volumeCount, groupBy []string) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		for _, dimension := range groupBy {
+			if counts[i].Dimensions[dimension] != counts[j].Dimensions[dimension] {
+				return counts[i].Dimensions[dimension] < counts[j].Dimensions[dimension]
+			}
+		}
+		return false
+	})
+}
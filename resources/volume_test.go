@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+)
+
+type stubVolume struct {
+	minutes []dispatch.VolumeCounts
+	since   time.Time
+}
+
+func (s *stubVolume) Volume(since time.Time) []dispatch.VolumeCounts {
+	s.since = since
+	return s.minutes
+}
+
+func TestVolume(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 7, 31, 10, 0, 0, 0, time.UTC)
+	article := dispatch.VolumeKey{SubscriptionType: "Article", Type: "UPDATE", EditorialDesk: "/FT/Money", Policy: "allow"}
+	deleted := dispatch.VolumeKey{SubscriptionType: "Article", Type: "DELETE", EditorialDesk: "/FT/Money", Policy: "allow"}
+	denied := dispatch.VolumeKey{SubscriptionType: "Audio", Type: "UPDATE", EditorialDesk: "/FT/Blocked", Policy: "deny"}
+	minutes := []dispatch.VolumeCounts{
+		{Start: start, Counts: map[dispatch.VolumeKey]int{article: 3, denied: 1}},
+		{Start: start.Add(time.Minute), Counts: map[dispatch.VolumeKey]int{}},
+		{Start: start.Add(2 * time.Minute), Counts: map[dispatch.VolumeKey]int{article: 1, deleted: 2}},
+	}
+
+	tests := map[string]struct {
+		query          string
+		expectedStatus int
+		expected       volumeSeries
+	}{
+		"all dimensions by minute": {
+			expectedStatus: http.StatusOK,
+			expected: volumeSeries{From: start, Interval: "1m0s", GroupBy: volumeDimensions, Total: 7, Points: []volumePoint{
+				{Start: start, Total: 4, Counts: []volumeCount{
+					{Dimensions: map[string]string{"subscriptionType": "Article", "type": "UPDATE", "editorialDesk": "/FT/Money", "publication": "", "policy": "allow"}, Count: 3},
+					{Dimensions: map[string]string{"subscriptionType": "Audio", "type": "UPDATE", "editorialDesk": "/FT/Blocked", "publication": "", "policy": "deny"}, Count: 1},
+				}},
+				{Start: start.Add(time.Minute), Counts: []volumeCount{}},
+				{Start: start.Add(2 * time.Minute), Total: 3, Counts: []volumeCount{
+					{Dimensions: map[string]string{"subscriptionType": "Article", "type": "DELETE", "editorialDesk": "/FT/Money", "publication": "", "policy": "allow"}, Count: 2},
+					{Dimensions: map[string]string{"subscriptionType": "Article", "type": "UPDATE", "editorialDesk": "/FT/Money", "publication": "", "policy": "allow"}, Count: 1},
+				}},
+			}},
+		},
+		"grouped and filtered by interval": {
+			query:          "?interval=5m&groupBy=editorialDesk&subscriptionType=article",
+			expectedStatus: http.StatusOK,
+			expected: volumeSeries{From: start, Interval: "5m0s", GroupBy: []string{"editorialDesk"}, Total: 6, Points: []volumePoint{
+				{Start: start, Total: 6, Counts: []volumeCount{
+					{Dimensions: map[string]string{"editorialDesk": "/FT/Money"}, Count: 6},
+				}},
+			}},
+		},
+		"no breakdown": {
+			query:          "?interval=1h&groupBy=",
+			expectedStatus: http.StatusOK,
+			expected: volumeSeries{From: start, Interval: "1h0m0s", GroupBy: []string{}, Total: 7, Points: []volumePoint{
+				{Start: start, Total: 7, Counts: []volumeCount{{Dimensions: map[string]string{}, Count: 7}}},
+			}},
+		},
+		"invalid period": {
+			query:          "?period=48h",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid interval": {
+			query:          "?interval=90s",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid dimension": {
+			query:          "?groupBy=type,author",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := logger.NewUPPLogger("test", "panic")
+			provider := &stubVolume{minutes: minutes}
+
+			w := httptest.NewRecorder()
+			Volume(provider, l)(w, httptest.NewRequest(http.MethodGet, "/__volume"+test.query, nil))
+
+			require.Equal(t, test.expectedStatus, w.Code, w.Body.String())
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.WithinDuration(t, time.Now().Add(-defaultVolumePeriod+time.Minute), provider.since, time.Second)
+
+			series := volumeSeries{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+			assert.Equal(t, test.expected, series)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/metrics"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
+)
+
+const (
+	wsWriteWait = 10 * time.Second
+	// wsMaxControlMessage bounds the size of the control messages sent by the clients
+	wsMaxControlMessage = 4096
+	wsControlBuffer     = 8
+)
+
+// control message actions
+const (
+	wsActionSubscribe  = "subscribe"
+	wsActionAck        = "ack"
+	wsActionSubscribed = "subscribed"
+	wsActionError      = "error"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// the subscribers are authenticated by their API key, so browsers are allowed to connect from any origin
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsControl is a control message. The clients send subscribe messages to change their subscription types
+// and ack messages to acknowledge the notifications they processed, the service answers with subscribed or error messages.
+type wsControl struct {
+	Action string   `json:"action"`
+	Types  []string `json:"types,omitempty"`
+	ID     string   `json:"id,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// HandleWebSocket streams the notifications over a WebSocket connection, each notification in a text message.
+// The subscription is validated and resolved as for HandleSubscription, the connection is kept alive with pings.
+func (h *SubHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	s, apiKey, ok := h.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer h.unsubscribe(s)
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered the request with an error
+		h.log.WithError(err).WithField("subscriberId", s.ID()).Warn("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	ctx, st, done := h.openStream(r.Context(), s, apiKey)
+	done(h.streamWebSocket(ctx, s, st, conn))
+}
+
+// streamWebSocket writes the notifications of the subscriber to the connection until it ends,
+// while the control messages of the client are read in the background.
+// It returns the cause of the disconnection, with the error or the reason given by the admins.
+func (h *SubHandler) streamWebSocket(ctx context.Context, s dispatch.Subscriber, st *stream, conn *websocket.Conn) (string, string) {
+	logEntry := h.log.WithField("subscriberId", s.ID()).WithField("subscriber", s.Address())
+
+	replies := make(chan wsControl, wsControlBuffer)
+	readDone := make(chan error, 1)
+	go func() {
+		readDone <- h.readWebSocket(ctx, s, conn, replies)
+	}()
+
+	writeMessage := func(notification string) error {
+		start := time.Now()
+		_ = conn.SetWriteDeadline(start.Add(wsWriteWait))
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(notification)); err != nil {
+			h.taps.publish(s.ID(), tapEvent{Event: tapError, Data: notification, Error: err.Error()})
+			return err
+		}
+		h.metrics.FrameWritten(metrics.FrameNotification, time.Since(start))
+		s.Counters().RecordWrite(len(notification), false)
+		h.taps.publish(s.ID(), tapEvent{Event: tapFrame, Data: notification})
+		return nil
+	}
+	ping := func() error {
+		start := time.Now()
+		if err := conn.WriteControl(websocket.PingMessage, nil, start.Add(wsWriteWait)); err != nil {
+			return err
+		}
+		h.metrics.FrameWritten(metrics.FrameHeartbeat, time.Since(start))
+		s.Counters().RecordWrite(0, true)
+		return nil
+	}
+	closeConn := func(code int, reason string) {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+	}
+
+	ticker := time.NewTicker(h.heartbeatPeriod)
+	defer ticker.Stop()
+	var lastSent time.Time
+	for {
+		notifications, throttled := st.next(s, lastSent)
+		select {
+		case notification := <-notifications:
+			if err := writeTraced(s, notification, writeMessage); err != nil {
+				logEntry.WithError(err).Error("Error while sending notification to subscriber")
+				return sessions.CauseWriteError, err.Error()
+			}
+			lastSent = time.Now()
+		case reply := <-replies:
+			err := conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err == nil {
+				err = conn.WriteJSON(reply)
+			}
+			if err != nil {
+				logEntry.WithError(err).Error("Error while answering a subscriber control message")
+				return sessions.CauseWriteError, err.Error()
+			}
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				logEntry.WithError(err).Error("Sending ping to subscriber has failed")
+				return sessions.CauseWriteError, err.Error()
+			}
+		case err := <-readDone:
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logEntry.Info("Notification subscriber disconnected remotely")
+				return sessions.CauseClient, ""
+			}
+			logEntry.WithError(err).Info("Notification subscriber connection lost")
+			return sessions.CauseClient, err.Error()
+		case <-throttled:
+		case <-ctx.Done():
+			return h.closeWebSocket(ctx, logEntry, st, closeConn)
+		}
+	}
+}
+
+// closeWebSocket sends a close message telling the client why the service ended the connection
+func (h *SubHandler) closeWebSocket(ctx context.Context, logEntry *logger.LogEntry, st *stream, closeConn func(code int, reason string)) (string, string) {
+	cause, reason := disconnected(ctx, logEntry, st)
+	switch cause {
+	case sessions.CauseShutdown:
+		closeConn(websocket.CloseGoingAway, "server shutting down")
+	case sessions.CauseAdmin:
+		closeConn(websocket.ClosePolicyViolation, reason)
+	default:
+		closeConn(websocket.CloseNormalClosure, "")
+	}
+	return cause, reason
+}
+
+// readWebSocket handles the control messages of the client until the connection fails or is closed.
+// The read deadline is extended by every pong, so a client which stops answering the pings is disconnected.
+func (h *SubHandler) readWebSocket(ctx context.Context, s dispatch.Subscriber, conn *websocket.Conn, replies chan<- wsControl) error {
+	pongWait := 2 * h.heartbeatPeriod
+	conn.SetReadLimit(wsMaxControlMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		reply, send := h.control(s, data)
+		if !send {
+			continue
+		}
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// control applies a control message of the client and returns the reply to send, if any
+func (h *SubHandler) control(s dispatch.Subscriber, data []byte) (wsControl, bool) {
+	msg := wsControl{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return wsControl{Action: wsActionError, Error: "invalid control message: " + err.Error()}, true
+	}
+
+	switch msg.Action {
+	case wsActionAck:
+		s.Counters().RecordAck()
+		h.log.WithField("subscriberId", s.ID()).WithField("id", msg.ID).Debug("Notification acknowledged by subscriber")
+		return wsControl{}, false
+	case wsActionSubscribe:
+		filterable, ok := s.(dispatch.FilterableSubscriber)
+		if !ok {
+			return wsControl{Action: wsActionError, Error: "the subscription types cannot be changed"}, true
+		}
+		if len(msg.Types) == 0 {
+			return wsControl{Action: wsActionError, Error: "no subscription type given"}, true
+		}
+		subTypes, err := resolveTypes(msg.Types, h.contentTypesIncludedInAll, h.contentTypesSupported)
+		if err != nil {
+			return wsControl{Action: wsActionError, Error: err.Error()}, true
+		}
+		filterable.SetSubTypes(subTypes)
+		h.log.WithField("subscriberId", s.ID()).WithField("acceptedContentType", subTypes).Info("Subscriber changed its subscription types")
+		return wsControl{Action: wsActionSubscribed, Types: subTypes}, true
+	}
+	return wsControl{Action: wsActionError, Error: fmt.Sprintf("unknown control action %q", msg.Action)}, true
+}
@@ -0,0 +1,176 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/notifications-push/v5/access"
+	"github.com/Financial-Times/notifications-push/v5/dispatch"
+	"github.com/Financial-Times/notifications-push/v5/mocks"
+	"github.com/Financial-Times/notifications-push/v5/sessions"
+)
+
+const streamAPIKey = "some-test-api-key"
+
+// streamTestHandler subscribes the streams of the tests to a running dispatcher
+type streamTestHandler struct {
+	handler    *SubHandler
+	dispatcher *dispatch.Dispatcher
+	shutdown   *mocks.ShutdownReg
+	sessions   *bytes.Buffer
+}
+
+func newStreamTestHandler(t *testing.T) *streamTestHandler {
+	t.Helper()
+
+	l := logger.NewUPPLogger("test", "panic")
+	agent := &stubAgent{result: &access.ContentPolicyResult{Allow: true}}
+	d := dispatch.NewDispatcher(0, dispatch.NewHistory(10, dispatch.OrderByArrival), nil, agent, nil, l)
+	go d.Start()
+	t.Cleanup(d.Stop)
+
+	kp := &mocks.KeyProcessor{}
+	kp.On("Validate", mock.Anything, streamAPIKey).Return(nil)
+	kp.On("Validate", mock.Anything, mock.Anything).Return(access.NewKeyErr("invalid key", http.StatusForbidden, "", ""))
+	pp := &mocks.PolicyProcessor{}
+	pp.On("GetNotificationSubscriptionOptions", mock.Anything, streamAPIKey).Return(&access.NotificationSubscriptionOptions{}, nil)
+
+	reg := mocks.NewShutdownReg()
+	reg.On("RegisterOnShutdown", mock.Anything).Return()
+
+	out := &bytes.Buffer{}
+	sessionLog := logger.NewUPPInfoLogger("test")
+	sessionLog.SetOutput(out)
+
+	return &streamTestHandler{
+		handler: NewSubHandler(d, kp, pp, reg, nil, nil, NewStreams(), sessions.NewRecorder(sessionLog), nil, time.Minute, l,
+			[]string{"Article", "ContentPackage", "Audio"}, []string{"Article", "ContentPackage", "Audio", "All"}, "Article"),
+		dispatcher: d,
+		shutdown:   reg,
+		sessions:   out,
+	}
+}
+
+func (s *streamTestHandler) waitForSubscriber(t *testing.T) dispatch.Subscriber {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(s.dispatcher.Subscribers()) == 1 }, time.Second, 10*time.Millisecond)
+	return s.dispatcher.Subscribers()[0]
+}
+
+type wsTestServer struct {
+	*streamTestHandler
+	url string
+}
+
+func newWSTestServer(t *testing.T) *wsTestServer {
+	t.Helper()
+
+	h := newStreamTestHandler(t)
+	router := mux.NewRouter()
+	router.HandleFunc("/content/notifications-push/ws", h.handler.HandleWebSocket)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	return &wsTestServer{
+		streamTestHandler: h,
+		url:               "ws" + strings.TrimPrefix(srv.URL, "http") + "/content/notifications-push/ws",
+	}
+}
+
+func readControl(t *testing.T, conn *websocket.Conn) wsControl {
+	t.Helper()
+	msg := wsControl{}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocket(t *testing.T) {
+	t.Parallel()
+
+	srv := newWSTestServer(t)
+	conn, resp, err := websocket.DefaultDialer.Dial(srv.url+"?type=Audio&apiKey="+streamAPIKey, nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	defer conn.Close()
+
+	sub := srv.waitForSubscriber(t)
+	assert.Equal(t, []string{"Audio"}, sub.SubTypes())
+
+	require.NoError(t, conn.WriteJSON(wsControl{Action: wsActionSubscribe, Types: []string{"All"}}))
+	assert.Equal(t, wsControl{Action: wsActionSubscribed, Types: []string{"Article", "ContentPackage", "Audio"}}, readControl(t, conn))
+	assert.Equal(t, []string{"Article", "ContentPackage", "Audio"}, sub.SubTypes())
+
+	require.NoError(t, conn.WriteJSON(wsControl{Action: wsActionSubscribe, Types: []string{"Page"}}))
+	assert.Equal(t, wsActionError, readControl(t, conn).Action)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	assert.Equal(t, wsActionError, readControl(t, conn).Action)
+
+	srv.dispatcher.Send(dispatch.NotificationModel{
+		APIURL:           "http://api.ft.com/content/7998974a-1e97-11e6-b286-cddde55ca122",
+		ID:               "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122",
+		Type:             dispatch.ContentUpdateType,
+		PublishReference: "tid_websocket",
+		SubscriptionType: dispatch.ArticleContentType,
+	})
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	msgType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, msgType)
+	notifications := []dispatch.NotificationResponse{}
+	require.NoError(t, json.Unmarshal(data, &notifications))
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "http://www.ft.com/thing/7998974a-1e97-11e6-b286-cddde55ca122", notifications[0].ID)
+
+	require.NoError(t, conn.WriteJSON(wsControl{Action: wsActionAck, ID: notifications[0].ID}))
+	require.Eventually(t, func() bool { return sub.Counters().Snapshot().Acknowledged == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(1), sub.Counters().Snapshot().Sent)
+
+	require.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	require.Eventually(t, func() bool { return len(srv.dispatcher.Subscribers()) == 0 }, time.Second, 10*time.Millisecond)
+
+	record := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(srv.sessions.Bytes(), &record))
+	assert.Equal(t, sessions.CauseClient, record["cause"])
+	assert.Equal(t, float64(1), record["notificationsSent"])
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	t.Parallel()
+
+	srv := newWSTestServer(t)
+	conn, resp, err := websocket.DefaultDialer.Dial(srv.url, http.Header{apiKeyHeaderField: []string{streamAPIKey}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	defer conn.Close()
+
+	srv.waitForSubscriber(t)
+	srv.shutdown.Shutdown()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %v", err)
+}
+
+func TestWebSocketInvalidKey(t *testing.T) {
+	t.Parallel()
+
+	srv := newWSTestServer(t)
+	_, resp, err := websocket.DefaultDialer.Dial(srv.url+"?apiKey=invalid", nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, srv.dispatcher.Subscribers())
+}
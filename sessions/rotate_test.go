@@ -0,0 +1,58 @@
+package sessions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	require.NoError(t, os.WriteFile(path, []byte("0000\n"), 0o600))
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"1111\n", "2222\n", "3333\n", "4444\n", "5555\n", "6666\n"} {
+		_, err = f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(p string) string {
+		content, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(content)
+	}
+	assert.Equal(t, "6666\n", read(path))
+	assert.Equal(t, "4444\n5555\n", read(path+".1"))
+	assert.Equal(t, "2222\n3333\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3", "Only the configured number of backups should be kept")
+
+	_, err = f.Write([]byte("7777\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	f, err := OpenRotatingFile(path, 10, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"1111\n", "2222\n", "3333\n"} {
+		_, err = f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "3333\n", string(content))
+	assert.NoFileExists(t, path+".1")
+}
@@ -0,0 +1,83 @@
+package sessions
+
+import (
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+// disconnect causes. The policies are only checked when subscribing, so a session never ends for a policy reason
+// other than an admin disconnection, recorded as CauseAdmin.
+const (
+	CauseClient     = "client"
+	CauseShutdown   = "shutdown"
+	CauseWriteError = "write-error"
+	CauseAdmin      = "admin"
+)
+
+// logType tells the session records apart when they share the standard output with the service logs
+const logType = "session"
+
+// Record accounts for a subscriber connection once it has ended.
+// Dropped counts the notifications discarded because the subscriber was lagging behind,
+// Failed the ones which could not be serialised for it.
+type Record struct {
+	SubscriberID      string
+	KeyFingerprint    string
+	Address           string
+	SubscriptionTypes []string
+	Monitor           bool
+	ConnectedAt       time.Time
+	DisconnectedAt    time.Time
+	NotificationsSent uint64
+	BytesSent         uint64
+	Dropped           uint64
+	Failed            uint64
+	Cause             string
+	Reason            string
+}
+
+// Duration returns how long the subscriber stayed connected
+func (r Record) Duration() time.Duration {
+	return r.DisconnectedAt.Sub(r.ConnectedAt)
+}
+
+// Recorder emits a session record on a dedicated log stream for every subscriber disconnection.
+// All methods are safe to call on a nil value, which records nothing.
+type Recorder struct {
+	log *logger.UPPLogger
+}
+
+// NewRecorder writes the session records with the given logger, which is expected to be separate from the service logs
+func NewRecorder(log *logger.UPPLogger) *Recorder {
+	return &Recorder{log: log}
+}
+
+// Record emits the session record
+func (s *Recorder) Record(r Record) {
+	if s == nil {
+		return
+	}
+	fields := map[string]interface{}{
+		"logType":           logType,
+		"subscriberId":      r.SubscriberID,
+		"address":           r.Address,
+		"subscriptionTypes": r.SubscriptionTypes,
+		"monitor":           r.Monitor,
+		"connectedAt":       r.ConnectedAt.UTC().Format(time.RFC3339Nano),
+		"disconnectedAt":    r.DisconnectedAt.UTC().Format(time.RFC3339Nano),
+		"durationSeconds":   r.Duration().Seconds(),
+		"notificationsSent": r.NotificationsSent,
+		"bytesSent":         r.BytesSent,
+		"dropped":           r.Dropped,
+		"failed":            r.Failed,
+		"cause":             r.Cause,
+	}
+	if r.KeyFingerprint != "" {
+		fields["keyFingerprint"] = r.KeyFingerprint
+	}
+	if r.Reason != "" {
+		fields["reason"] = r.Reason
+	}
+	s.log.WithFields(fields).Info("Subscriber session ended")
+}
@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	log := logger.NewUPPInfoLogger("test")
+	log.SetOutput(out)
+
+	connectedAt := time.Date(2024, 7, 31, 10, 0, 0, 0, time.UTC)
+	NewRecorder(log).Record(Record{
+		SubscriberID:      "b3c5f0d6-1d1b-4e6e-9a0a-3f6d1f3b1c2d",
+		KeyFingerprint:    "0123456789abcdef",
+		Address:           "192.168.1.2",
+		SubscriptionTypes: []string{"Article", "Audio"},
+		ConnectedAt:       connectedAt,
+		DisconnectedAt:    connectedAt.Add(90 * time.Second),
+		NotificationsSent: 12,
+		BytesSent:         4096,
+		Dropped:           3,
+		Failed:            1,
+		Cause:             CauseAdmin,
+		Reason:            "disconnected by admin",
+	})
+
+	record := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "session", record["logType"])
+	assert.Equal(t, "Subscriber session ended", record["msg"])
+	assert.Equal(t, "b3c5f0d6-1d1b-4e6e-9a0a-3f6d1f3b1c2d", record["subscriberId"])
+	assert.Equal(t, "0123456789abcdef", record["keyFingerprint"])
+	assert.Equal(t, "192.168.1.2", record["address"])
+	assert.Equal(t, []interface{}{"Article", "Audio"}, record["subscriptionTypes"])
+	assert.Equal(t, false, record["monitor"])
+	assert.Equal(t, "2024-07-31T10:00:00Z", record["connectedAt"])
+	assert.Equal(t, "2024-07-31T10:01:30Z", record["disconnectedAt"])
+	assert.Equal(t, 90.0, record["durationSeconds"])
+	assert.Equal(t, 12.0, record["notificationsSent"])
+	assert.Equal(t, 4096.0, record["bytesSent"])
+	assert.Equal(t, 3.0, record["dropped"])
+	assert.Equal(t, 1.0, record["failed"])
+	assert.Equal(t, CauseAdmin, record["cause"])
+	assert.Equal(t, "disconnected by admin", record["reason"])
+}
+
+func TestNilRecorder(t *testing.T) {
+	t.Parallel()
+
+	var r *Recorder
+	assert.NotPanics(t, func() { r.Record(Record{Cause: CauseClient}) })
+}
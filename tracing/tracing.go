@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Financial-Times/notifications-push/v5"
+
+// span exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+)
+
+// span attributes
+const (
+	TransactionIDKey = attribute.Key("ft.transaction_id")
+	SubscriberIDKey  = attribute.Key("ft.subscriber_id")
+)
+
+// Tracer returns the tracer of the service spans, backed by the provider installed by Setup
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the W3C trace context propagator and the global tracer provider exporting the spans to the given target.
+// With no exporter the spans are not recorded, only the incoming trace context is propagated.
+// The returned function flushes the spans left and stops the provider.
+func Setup(exporter string, serviceName string, out io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		var err error
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected %s or %s", exporter, ExporterNone, ExporterStdout)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Extract returns a context holding the trace context found in the message headers
+func Extract(headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(headers))
+}
+
+// ContextWith returns a context holding the span context, used to start the child spans of a notification
+func ContextWith(sc trace.SpanContext) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
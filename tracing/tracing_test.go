@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	shutdown, err := Setup(ExporterNone, "notifications-push", nil)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup("jaeger", "notifications-push", nil)
+	assert.EqualError(t, err, `unknown tracing exporter "jaeger", expected none or stdout`)
+
+	out := &bytes.Buffer{}
+	shutdown, err = Setup(ExporterStdout, "notifications-push", out)
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "HandleMessage")
+	span.SetAttributes(TransactionIDKey.String("tid_test"))
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, out.String(), `"Name":"HandleMessage"`)
+	assert.Contains(t, out.String(), "tid_test")
+	assert.Contains(t, out.String(), "notifications-push")
+}
+
+func TestExtract(t *testing.T) {
+	_, err := Setup(ExporterNone, "notifications-push", nil)
+	require.NoError(t, err)
+
+	sc := trace.SpanContextFromContext(Extract(map[string]string{
+		"X-Request-Id": "tid_test",
+		"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}))
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+
+	sc = trace.SpanContextFromContext(Extract(map[string]string{"X-Request-Id": "tid_test"}))
+	assert.False(t, sc.IsValid())
+
+	assert.Equal(t, sc, trace.SpanContextFromContext(ContextWith(sc)))
+}